@@ -0,0 +1,81 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// maxAuthCacheEntries bounds the tokens an authCache remembers; past it the
+// expired entries are swept, and everything if none has expired.
+const maxAuthCacheEntries = 10000
+
+// authCache remembers for a moment the access tokens that passed the
+// revocation and session checks, so a burst of requests with one token costs
+// a single round of lookups. Signing a session out through this process
+// clears it; another replica may take the session's tokens for up to ttl
+// more. A nil *authCache remembers nothing.
+type authCache struct {
+	ttl time.Duration
+
+	mu sync.Mutex
+	// until holds, by jti, when each token has to be checked again
+	until map[string]time.Time
+}
+
+// newAuthCache returns a cache keeping tokens for ttl, or nil when ttl isn't
+// positive, which checks every request.
+func newAuthCache(ttl time.Duration) *authCache {
+	if ttl <= 0 {
+		return nil
+	}
+
+	return &authCache{ttl: ttl, until: map[string]time.Time{}}
+}
+
+// checked reports whether the token passed the checks less than ttl before now.
+func (c *authCache) checked(jti string, now time.Time) bool {
+	if c == nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	until, ok := c.until[jti]
+	return ok && now.Before(until)
+}
+
+// add records that the token passed the checks at now.
+func (c *authCache) add(jti string, now time.Time) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.until) >= maxAuthCacheEntries {
+		for key, until := range c.until {
+			if !now.Before(until) {
+				delete(c.until, key)
+			}
+		}
+		if len(c.until) >= maxAuthCacheEntries {
+			clear(c.until)
+		}
+	}
+	c.until[jti] = now.Add(c.ttl)
+}
+
+// clear forgets every token, for when sessions end: the tokens of those
+// sessions have to be checked again.
+func (c *authCache) clear() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.until)
+}
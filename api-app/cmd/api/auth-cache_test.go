@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"task-app/db/data"
+	"testing"
+	"time"
+)
+
+func TestAuthCache(t *testing.T) {
+	now := time.Now()
+
+	var disabled *authCache
+	disabled.add("a", now)
+	if disabled.checked("a", now) || newAuthCache(0) != nil {
+		t.Error("a nil authCache remembered a token")
+	}
+
+	cache := newAuthCache(time.Second)
+	if cache.checked("a", now) {
+		t.Error("checked() of an unknown token = true")
+	}
+	cache.add("a", now)
+	if !cache.checked("a", now.Add(time.Second-1)) || cache.checked("a", now.Add(time.Second)) {
+		t.Error("checked() doesn't keep tokens for exactly the ttl")
+	}
+	cache.clear()
+	if cache.checked("a", now) {
+		t.Error("checked() after clear() = true")
+	}
+
+	// A full cache makes room by dropping the expired tokens first
+	for i := range maxAuthCacheEntries {
+		cache.add(fmt.Sprint(i), now.Add(-time.Duration(i%2)*time.Second))
+	}
+	cache.add("b", now)
+	if n := len(cache.until); n != maxAuthCacheEntries/2+1 {
+		t.Errorf("cache holds %d tokens after sweeping, want %d", n, maxAuthCacheEntries/2+1)
+	}
+	if !cache.checked("0", now) || !cache.checked("b", now) {
+		t.Error("sweeping the cache dropped live tokens")
+	}
+}
+
+// countingSessions is a SessionRepository counting the calls to Touch.
+type countingSessions struct {
+	data.SessionRepository
+	touches *int
+}
+
+func (r countingSessions) Touch(ctx context.Context, ID, userID int, now time.Time) error {
+	*r.touches++
+	return r.SessionRepository.Touch(ctx, ID, userID, now)
+}
+
+func TestAuthenticateCache(t *testing.T) {
+	app := newTestApp(t)
+	app.auth = newAuthCache(time.Minute)
+	touches := 0
+	app.models.Session = countingSessions{app.models.Session, &touches}
+	handler := app.routes()
+	ada := signUp(t, handler, "ada")
+	other := logIn(t, handler, "ada", "password")
+
+	for range 3 {
+		w := do(t, handler, http.MethodGet, "/lists", ada.Token, nil)
+		wantStatus(t, "GET /lists", w, http.StatusOK)
+	}
+	if touches != 1 {
+		t.Errorf("3 requests with one token touched its session %d times, want 1", touches)
+	}
+
+	// Signing a session out takes effect at once
+	w := do(t, handler, http.MethodDelete, "/users/sessions?keep_current=true", other.Token, nil)
+	wantStatus(t, "sign out the other sessions", w, http.StatusOK)
+	w = do(t, handler, http.MethodGet, "/lists", ada.Token, nil)
+	wantStatus(t, "GET /lists on a session signed out", w, http.StatusUnauthorized)
+
+	w = do(t, handler, http.MethodPost, "/users/logout", other.Token, nil)
+	wantStatus(t, "logout", w, http.StatusOK)
+	w = do(t, handler, http.MethodGet, "/lists", other.Token, nil)
+	wantStatus(t, "GET /lists after logout", w, http.StatusUnauthorized)
+}
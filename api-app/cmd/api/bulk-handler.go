@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"task-app/db/data"
+	"time"
+)
+
+// maxBulkOperations caps the operations of one POST /todos/bulk
+const maxBulkOperations = 100
+
+// Modes of POST /todos/bulk
+const (
+	bulkAtomic     = "atomic"
+	bulkBestEffort = "best_effort"
+)
+
+// BulkTodos handles POST /todos/bulk, applying a list of operations in one
+// transaction. In the default "atomic" mode any failure undoes them all and
+// the answer is a 422; in "best_effort" mode the failed ones are skipped.
+// Either way, data.results has the outcome of each operation, in order.
+func (app *application) BulkTodos(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var requestPayload struct {
+		Mode       string `json:"mode"`
+		Operations []struct {
+			Op   string `json:"op"`
+			ID   int    `json:"id"`
+			Todo *struct {
+				PriorityID int        `json:"priority_id"`
+				ListID     int        `json:"list_id"`
+				Text       string     `json:"text"`
+				DueAt      *time.Time `json:"due_at"`
+				RemindAt   *time.Time `json:"remind_at"`
+				Recurrence string     `json:"recurrence"`
+				Tags       []string   `json:"tags"`
+			} `json:"todo"`
+			PriorityID int   `json:"priority_id"`
+			ListID     int   `json:"list_id"`
+			Completed  *bool `json:"completed"`
+			// Version, like If-Match, makes an update or delete fail when
+			// the todo has changed since
+			Version int `json:"version"`
+		} `json:"operations"`
+	}
+	if err := app.readJSON(w, r, &requestPayload); err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	mode := requestPayload.Mode
+	if mode == "" {
+		mode = bulkAtomic
+	}
+	if mode != bulkAtomic && mode != bulkBestEffort {
+		app.errorJSON(w, fmt.Errorf("mode must be %q or %q", bulkAtomic, bulkBestEffort))
+		return
+	}
+	if len(requestPayload.Operations) == 0 || len(requestPayload.Operations) > maxBulkOperations {
+		app.errorJSON(w, fmt.Errorf("between 1 and %d operations are required", maxBulkOperations))
+		return
+	}
+
+	// Every operation is validated up front, whatever the mode: a malformed
+	// request changes nothing
+	validationErrors := map[string]string{}
+	ops := make([]data.BulkOperation, len(requestPayload.Operations))
+	for i, input := range requestPayload.Operations {
+		prefix := fmt.Sprintf("operations[%d].", i)
+		op := data.BulkOperation{
+			Op:         input.Op,
+			ID:         input.ID,
+			PriorityID: input.PriorityID,
+			ListID:     input.ListID,
+			Completed:  input.Completed == nil || *input.Completed,
+			Version:    input.Version,
+		}
+
+		switch input.Op {
+		case data.BulkCreate, data.BulkUpdate:
+			if input.Todo == nil {
+				validationErrors[prefix+"todo"] = "The todo field is required."
+				break
+			}
+			rule, err := zonedRecurrence(r, input.Todo.Recurrence)
+			if err != nil {
+				app.errorJSON(w, err)
+				return
+			}
+			op.Todo = data.Todo{
+				UserID:     userID,
+				PriorityID: input.Todo.PriorityID,
+				ListID:     input.Todo.ListID,
+				Text:       input.Todo.Text,
+				DueAt:      input.Todo.DueAt,
+				RemindAt:   input.Todo.RemindAt,
+				Recurrence: rule,
+				Tags:       input.Todo.Tags,
+				Version:    input.Version,
+			}
+			// Updates replace the whole todo, like PUT /todos/{id}
+			if input.Op == data.BulkUpdate {
+				op.Todo.Tags = data.NormalizeTags(input.Todo.Tags)
+			}
+			if validationErr, ok := validateTodoInputs(&op.Todo).(*ValidationError); ok {
+				for field, message := range validationErr.Errors {
+					validationErrors[prefix+"todo."+field] = message
+				}
+			}
+		case data.BulkDelete, data.BulkComplete:
+		case data.BulkSetPriority:
+			if input.PriorityID <= 0 {
+				validationErrors[prefix+"priority_id"] = "The priority id field is required."
+			}
+		case data.BulkMoveToList:
+			if input.ListID <= 0 {
+				validationErrors[prefix+"list_id"] = "The list id field is required."
+			}
+		default:
+			validationErrors[prefix+"op"] = fmt.Sprintf("Unknown operation %q.", input.Op)
+		}
+
+		if input.Op != data.BulkCreate && input.ID <= 0 {
+			validationErrors[prefix+"id"] = "The id field is required."
+		}
+		ops[i] = op
+	}
+	if len(validationErrors) > 0 {
+		app.errorJSONWithData(w, &ValidationError{Errors: validationErrors}, envelope{"errors": validationErrors})
+		return
+	}
+
+	results, err := app.models.Todo.Bulk(r.Context(), userID, ops, mode == bulkAtomic)
+	if err != nil {
+		app.errorLog.Println(err)
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	succeeded := 0
+	for _, result := range results {
+		if result.Status == data.BulkOK {
+			succeeded++
+		}
+	}
+
+	if mode == bulkAtomic && succeeded < len(results) {
+		app.writeJSON(w, http.StatusUnprocessableEntity, jsonResponse{
+			Error:   true,
+			Message: "An operation failed, so none were applied.",
+			Data:    envelope{"results": results},
+		})
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, jsonResponse{
+		Error:   false,
+		Message: fmt.Sprintf("%d of %d operations succeeded.", succeeded, len(results)),
+		Data:    envelope{"results": results},
+	})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"task-app/db/data"
+	"testing"
+)
+
+// bulkResponse is the answer of POST /todos/bulk.
+type bulkResponse struct {
+	Error   bool   `json:"error"`
+	Message string `json:"message"`
+	Data    struct {
+		Results []data.BulkResult `json:"results"`
+		Errors  map[string]string `json:"errors"`
+	} `json:"data"`
+}
+
+func TestBulkTodos(t *testing.T) {
+	handler := newTestApp(t).routes()
+	ada := signUp(t, handler, "ada")
+
+	w := do(t, handler, http.MethodPost, "/todos", ada.Token, envelope{"priority_id": 1, "text": "Existing"})
+	wantStatus(t, "POST /todos", w, http.StatusCreated)
+	existing := decodeTodo(t, w).Data.Todo
+
+	w = do(t, handler, http.MethodPost, "/todos/bulk", "", envelope{})
+	wantStatus(t, "bulk without a token", w, http.StatusUnauthorized)
+
+	// An atomic request with a failure changes nothing
+	w = do(t, handler, http.MethodPost, "/todos/bulk", ada.Token, envelope{"operations": []envelope{
+		{"op": "create", "todo": envelope{"priority_id": 1, "text": "New"}},
+		{"op": "complete", "id": existing.ID},
+		{"op": "delete", "id": existing.ID + 100},
+	}})
+	wantStatus(t, "atomic bulk with a failure", w, http.StatusUnprocessableEntity)
+	var resp bulkResponse
+	decode(t, w, &resp)
+	if len(resp.Data.Results) != 3 || resp.Data.Results[1].Status != data.BulkRolledBack || resp.Data.Results[2].Status != data.BulkFailed {
+		t.Errorf("atomic bulk results = %+v", resp.Data.Results)
+	}
+	w = do(t, handler, http.MethodGet, fmt.Sprintf("/todos/%d", existing.ID), ada.Token, nil)
+	wantStatus(t, "GET", w, http.StatusOK)
+	if got := decodeTodo(t, w).Data.Todo; got.Completed || got.Version != 1 {
+		t.Errorf("todo after the failed atomic bulk = %+v, want it untouched", got)
+	}
+
+	// Best effort keeps what succeeded
+	w = do(t, handler, http.MethodPost, "/todos/bulk", ada.Token, envelope{"mode": "best_effort", "operations": []envelope{
+		{"op": "create", "todo": envelope{"priority_id": 1, "text": "New"}},
+		{"op": "update", "id": existing.ID, "version": 5, "todo": envelope{"priority_id": 1, "text": "Stale"}},
+		{"op": "set_priority", "id": existing.ID, "priority_id": 2},
+	}})
+	wantStatus(t, "best effort bulk", w, http.StatusOK)
+	resp = bulkResponse{}
+	decode(t, w, &resp)
+	if resp.Message != "2 of 3 operations succeeded." {
+		t.Errorf("best effort bulk message = %q", resp.Message)
+	}
+	results := resp.Data.Results
+	if len(results) != 3 || results[0].Status != data.BulkOK || results[0].ID == 0 || results[1].Status != data.BulkFailed || results[2].Status != data.BulkOK {
+		t.Errorf("best effort bulk results = %+v", results)
+	}
+	w = do(t, handler, http.MethodGet, fmt.Sprintf("/todos/%d", existing.ID), ada.Token, nil)
+	wantStatus(t, "GET", w, http.StatusOK)
+	if got := decodeTodo(t, w).Data.Todo; got.Text != "Existing" || got.PriorityID != 2 {
+		t.Errorf("todo after the best effort bulk = %+v, want only the new priority", got)
+	}
+
+	// Malformed requests are turned down before anything runs
+	for _, tc := range []struct {
+		name string
+		body envelope
+	}{
+		{"unknown mode", envelope{"mode": "some", "operations": []envelope{{"op": "complete", "id": existing.ID}}}},
+		{"no operations", envelope{"operations": []envelope{}}},
+		{"too many operations", envelope{"operations": make([]envelope, maxBulkOperations+1)}},
+	} {
+		w = do(t, handler, http.MethodPost, "/todos/bulk", ada.Token, tc.body)
+		wantStatus(t, tc.name, w, http.StatusBadRequest)
+	}
+
+	w = do(t, handler, http.MethodPost, "/todos/bulk", ada.Token, envelope{"mode": "best_effort", "operations": []envelope{
+		{"op": "complete", "id": existing.ID},
+		{"op": "create", "todo": envelope{"priority_id": 1}},
+		{"op": "set_priority", "id": existing.ID},
+		{"op": "rename", "id": existing.ID},
+		{"op": "delete"},
+	}})
+	wantStatus(t, "invalid operations", w, http.StatusBadRequest)
+	resp = bulkResponse{}
+	decode(t, w, &resp)
+	for _, field := range []string{"operations[1].todo.text", "operations[2].priority_id", "operations[3].op", "operations[4].id"} {
+		if resp.Data.Errors[field] == "" {
+			t.Errorf("validation errors = %v, want one for %s", resp.Data.Errors, field)
+		}
+	}
+	if _, ok := resp.Data.Errors["operations[0].id"]; ok {
+		t.Errorf("validation errors = %v, want none for the valid operation", resp.Data.Errors)
+	}
+	w = do(t, handler, http.MethodGet, fmt.Sprintf("/todos/%d", existing.ID), ada.Token, nil)
+	wantStatus(t, "GET", w, http.StatusOK)
+	if got := decodeTodo(t, w).Data.Todo; got.Completed {
+		t.Error("an invalid bulk request applied its valid operations")
+	}
+}
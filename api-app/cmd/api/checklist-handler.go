@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"task-app/db/data"
+)
+
+// maxChecklistTextLength caps the text of a checklist item
+const maxChecklistTextLength = 200
+
+// ChecklistItems handles GET /todos/{id}/items, the todo's checklist by position.
+func (app *application) ChecklistItems(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	todoID, err := readIDParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	app.writeChecklist(w, r, todoID, userID, "OK")
+}
+
+// AddChecklistItem handles POST /todos/{id}/items, adding an item at the end
+// of the todo's checklist.
+func (app *application) AddChecklistItem(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	todoID, err := readIDParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var requestPayload struct {
+		Text string `json:"text"`
+		Done bool   `json:"done"`
+	}
+	if err := app.readJSON(w, r, &requestPayload); err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	item := data.ChecklistItem{TodoID: todoID, Text: strings.TrimSpace(requestPayload.Text), Done: requestPayload.Done}
+
+	if err := validateChecklistInputs(&item); err != nil {
+		app.errorJSONWithData(w, err, envelope{"errors": err.(*ValidationError).Errors})
+		return
+	}
+
+	if err := app.models.Checklist.Insert(r.Context(), &item, userID); err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/todos/%d/items/%d", todoID, item.ID))
+	app.writeJSON(w, http.StatusCreated, jsonResponse{
+		Error:   false,
+		Message: "Checklist item has been successfully added.",
+		Data:    envelope{"item": item},
+	})
+}
+
+// UpdateChecklistItem handles PATCH /todos/{id}/items/{itemID}, which renames
+// or toggles an item; omitted fields keep their current value.
+func (app *application) UpdateChecklistItem(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	todoID, err := readIDParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+	itemID, err := readIDParam(r, "itemID")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var requestPayload struct {
+		Text *string `json:"text"`
+		Done *bool   `json:"done"`
+	}
+	if err := app.readJSON(w, r, &requestPayload); err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	item, err := app.models.Checklist.Get(r.Context(), itemID, todoID, userID)
+	if err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	if requestPayload.Text != nil {
+		item.Text = strings.TrimSpace(*requestPayload.Text)
+	}
+	if requestPayload.Done != nil {
+		item.Done = *requestPayload.Done
+	}
+
+	if err := validateChecklistInputs(item); err != nil {
+		app.errorJSONWithData(w, err, envelope{"errors": err.(*ValidationError).Errors})
+		return
+	}
+
+	if err := app.models.Checklist.Update(r.Context(), item, userID); err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, jsonResponse{
+		Error:   false,
+		Message: "Checklist item has been successfully updated.",
+		Data:    envelope{"item": item},
+	})
+}
+
+// ReorderChecklist handles PUT /todos/{id}/items/order. The body lists every
+// item ID of the todo in the new order.
+func (app *application) ReorderChecklist(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	todoID, err := readIDParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var requestPayload struct {
+		ItemIDs []int `json:"item_ids"`
+	}
+	if err := app.readJSON(w, r, &requestPayload); err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	if err := app.models.Checklist.Reorder(r.Context(), todoID, userID, requestPayload.ItemIDs); err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	app.writeChecklist(w, r, todoID, userID, "Checklist has been successfully reordered.")
+}
+
+// DeleteChecklistItem handles DELETE /todos/{id}/items/{itemID}.
+func (app *application) DeleteChecklistItem(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	todoID, err := readIDParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+	itemID, err := readIDParam(r, "itemID")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	if err := app.models.Checklist.Delete(r.Context(), itemID, todoID, userID); err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, jsonResponse{
+		Error:   false,
+		Message: "Checklist item has been successfully deleted.",
+	})
+}
+
+// writeChecklist answers with the todo's stored checklist.
+func (app *application) writeChecklist(w http.ResponseWriter, r *http.Request, todoID, userID int, message string) {
+	items, err := app.models.Checklist.GetAll(r.Context(), todoID, userID)
+	if err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, jsonResponse{
+		Error:   false,
+		Message: message,
+		Data:    envelope{"items": items},
+	})
+}
+
+func validateChecklistInputs(item *data.ChecklistItem) error {
+	var checklistValidationErrors = map[string]string{}
+
+	// Check for empty fields and add error messages to map
+	checkEmptyField(&checklistValidationErrors, "text", item.Text)
+
+	if len(item.Text) > maxChecklistTextLength {
+		checklistValidationErrors["text"] = fmt.Sprintf("The text can't be longer than %d characters.", maxChecklistTextLength)
+	}
+
+	// If there are validation errors, return a ValidationError with the error map
+	if len(checklistValidationErrors) > 0 {
+		return &ValidationError{Errors: checklistValidationErrors}
+	}
+
+	return nil
+}
@@ -3,10 +3,16 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
+	"task-app/db/data"
+	"time"
+
+	"github.com/go-chi/chi/v5"
 )
 
 func (app *application) readJSON(w http.ResponseWriter, r *http.Request, data interface{}) error {
@@ -52,6 +58,16 @@ func (app *application) writeJSON(w http.ResponseWriter, status int, data interf
 	return nil
 }
 
+// statusClientClosedRequest is the non-standard status (popularised by nginx)
+// logged when the client went away before we could answer.
+const statusClientClosedRequest = 499
+
+// isContextError reports whether err is the data layer telling us the request
+// was canceled or timed out, rather than a genuine failure.
+func isContextError(err error) bool {
+	return errors.Is(err, data.ErrCanceled) || errors.Is(err, data.ErrTimeout)
+}
+
 func (app *application) errorJSON(w http.ResponseWriter, err error, status ...int) error {
 	statusCode := http.StatusBadRequest
 
@@ -61,13 +77,35 @@ func (app *application) errorJSON(w http.ResponseWriter, err error, status ...in
 
 	var customErr error
 	switch {
-	case strings.Contains(err.Error(), "SQLSTATE 23505"):
+	case errors.Is(err, data.ErrCanceled) && errors.Is(err, errServerShutdown):
+		customErr = errServerShutdown
+		statusCode = http.StatusServiceUnavailable
+	case errors.Is(err, data.ErrCanceled):
+		customErr = data.ErrCanceled
+		statusCode = statusClientClosedRequest
+	case errors.Is(err, data.ErrTimeout):
+		customErr = err
+		statusCode = http.StatusServiceUnavailable
+	case errors.Is(err, data.ErrInboxList):
+		customErr = err
+		statusCode = http.StatusForbidden
+	case errors.Is(err, data.ErrArchivedList), errors.Is(err, data.ErrUndoConflict),
+		errors.Is(err, data.ErrNothingToUndo), errors.Is(err, data.ErrNothingToRedo):
+		customErr = err
+		statusCode = http.StatusConflict
+	case errors.Is(err, data.ErrTokenInvalid), errors.Is(err, data.ErrTokenReused):
+		customErr = err
+		statusCode = http.StatusUnauthorized
+	case errors.Is(err, data.ErrVersionConflict):
+		customErr = err
+		statusCode = http.StatusPreconditionFailed
+	case strings.Contains(err.Error(), "SQLSTATE 23505"), strings.Contains(err.Error(), "UNIQUE constraint failed"), errors.Is(err, data.ErrDuplicate):
 		customErr = errors.New("duplicate value violates unique constraint")
 		statusCode = http.StatusForbidden
 	case strings.Contains(err.Error(), "SQLSTATE 22001"):
 		customErr = errors.New("the value you are trying to insert is too large")
 		statusCode = http.StatusForbidden
-	case strings.Contains(err.Error(), "SQLSTATE 23503"):
+	case strings.Contains(err.Error(), "SQLSTATE 23503"), strings.Contains(err.Error(), "FOREIGN KEY constraint failed"):
 		customErr = errors.New("foreign key violation")
 		statusCode = http.StatusForbidden
 	default:
@@ -106,4 +144,97 @@ func checkEmptyField(errors *map[string]string, field string, value interface{})
 	if !v.IsValid() || v.IsZero() {
 		(*errors)[field] = "The " + strings.ReplaceAll(field, "_", " ") + " field is required."
 	}
-}
\ No newline at end of file
+}
+
+// authUserID returns the user ID stored by Authenticate, writing a 401 and
+// returning false when it is missing.
+func (app *application) authUserID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	userID, _ := r.Context().Value(userIDKey).(int64)
+	if userID == 0 {
+		app.writeJSON(w, http.StatusUnauthorized, jsonResponse{
+			Error:   true,
+			Message: "Unauthorized.",
+		})
+		return 0, false
+	}
+
+	return int(userID), true
+}
+
+// readIDParam parses a positive integer URL parameter such as {id}.
+func readIDParam(r *http.Request, name string) (int, error) {
+	id, err := strconv.Atoi(chi.URLParam(r, name))
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid " + name + " parameter")
+	}
+
+	return id, nil
+}
+
+// notFoundOrError writes a 404 for data.ErrNotFound and falls back to errorJSON otherwise.
+func (app *application) notFoundOrError(w http.ResponseWriter, err error) {
+	if errors.Is(err, data.ErrNotFound) {
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
+
+	app.errorJSON(w, err)
+}
+
+// nullableTime tells an explicit JSON null apart from an omitted field, which
+// PATCH payloads need: null clears the value, omitting it leaves it alone.
+type nullableTime struct {
+	Set   bool
+	Value *time.Time
+}
+
+func (n *nullableTime) UnmarshalJSON(b []byte) error {
+	n.Set = true
+	if string(b) == "null" {
+		n.Value = nil
+		return nil
+	}
+
+	var t time.Time
+	if err := json.Unmarshal(b, &t); err != nil {
+		return err
+	}
+	n.Value = &t
+
+	return nil
+}
+
+// readTimezone returns the client's timezone from ?tz= or the X-Timezone
+// header (an IANA name such as "Europe/Berlin"), defaulting to UTC.
+func readTimezone(r *http.Request) (*time.Location, error) {
+	name := r.URL.Query().Get("tz")
+	if name == "" {
+		name = r.Header.Get("X-Timezone")
+	}
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", name)
+	}
+
+	return loc, nil
+}
+
+// background runs fn in its own goroutine, past the response. A panic is
+// logged rather than crashing the server, and shutdown waits for fn.
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				app.errorLog.Printf("background task: %v", err)
+			}
+		}()
+
+		fn()
+	}()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"task-app/db/data"
+	"testing"
+	"time"
+)
+
+func TestErrorJSON(t *testing.T) {
+	app := newTestApp(t)
+
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		message string
+	}{
+		{"canceled", fmt.Errorf("%w: %w", data.ErrCanceled, context.Canceled), statusClientClosedRequest, data.ErrCanceled.Error()},
+		{"shutting down", fmt.Errorf("%w: %w", data.ErrCanceled, errServerShutdown), http.StatusServiceUnavailable, errServerShutdown.Error()},
+		{"timeout", data.ErrTimeout, http.StatusServiceUnavailable, data.ErrTimeout.Error()},
+		{"version conflict", data.ErrVersionConflict, http.StatusPreconditionFailed, data.ErrVersionConflict.Error()},
+		{"other", errors.New("bad input"), http.StatusBadRequest, "bad input"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		app.errorJSON(w, tt.err)
+		var resp jsonResponse
+		decode(t, w, &resp)
+		if w.Code != tt.status || !resp.Error || resp.Message != tt.message {
+			t.Errorf("%s: errorJSON() = %d %+v, want %d %q", tt.name, w.Code, resp, tt.status, tt.message)
+		}
+
+		// The status a caller passes doesn't hide a canceled request
+		if tt.status == statusClientClosedRequest || tt.status == http.StatusServiceUnavailable {
+			w = httptest.NewRecorder()
+			app.errorJSON(w, tt.err, http.StatusInternalServerError)
+			if w.Code != tt.status {
+				t.Errorf("%s: errorJSON(500) answered %d, want %d", tt.name, w.Code, tt.status)
+			}
+		}
+	}
+}
+
+func TestRequestContextErrors(t *testing.T) {
+	handler := newTestApp(t).routes()
+	ada := signUp(t, handler, "ada")
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	stopped, stop := context.WithCancelCause(context.Background())
+	stop(errServerShutdown)
+	expired, expire := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer expire()
+
+	// The queries fail with the context, which decides the answer
+	for _, tc := range []struct {
+		name   string
+		ctx    context.Context
+		status int
+	}{
+		{"client gone", canceled, statusClientClosedRequest},
+		{"server shutting down", stopped, http.StatusServiceUnavailable},
+		{"deadline exceeded", expired, http.StatusServiceUnavailable},
+	} {
+		r := newRequest(t, http.MethodGet, "/todos", ada.Token, nil).WithContext(tc.ctx)
+		wantStatus(t, tc.name, serve(handler, r), tc.status)
+	}
+}
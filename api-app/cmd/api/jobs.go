@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// runJob calls fn every interval until ctx is canceled. Failures are logged
+// and the job simply tries again on the next tick.
+func (app *application) runJob(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := fn(ctx); err != nil && ctx.Err() == nil {
+				app.errorLog.Printf("%s job: %v", name, err)
+			}
+		}
+	}
+}
+
+// fireReminders marks due reminders as fired so clients polling
+// GET /todos/reminders pick them up.
+func (app *application) fireReminders(ctx context.Context) error {
+	fired, err := app.models.Todo.FireReminders(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if fired > 0 {
+		app.infoLog.Printf("Fired %d reminder(s)", fired)
+	}
+
+	return nil
+}
+
+// trashPurgeInterval is how often the trash is checked for todos past the
+// retention period; purging a little late does no harm.
+const trashPurgeInterval = time.Hour
+
+// purgeTrash deletes for good the todos that have been in the trash for
+// longer than the configured retention period.
+func (app *application) purgeTrash(ctx context.Context) error {
+	purged, err := app.models.Todo.PurgeDeleted(ctx, time.Now().Add(-app.config.trashRetention))
+	if err != nil {
+		return err
+	}
+
+	if purged > 0 {
+		app.infoLog.Printf("Purged %d todo(s) from the trash", purged)
+	}
+
+	return nil
+}
+
+// tokenPruneInterval is how often expired refresh tokens and revocations are
+// deleted. Expired tokens are rejected on their own; pruning only keeps the
+// tables small.
+const tokenPruneInterval = time.Hour
+
+// pruneTokens deletes the refresh tokens past their expiry, the revocations
+// of access tokens past theirs, the sessions that ended and the password
+// reset tokens past their expiry.
+func (app *application) pruneTokens(ctx context.Context) error {
+	deleted, err := app.models.RefreshToken.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		app.infoLog.Printf("Deleted %d expired refresh token(s)", deleted)
+	}
+
+	pruned, err := app.models.Revocation.Prune(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if pruned > 0 {
+		app.infoLog.Printf("Pruned %d expired token revocation(s)", pruned)
+	}
+
+	ended, err := app.models.Session.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if ended > 0 {
+		app.infoLog.Printf("Deleted %d ended session(s)", ended)
+	}
+
+	expired, err := app.models.PasswordReset.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if expired > 0 {
+		app.infoLog.Printf("Deleted %d expired password reset token(s)", expired)
+	}
+
+	return nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"task-app/db/data"
+)
+
+// AllLists handles GET /lists, the user's lists by position. Archived lists
+// are included with ?archived=true.
+func (app *application) AllLists(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	includeArchived, _ := strconv.ParseBool(r.URL.Query().Get("archived"))
+
+	lists, err := app.models.List.GetAll(r.Context(), userID, includeArchived)
+	if err != nil {
+		app.errorLog.Println(err)
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	payload := jsonResponse{
+		Error:   false,
+		Message: "OK",
+		Data:    envelope{"lists": lists},
+	}
+
+	app.writeJSON(w, http.StatusOK, payload)
+}
+
+// CreateList handles POST /lists, adding a list after the user's others.
+func (app *application) CreateList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var requestPayload struct {
+		Name string `json:"name"`
+	}
+	if err := app.readJSON(w, r, &requestPayload); err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	list := data.List{UserID: userID, Name: strings.TrimSpace(requestPayload.Name)}
+
+	if err := validateListInputs(&list); err != nil {
+		app.errorJSONWithData(w, err, envelope{"errors": err.(*ValidationError).Errors})
+		return
+	}
+
+	if err := app.models.List.Insert(r.Context(), &list); err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/lists/"+strconv.Itoa(list.ID))
+	app.writeJSON(w, http.StatusCreated, jsonResponse{
+		Error:   false,
+		Message: "List has been successfully created.",
+		Data:    envelope{"list": list},
+	})
+}
+
+// GetList handles GET /lists/{id}.
+func (app *application) GetList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := readIDParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	app.writeList(w, r, id, userID, "OK")
+}
+
+// UpdateList handles PUT /lists/{id}, renaming and repositioning a list. An
+// omitted position keeps the current one.
+func (app *application) UpdateList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := readIDParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var requestPayload struct {
+		Name     string `json:"name"`
+		Position *int   `json:"position"`
+	}
+	if err := app.readJSON(w, r, &requestPayload); err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	list, err := app.models.List.Get(r.Context(), id, userID)
+	if err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	list.Name = strings.TrimSpace(requestPayload.Name)
+	if requestPayload.Position != nil {
+		list.Position = *requestPayload.Position
+	}
+
+	if err := validateListInputs(list); err != nil {
+		app.errorJSONWithData(w, err, envelope{"errors": err.(*ValidationError).Errors})
+		return
+	}
+
+	if err := app.models.List.Update(r.Context(), list); err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	app.writeList(w, r, id, userID, "List has been successfully updated.")
+}
+
+// DeleteList handles DELETE /lists/{id}. The list's todos move to the Inbox,
+// which itself can't be deleted.
+func (app *application) DeleteList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := readIDParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	moved, err := app.models.List.Delete(r.Context(), id, userID)
+	if err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, jsonResponse{
+		Error:   false,
+		Message: "List has been successfully deleted.",
+		Data:    envelope{"moved_todos": moved},
+	})
+}
+
+// ArchiveList handles POST /lists/{id}/archive, hiding the list and its todos
+// from the default views.
+func (app *application) ArchiveList(w http.ResponseWriter, r *http.Request) {
+	app.setListArchived(w, r, true)
+}
+
+// UnarchiveList handles POST /lists/{id}/unarchive.
+func (app *application) UnarchiveList(w http.ResponseWriter, r *http.Request) {
+	app.setListArchived(w, r, false)
+}
+
+func (app *application) setListArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := readIDParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	if err := app.models.List.SetArchived(r.Context(), id, userID, archived); err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	message := "List has been restored."
+	if archived {
+		message = "List has been archived."
+	}
+
+	app.writeList(w, r, id, userID, message)
+}
+
+// ListTodos handles GET /lists/{id}/todos, taking the same query parameters
+// as GET /todos.
+func (app *application) ListTodos(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := readIDParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	if _, err := app.models.List.Get(r.Context(), id, userID); err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	app.pagedTodos(w, r, defaultTodoPageSize, func(filter *data.TodoFilter) { filter.ListID = id })
+}
+
+// writeList answers with the stored list.
+func (app *application) writeList(w http.ResponseWriter, r *http.Request, id, userID int, message string) {
+	list, err := app.models.List.Get(r.Context(), id, userID)
+	if err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, jsonResponse{
+		Error:   false,
+		Message: message,
+		Data:    envelope{"list": list},
+	})
+}
+
+func validateListInputs(list *data.List) error {
+	var listValidationErrors = map[string]string{}
+
+	// Check for empty fields and add error messages to map
+	checkEmptyField(&listValidationErrors, "name", list.Name)
+
+	if len(list.Name) > 100 {
+		listValidationErrors["name"] = "The name can't be longer than 100 characters."
+	}
+
+	// If there are validation errors, return a ValidationError with the error map
+	if len(listValidationErrors) > 0 {
+		return &ValidationError{Errors: listValidationErrors}
+	}
+
+	return nil
+}
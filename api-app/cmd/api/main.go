@@ -1,16 +1,72 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"task-app/db"
 	"task-app/db/data"
+	"task-app/mailer"
+	"task-app/utils"
+	"time"
+	_ "time/tzdata" // Lets ?tz= resolve IANA names on hosts without a zoneinfo database
 )
 
 type config struct {
 	port int
+	// env is "development", "staging" or "production"; only development
+	// runs without signing keys configured
+	env              string
+	dsn              string
+	reminderInterval time.Duration
+	// trashRetention is how long deleted todos stay in the trash; 0 keeps them
+	trashRetention time.Duration
+	// undoWindow is how long a change can be undone, and an undo redone
+	undoWindow time.Duration
+	// accessTokenTTL and refreshTokenTTL are how long the tokens issued at
+	// login and refresh stay valid
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+	// refreshCookie delivers refresh tokens in an HttpOnly cookie rather than
+	// in the response body; refreshCookieSecure keeps the cookie to HTTPS
+	refreshCookie       bool
+	refreshCookieSecure bool
+	// authCacheTTL is how long an access token that passed the revocation
+	// and session checks skips them; 0 checks every request
+	authCacheTTL time.Duration
+	// revocationStore is where revoked access tokens are kept: "db", or
+	// "redis" at redisAddr
+	revocationStore string
+	redisAddr       string
+	redisPassword   string
+	// jwtKeyDir holds the token signing keys, named <kid>.pem or <kid>.key;
+	// jwtSecret adds an HS256 key named "default"
+	jwtKeyDir string
+	jwtSecret string
+	// jwtSigningKey is the kid of the key new tokens are signed with
+	jwtSigningKey string
+	// resetTokenTTL is how long a password reset link works; resetURL is
+	// the page it leads to, which gets the token as its token parameter
+	resetTokenTTL time.Duration
+	resetURL      string
+	// mailer is how emails go out: "log", written to mailFile or stdout, or
+	// "smtp" through smtpAddr
+	mailer       string
+	mailFrom     string
+	mailFile     string
+	smtpAddr     string
+	smtpUsername string
+	smtpPassword string
 }
 
 type application struct {
@@ -18,25 +74,93 @@ type application struct {
 	infoLog  *log.Logger
 	errorLog *log.Logger
 	models   data.Models
+	keys     *utils.Keyring
+	mailer   mailer.Mailer
+	// auth spares recently checked access tokens the lookups of Authenticate
+	auth *authCache
+	// wg tracks the background tasks shutdown waits for
+	wg sync.WaitGroup
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := runMigrate(os.Args[2:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	var cfg config
-	cfg.port = 8081
+	flag.IntVar(&cfg.port, "port", 8081, "API server port")
+	flag.StringVar(&cfg.env, "env", "development", "Environment (development|staging|production)")
+	flag.StringVar(&cfg.dsn, "dsn", os.Getenv("DSN"), "Database DSN: a SQLite file path or a Postgres connection string")
+	flag.DurationVar(&cfg.reminderInterval, "reminder-interval", 30*time.Second, "How often due reminders are fired")
+	flag.DurationVar(&cfg.undoWindow, "undo-window", 10*time.Minute, "How long after a todo change it can still be undone")
+	flag.DurationVar(&cfg.trashRetention, "trash-retention", 30*24*time.Hour, "How long deleted todos stay in the trash before being purged, 0 to keep them")
+	flag.DurationVar(&cfg.accessTokenTTL, "access-token-ttl", 15*time.Minute, "How long access tokens are valid")
+	flag.DurationVar(&cfg.refreshTokenTTL, "refresh-token-ttl", 30*24*time.Hour, "How long refresh tokens are valid")
+	flag.BoolVar(&cfg.refreshCookie, "refresh-cookie", false, "Deliver refresh tokens in an HttpOnly cookie instead of the response body")
+	flag.BoolVar(&cfg.refreshCookieSecure, "refresh-cookie-secure", true, "Only send the refresh token cookie over HTTPS; turn off to develop over plain HTTP")
+	flag.DurationVar(&cfg.authCacheTTL, "auth-cache-ttl", 5*time.Second, "How long an access token that passed the revocation and session checks skips them, 0 to check every request; another replica may take a signed out session's tokens for as long")
+	flag.StringVar(&cfg.revocationStore, "revocation-store", "db", `Where revoked tokens are kept: "db" or "redis"`)
+	flag.StringVar(&cfg.redisAddr, "redis-addr", "localhost:6379", "Redis address (host:port) of the redis revocation store")
+	flag.StringVar(&cfg.redisPassword, "redis-password", os.Getenv("REDIS_PASSWORD"), "Redis password of the redis revocation store")
+	flag.StringVar(&cfg.jwtKeyDir, "jwt-key-dir", os.Getenv("JWT_KEY_DIR"), "Directory of token signing keys: <kid>.pem private keys (RSA, P-256 or Ed25519) and <kid>.key HS256 secrets")
+	flag.StringVar(&cfg.jwtSecret, "jwt-secret", os.Getenv("JWT_SECRET"), `HS256 secret of an extra signing key with kid "default"`)
+	flag.StringVar(&cfg.jwtSigningKey, "jwt-signing-key", "", "kid of the key new tokens are signed with, by default the last in kid order")
+	flag.DurationVar(&cfg.resetTokenTTL, "reset-token-ttl", time.Hour, "How long password reset links work")
+	flag.StringVar(&cfg.resetURL, "reset-url", "http://localhost:5173/reset-password", "Frontend page password reset links lead to, given the token as its token parameter")
+	flag.StringVar(&cfg.mailer, "mailer", "log", `How emails are sent: "log" to write them out, or "smtp"`)
+	flag.StringVar(&cfg.mailFrom, "mail-from", "Task App <no-reply@localhost>", "Sender of the emails")
+	flag.StringVar(&cfg.mailFile, "mail-file", "", "File the log mailer appends emails to, stdout when empty")
+	flag.StringVar(&cfg.smtpAddr, "smtp-addr", "localhost:587", "SMTP server address (host:port) of the smtp mailer")
+	flag.StringVar(&cfg.smtpUsername, "smtp-username", os.Getenv("SMTP_USERNAME"), "SMTP username of the smtp mailer, none when empty")
+	flag.StringVar(&cfg.smtpPassword, "smtp-password", os.Getenv("SMTP_PASSWORD"), "SMTP password of the smtp mailer")
+	flag.Parse()
+
+	if cfg.revocationStore != "db" && cfg.revocationStore != "redis" {
+		log.Fatalf("unknown revocation store %q", cfg.revocationStore)
+	}
+	if cfg.env != "development" && cfg.env != "staging" && cfg.env != "production" {
+		log.Fatalf("unknown environment %q", cfg.env)
+	}
+	if cfg.mailer != "log" && cfg.mailer != "smtp" {
+		log.Fatalf("unknown mailer %q", cfg.mailer)
+	}
+	if _, err := url.Parse(cfg.resetURL); err != nil {
+		log.Fatalf("invalid reset URL: %v", err)
+	}
 
 	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 	errorLog := log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime)
 
-	db.InitDB()
+	keys, err := loadKeyring(cfg, errorLog)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mail, err := newMailer(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db.InitDB(cfg.dsn)
 
 	app := &application{
 		config:   cfg,
 		infoLog:  infoLog,
 		errorLog: errorLog,
-		models:   data.New(db.DB),
+		models:   data.New(db.DB, db.Driver),
+		keys:     keys,
+		mailer:   mail,
+		auth:     newAuthCache(cfg.authCacheTTL),
+	}
+	if cfg.revocationStore == "redis" {
+		app.models.Revocation = data.NewRedisRevocationStore(cfg.redisAddr, cfg.redisPassword)
 	}
 
-	err := app.serve()
+	err = app.serve()
 	if err != nil {
 		fmt.Println("1111")
 		log.Fatal(err)
@@ -44,13 +168,109 @@ func main() {
 
 }
 
+// loadKeyring gathers the signing keys from the configuration. Without any,
+// development signs tokens with a random key, so they don't survive a
+// restart, and other environments refuse to start.
+func loadKeyring(cfg config, errorLog *log.Logger) (*utils.Keyring, error) {
+	var keys []*utils.SigningKey
+	if cfg.jwtKeyDir != "" {
+		loaded, err := utils.LoadKeys(cfg.jwtKeyDir)
+		if err != nil {
+			return nil, err
+		}
+		keys = loaded
+	}
+
+	if cfg.jwtSecret != "" {
+		key, err := utils.HMACKey("default", []byte(cfg.jwtSecret))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		if cfg.env != "development" {
+			return nil, errors.New("no signing keys configured: set -jwt-key-dir or -jwt-secret")
+		}
+		errorLog.Println("WARNING: no signing keys configured, using a temporary one: tokens won't survive a restart")
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		key, err := utils.HMACKey("temporary", secret)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return utils.NewKeyring(keys, cfg.jwtSigningKey)
+}
+
+// newMailer returns the mailer picked by the configuration.
+func newMailer(cfg config) (mailer.Mailer, error) {
+	if cfg.mailer == "smtp" {
+		return mailer.NewSMTPMailer(cfg.smtpAddr, cfg.smtpUsername, cfg.smtpPassword, cfg.mailFrom), nil
+	}
+
+	if cfg.mailFile == "" {
+		return mailer.NewLogMailer(os.Stdout, cfg.mailFrom), nil
+	}
+	file, err := os.OpenFile(cfg.mailFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return mailer.NewLogMailer(file, cfg.mailFrom), nil
+}
+
+// errServerShutdown is the cancellation cause attached to every in-flight
+// request once the server starts shutting down.
+var errServerShutdown = errors.New("the server is shutting down")
+
 func (app *application) serve() error {
 	app.infoLog.Println("API listening on port", app.config.port)
 
+	// Every request context derives from baseCtx, so canceling it on shutdown
+	// stops running queries instead of letting them run to their timeout
+	baseCtx, cancelRequests := context.WithCancelCause(context.Background())
+	defer cancelRequests(nil)
+
 	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", app.config.port),
-		Handler: app.routes(),
+		Addr:        fmt.Sprintf(":%d", app.config.port),
+		Handler:     app.routes(),
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+
+	go app.runJob(baseCtx, "reminders", app.config.reminderInterval, app.fireReminders)
+	if app.config.trashRetention > 0 {
+		go app.runJob(baseCtx, "trash", trashPurgeInterval, app.purgeTrash)
+	}
+	go app.runJob(baseCtx, "tokens", tokenPruneInterval, app.pruneTokens)
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+
+		app.infoLog.Println("Shutting down:", s)
+		cancelRequests(errServerShutdown)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err := srv.Shutdown(ctx)
+
+		// Let emails and the like still on their way go out
+		app.wg.Wait()
+		shutdownErr <- err
+	}()
+
+	err := srv.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
 	}
 
-	return srv.ListenAndServe()
+	return <-shutdownErr
 }
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"task-app/db/data"
+	"task-app/mailer"
+	"task-app/utils"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func init() {
+	data.PasswordCost = bcrypt.MinCost
+}
+
+// newTestApp returns an application on in-memory repositories, signing
+// tokens with a fixed key and discarding its logs and emails.
+func newTestApp(t *testing.T) *application {
+	t.Helper()
+
+	key, err := utils.HMACKey("test", []byte(strings.Repeat("k", 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := utils.NewKeyring([]*utils.SigningKey{key}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	discard := log.New(io.Discard, "", 0)
+
+	return &application{
+		config: config{
+			undoWindow:      10 * time.Minute,
+			accessTokenTTL:  15 * time.Minute,
+			refreshTokenTTL: time.Hour,
+			resetTokenTTL:   time.Hour,
+			resetURL:        "http://localhost:5173/reset-password",
+		},
+		infoLog:  discard,
+		errorLog: discard,
+		models:   data.NewMemory(),
+		keys:     keys,
+		mailer:   mailer.NewLogMailer(io.Discard, "Task App <no-reply@localhost>"),
+	}
+}
+
+// newRequest returns a request to path with body encoded as JSON, unless
+// nil, and token as its bearer token, unless empty.
+func newRequest(t *testing.T, method, path, token string, body any) *http.Request {
+	t.Helper()
+
+	var b bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&b).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := httptest.NewRequest(method, path, &b)
+	r.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return r
+}
+
+// serve answers r with handler.
+func serve(handler http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	return w
+}
+
+// do sends a request built by newRequest to handler.
+func do(t *testing.T, handler http.Handler, method, path, token string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	return serve(handler, newRequest(t, method, path, token, body))
+}
+
+// decode decodes the JSON response in w into v.
+func decode(t *testing.T, w *httptest.ResponseRecorder, v any) {
+	t.Helper()
+
+	if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+}
+
+// wantStatus fails the test unless w has the given status.
+func wantStatus(t *testing.T, what string, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+
+	if w.Code != status {
+		t.Fatalf("%s = %d %s, want %d", what, w.Code, strings.TrimSpace(w.Body.String()), status)
+	}
+}
+
+// tokens are the tokens a login or refresh answers with.
+type tokens struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// signUp registers a user with the email <name>@example.com and the password
+// "password", then logs them in.
+func signUp(t *testing.T, handler http.Handler, name string) tokens {
+	t.Helper()
+
+	w := do(t, handler, http.MethodPost, "/users/register", "", envelope{
+		"name":             name,
+		"email":            name + "@example.com",
+		"password":         "password",
+		"confirm_password": "password",
+	})
+	wantStatus(t, "register", w, http.StatusAccepted)
+
+	return logIn(t, handler, name, "password")
+}
+
+// logIn logs the user with the email <name>@example.com in.
+func logIn(t *testing.T, handler http.Handler, name, password string) tokens {
+	t.Helper()
+
+	w := do(t, handler, http.MethodPost, "/users/login", "", envelope{"email": name + "@example.com", "password": password})
+	wantStatus(t, "login", w, http.StatusOK)
+
+	var resp struct {
+		Data tokens `json:"data"`
+	}
+	decode(t, w, &resp)
+
+	return resp.Data
+}
+
+func TestLoadKeyring(t *testing.T) {
+	discard := log.New(io.Discard, "", 0)
+	secret := strings.Repeat("s", 32)
+
+	tests := []struct {
+		name string
+		cfg  config
+		// want is the kid of the signing key, empty when loading fails
+		want string
+	}{
+		{"development without keys", config{env: "development"}, "temporary"},
+		{"production without keys", config{env: "production"}, ""},
+		{"staging without keys", config{env: "staging"}, ""},
+		{"production with a secret", config{env: "production", jwtSecret: secret}, "default"},
+		{"short secret", config{env: "development", jwtSecret: "short"}, ""},
+		{"unknown signing key", config{env: "production", jwtSecret: secret, jwtSigningKey: "other"}, ""},
+		{"missing key directory", config{env: "production", jwtKeyDir: t.TempDir() + "/missing"}, ""},
+	}
+	for _, tt := range tests {
+		keys, err := loadKeyring(tt.cfg, discard)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%s: loadKeyring() signs with %q, want an error", tt.name, keys.Current().ID)
+			}
+			continue
+		}
+		if err != nil || keys.Current().ID != tt.want {
+			t.Errorf("%s: loadKeyring() = %v, %v; want it signing with %q", tt.name, keys, err, tt.want)
+		}
+	}
+}
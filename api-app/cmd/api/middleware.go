@@ -2,14 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
-	"task-app/utils"
+	"task-app/db/data"
+	"time"
 )
 
 type contextKey string
 
-const userIDKey contextKey = "userID"
+const (
+	userIDKey contextKey = "userID"
+	// claimsKey holds the *utils.Claims of the bearer token
+	claimsKey contextKey = "claims"
+)
 
 func (app *application) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -35,7 +41,34 @@ func (app *application) Authenticate(next http.Handler) http.Handler {
 		}
 
 		token := headerParts[1]
-		userID, err := utils.VerifyToken(token)
+		claims, err := app.keys.VerifyToken(token)
+		// A token checked a moment ago is spared the lookups
+		now := time.Now()
+		checked := err == nil && app.auth.checked(claims.ID, now)
+		if err == nil && !checked {
+			var revoked bool
+			revoked, err = app.models.Revocation.IsRevoked(r.Context(), claims.ID)
+			if err != nil {
+				app.errorLog.Println(err)
+				app.errorJSON(w, err, http.StatusInternalServerError)
+				return
+			}
+			if revoked {
+				err = errors.New("Token is revoked.")
+			}
+		}
+		if err == nil && !checked {
+			// The token's session must still be signed in
+			err = app.models.Session.Touch(r.Context(), int(claims.SessionID), int(claims.UserID), now)
+			if err != nil && !errors.Is(err, data.ErrNotFound) {
+				app.errorLog.Println(err)
+				app.errorJSON(w, err, http.StatusInternalServerError)
+				return
+			}
+			if err == nil {
+				app.auth.add(claims.ID, now)
+			}
+		}
 		if err != nil {
 			payload := jsonResponse{
 				Error:   true,
@@ -47,8 +80,22 @@ func (app *application) Authenticate(next http.Handler) http.Handler {
 		}
 
 		// Store user ID in request context for later use
-		ctx := context.WithValue(r.Context(), userIDKey, userID)
+		ctx := context.WithValue(r.Context(), userIDKey, claims.UserID)
+		ctx = context.WithValue(ctx, claimsKey, claims)
 		
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// Deprecated marks responses from legacy endpoints with a Deprecation header
+// and a Link to the route that replaces them.
+func (app *application) Deprecated(successor string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Link", "<"+successor+">; rel=\"successor-version\"")
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"task-app/db"
+	"text/tabwriter"
+)
+
+const migrateUsage = "usage: api migrate [-dsn DSN] up|down|status|to <version>"
+
+// runMigrate handles the "migrate" subcommand.
+func runMigrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dsn := flags.String("dsn", os.Getenv("DSN"), "Database DSN: a SQLite file path or a Postgres connection string")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	args = flags.Args()
+
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	db.OpenDB(*dsn)
+	defer db.DB.Close()
+
+	migrator, err := db.NewMigrator(db.DB, db.Driver)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		err = migrator.Up()
+	case "down":
+		err = migrator.Down()
+	case "to":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		err = migrator.To(version)
+	case "status":
+		return printMigrationStatus(migrator)
+	default:
+		return errors.New(migrateUsage)
+	}
+	if err != nil {
+		return err
+	}
+
+	current, err := migrator.Current()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Database is at version %d (latest %d).\n", current, migrator.Latest())
+
+	return nil
+}
+
+func printMigrationStatus(migrator *db.Migrator) error {
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.Applied {
+			appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"task-app/db/data"
+	"task-app/mailer"
+	"time"
+)
+
+// mailTimeout bounds sending one email, which happens after the response
+const mailTimeout = 30 * time.Second
+
+// ForgotPassword handles POST /users/password/forgot, emailing a link to
+// reset the password of the account with the given email. The answer is the
+// same whether there is such an account or not, so it can't be used to find
+// out.
+func (app *application) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var requestPayload struct {
+		Email string `json:"email"`
+	}
+	if err := app.readJSON(w, r, &requestPayload); err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	validationErrors := map[string]string{}
+	checkEmptyField(&validationErrors, "email", requestPayload.Email)
+	if len(validationErrors) > 0 {
+		app.errorJSONWithData(w, errors.New("There was an issue with the validation process."), envelope{"errors": validationErrors})
+		return
+	}
+
+	user, err := app.models.User.GetByEmail(r.Context(), requestPayload.Email)
+	if err != nil && !errors.Is(err, data.ErrNotFound) {
+		app.errorJSON(w, err)
+		return
+	}
+	if user != nil {
+		token, err := app.models.PasswordReset.Issue(r.Context(), user.ID, app.config.resetTokenTTL)
+		if err != nil {
+			app.errorJSON(w, err)
+			return
+		}
+
+		// Sending takes a while only when there is an account, so it happens
+		// after the response
+		msg := resetMessage(app.config.resetURL, user, token, app.config.resetTokenTTL)
+		app.background(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+			defer cancel()
+
+			if err := app.mailer.Send(ctx, msg); err != nil {
+				app.errorLog.Printf("sending the password reset email of user %d: %v", user.ID, err)
+			}
+		})
+	}
+
+	app.writeJSON(w, http.StatusAccepted, jsonResponse{
+		Error:   false,
+		Message: "If an account uses this email, a link to reset its password is on its way.",
+	})
+}
+
+// resetMessage is the email carrying a password reset link, made of the
+// reset page at resetURL with the token as its token parameter.
+func resetMessage(resetURL string, user *data.User, token string, ttl time.Duration) mailer.Message {
+	link, _ := url.Parse(resetURL) // Checked at startup
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Someone asked to reset the password of your account. If it was you, choose a new one here:\n\n"+
+			"%s\n\n"+
+			"The link works once, within %s. If you didn't ask for it, you can ignore this email: your password stays the same.\n",
+			user.Name, link, readableDuration(ttl)),
+	}
+}
+
+// readableDuration spells out d in whole hours or minutes, such as "1 hour",
+// falling back to Go's notation for other lengths.
+func readableDuration(d time.Duration) string {
+	unit, name := time.Hour, "hour"
+	if d%time.Hour != 0 {
+		unit, name = time.Minute, "minute"
+	}
+	if d <= 0 || d%unit != 0 {
+		return d.String()
+	}
+
+	n := int(d / unit)
+	if n != 1 {
+		name += "s"
+	}
+
+	return fmt.Sprintf("%d %s", n, name)
+}
+
+// ResetPassword handles POST /users/password/reset, setting a new password
+// with the token of a reset link. Every session of the account is signed
+// out, so the new password is needed to log in again.
+func (app *application) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var requestPayload struct {
+		Token           string `json:"token"`
+		Password        string `json:"password"`
+		ConfirmPassword string `json:"confirm_password"`
+	}
+	if err := app.readJSON(w, r, &requestPayload); err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	validationErrors := map[string]string{}
+	checkEmptyField(&validationErrors, "token", requestPayload.Token)
+	checkEmptyField(&validationErrors, "password", requestPayload.Password)
+	checkEmptyField(&validationErrors, "confirm_password", requestPayload.ConfirmPassword)
+	if requestPayload.Password != "" && requestPayload.ConfirmPassword != "" && requestPayload.Password != requestPayload.ConfirmPassword {
+		validationErrors["confirm_password"] = "The passwords you entered don’t match. Please try again."
+	}
+	if len(validationErrors) > 0 {
+		app.errorJSONWithData(w, errors.New("There was an issue with the validation process."), envelope{"errors": validationErrors})
+		return
+	}
+
+	err := app.models.PasswordReset.Reset(r.Context(), requestPayload.Token, requestPayload.Password)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+	app.auth.clear()
+	app.clearRefreshCookie(w)
+
+	app.writeJSON(w, http.StatusOK, jsonResponse{
+		Error:   false,
+		Message: "Your password has been reset. Please log in with the new one.",
+	})
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"task-app/mailer"
+	"testing"
+	"time"
+)
+
+// testMailer keeps the emails sent instead of sending them.
+type testMailer struct {
+	mu       sync.Mutex
+	messages []mailer.Message
+}
+
+func (m *testMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// forgotPassword asks for a reset link for the user with the email
+// <name>@example.com, then waits for the email to go out and returns the
+// token of its link, or "" when none was sent.
+func forgotPassword(t *testing.T, app *application, handler http.Handler, name string) string {
+	t.Helper()
+
+	mail := app.mailer.(*testMailer)
+	mail.mu.Lock()
+	sent := len(mail.messages)
+	mail.mu.Unlock()
+
+	w := do(t, handler, http.MethodPost, "/users/password/forgot", "", envelope{"email": name + "@example.com"})
+	wantStatus(t, "forgot", w, http.StatusAccepted)
+	app.wg.Wait()
+
+	mail.mu.Lock()
+	defer mail.mu.Unlock()
+	if len(mail.messages) == sent {
+		return ""
+	}
+	msg := mail.messages[len(mail.messages)-1]
+	if msg.To != name+"@example.com" {
+		t.Fatalf("email to %q, want %s@example.com", msg.To, name)
+	}
+
+	for _, line := range strings.Split(msg.Body, "\n") {
+		if strings.HasPrefix(line, app.config.resetURL) {
+			link, err := url.Parse(line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return link.Query().Get("token")
+		}
+	}
+	t.Fatalf("no reset link in %q", msg.Body)
+
+	return ""
+}
+
+// resetPassword sets the password with token, returning the response.
+func resetPassword(t *testing.T, handler http.Handler, token, password string) int {
+	t.Helper()
+
+	w := do(t, handler, http.MethodPost, "/users/password/reset", "", envelope{"token": token, "password": password, "confirm_password": password})
+
+	return w.Code
+}
+
+func TestPasswordReset(t *testing.T) {
+	app := newTestApp(t)
+	app.mailer = &testMailer{}
+	handler := app.routes()
+	phone := signUp(t, handler, "ada")
+	laptop := logIn(t, handler, "ada", "password")
+	bob := signUp(t, handler, "bob")
+
+	// Unknown emails get the same answer, and no email
+	if token := forgotPassword(t, app, handler, "eve"); token != "" {
+		t.Fatalf("a reset link was sent to an unknown email")
+	}
+	w := do(t, handler, http.MethodPost, "/users/password/forgot", "", envelope{})
+	wantStatus(t, "forgot without an email", w, http.StatusBadRequest)
+
+	token := forgotPassword(t, app, handler, "ada")
+	if token == "" {
+		t.Fatal("no reset link was sent")
+	}
+	w = do(t, handler, http.MethodPost, "/users/password/reset", "", envelope{"token": token, "password": "new password", "confirm_password": "other"})
+	wantStatus(t, "reset with passwords that differ", w, http.StatusBadRequest)
+
+	if status := resetPassword(t, handler, token, "new password"); status != http.StatusOK {
+		t.Fatalf("reset = %d, want %d", status, http.StatusOK)
+	}
+
+	// Every session of the account is signed out, refresh tokens included
+	for name, session := range map[string]tokens{"phone": phone, "laptop": laptop} {
+		w := do(t, handler, http.MethodGet, "/lists", session.Token, nil)
+		wantStatus(t, "GET /lists on the "+name+" after the reset", w, http.StatusUnauthorized)
+		w = do(t, handler, http.MethodPost, "/users/token/refresh", "", envelope{"refresh_token": session.RefreshToken})
+		wantStatus(t, "refresh on the "+name+" after the reset", w, http.StatusUnauthorized)
+	}
+	// Other accounts are left alone
+	w = do(t, handler, http.MethodGet, "/lists", bob.Token, nil)
+	wantStatus(t, "GET /lists of another user", w, http.StatusOK)
+
+	w = do(t, handler, http.MethodPost, "/users/login", "", envelope{"email": "ada@example.com", "password": "password"})
+	wantStatus(t, "login with the old password", w, http.StatusBadRequest)
+	logIn(t, handler, "ada", "new password")
+
+	// The link works once
+	if status := resetPassword(t, handler, token, "another password"); status != http.StatusBadRequest {
+		t.Fatalf("second reset = %d, want %d", status, http.StatusBadRequest)
+	}
+	logIn(t, handler, "ada", "new password")
+}
+
+func TestPasswordResetTokens(t *testing.T) {
+	app := newTestApp(t)
+	app.mailer = &testMailer{}
+	handler := app.routes()
+	signUp(t, handler, "ada")
+
+	tests := []struct {
+		name  string
+		token func(t *testing.T) string
+	}{
+		{"unknown", func(t *testing.T) string { return "not-a-token" }},
+		{"replaced by a newer link", func(t *testing.T) string {
+			token := forgotPassword(t, app, handler, "ada")
+			forgotPassword(t, app, handler, "ada")
+			return token
+		}},
+		{"expired", func(t *testing.T) string {
+			app.config.resetTokenTTL = -time.Minute
+			defer func() { app.config.resetTokenTTL = time.Hour }()
+			return forgotPassword(t, app, handler, "ada")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// An empty token would fail validation rather than the token check
+			token := tt.token(t)
+			if token == "" {
+				t.Fatal("no reset link was sent")
+			}
+			if status := resetPassword(t, handler, token, "new password"); status != http.StatusBadRequest {
+				t.Fatalf("reset = %d, want %d", status, http.StatusBadRequest)
+			}
+			logIn(t, handler, "ada", "password")
+		})
+	}
+}
+
+func TestReadableDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{time.Hour, "1 hour"},
+		{48 * time.Hour, "48 hours"},
+		{time.Minute, "1 minute"},
+		{90 * time.Minute, "90 minutes"},
+		{90 * time.Second, "1m30s"},
+		{0, "0s"},
+		{-time.Hour, "-1h0m0s"},
+	}
+	for _, tt := range tests {
+		if got := readableDuration(tt.d); got != tt.want {
+			t.Errorf("readableDuration(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
@@ -1,11 +1,30 @@
 package main
 
-import "net/http"
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"task-app/db/data"
+)
+
+// badgePattern accepts one or more CSS class names, e.g. "is-danger is-light"
+var badgePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+( [A-Za-z0-9_-]+)*$`)
+
+// errDefaultPriority is returned when a user tries to change a shared default priority
+var errDefaultPriority = errors.New("default priorities cannot be changed")
 
 func (app *application) AllPriorities(w http.ResponseWriter, r *http.Request) {
-	priorities, err := app.models.Priority.GetAll()
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	priorities, err := app.models.Priority.GetAll(r.Context(), userID)
 	if err != nil {
 		app.errorLog.Println(err)
+		app.errorJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -16,4 +35,211 @@ func (app *application) AllPriorities(w http.ResponseWriter, r *http.Request) {
 	}
 
 	app.writeJSON(w, http.StatusOK, payload)
-}
\ No newline at end of file
+}
+
+// priorityPayload is the body of POST and PUT /priorities. An omitted rank
+// places the priority after the user's current ones.
+type priorityPayload struct {
+	Name  string `json:"name"`
+	Badge string `json:"badge"`
+	Rank  *int   `json:"rank"`
+}
+
+// CreatePriority handles POST /priorities, adding one of the user's own priorities.
+func (app *application) CreatePriority(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var requestPayload priorityPayload
+	if err := app.readJSON(w, r, &requestPayload); err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	priority := data.Priority{
+		UserID: &userID,
+		Name:   strings.TrimSpace(requestPayload.Name),
+		Badge:  strings.TrimSpace(requestPayload.Badge),
+	}
+
+	if requestPayload.Rank != nil {
+		priority.Rank = *requestPayload.Rank
+	} else {
+		rank, err := app.nextPriorityRank(r, userID)
+		if err != nil {
+			app.errorJSON(w, err, http.StatusInternalServerError)
+			return
+		}
+		priority.Rank = rank
+	}
+
+	if err := validatePriorityInputs(&priority); err != nil {
+		app.errorJSONWithData(w, err, envelope{"errors": err.(*ValidationError).Errors})
+		return
+	}
+
+	if err := app.models.Priority.Insert(r.Context(), &priority); err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/priorities/"+strconv.Itoa(priority.ID))
+	app.writeJSON(w, http.StatusCreated, jsonResponse{
+		Error:   false,
+		Message: "Priority has been successfully created.",
+		Data:    envelope{"priority": priority},
+	})
+}
+
+// UpdatePriority handles PUT /priorities/{id}. Only the user's own
+// priorities can be changed.
+func (app *application) UpdatePriority(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	existing, ok := app.ownPriority(w, r, userID)
+	if !ok {
+		return
+	}
+
+	var requestPayload priorityPayload
+	if err := app.readJSON(w, r, &requestPayload); err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	existing.Name = strings.TrimSpace(requestPayload.Name)
+	existing.Badge = strings.TrimSpace(requestPayload.Badge)
+	if requestPayload.Rank != nil {
+		existing.Rank = *requestPayload.Rank
+	}
+
+	if err := validatePriorityInputs(existing); err != nil {
+		app.errorJSONWithData(w, err, envelope{"errors": err.(*ValidationError).Errors})
+		return
+	}
+
+	if err := app.models.Priority.Update(r.Context(), existing); err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	updated, err := app.models.Priority.Get(r.Context(), existing.ID, userID)
+	if err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, jsonResponse{
+		Error:   false,
+		Message: "Priority has been successfully updated.",
+		Data:    envelope{"priority": updated},
+	})
+}
+
+// DeletePriority handles DELETE /priorities/{id}?reassign_to=. The user's
+// todos with this priority move to reassign_to, or to the first default
+// priority when it's omitted.
+func (app *application) DeletePriority(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	existing, ok := app.ownPriority(w, r, userID)
+	if !ok {
+		return
+	}
+
+	reassignTo := 0
+	if value := r.URL.Query().Get("reassign_to"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id < 1 || id == existing.ID {
+			app.errorJSON(w, errors.New("reassign_to must be the id of another priority"))
+			return
+		}
+		if _, err := app.models.Priority.Get(r.Context(), id, userID); err != nil {
+			if errors.Is(err, data.ErrNotFound) {
+				err = errors.New("reassign_to must be the id of another priority")
+			}
+			app.errorJSON(w, err)
+			return
+		}
+		reassignTo = id
+	}
+
+	reassigned, err := app.models.Priority.Delete(r.Context(), existing.ID, userID, reassignTo)
+	if err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, jsonResponse{
+		Error:   false,
+		Message: "Priority has been successfully deleted.",
+		Data:    envelope{"reassigned_todos": reassigned},
+	})
+}
+
+// ownPriority loads the {id} priority, answering 404 when the user can't see
+// it and 403 when it's one of the shared defaults.
+func (app *application) ownPriority(w http.ResponseWriter, r *http.Request, userID int) (*data.Priority, bool) {
+	id, err := readIDParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, err)
+		return nil, false
+	}
+
+	priority, err := app.models.Priority.Get(r.Context(), id, userID)
+	if err != nil {
+		app.notFoundOrError(w, err)
+		return nil, false
+	}
+	if priority.UserID == nil {
+		app.errorJSON(w, errDefaultPriority, http.StatusForbidden)
+		return nil, false
+	}
+
+	return priority, true
+}
+
+// nextPriorityRank ranks a new priority after every one the user can see.
+func (app *application) nextPriorityRank(r *http.Request, userID int) (int, error) {
+	priorities, err := app.models.Priority.GetAll(r.Context(), userID)
+	if err != nil {
+		return 0, err
+	}
+
+	rank := 0
+	for _, priority := range priorities {
+		rank = max(rank, priority.Rank)
+	}
+
+	return rank + 10, nil
+}
+
+func validatePriorityInputs(priority *data.Priority) error {
+	var priorityValidationErrors = map[string]string{}
+
+	// Check for empty fields and add error messages to map
+	checkEmptyField(&priorityValidationErrors, "name", priority.Name)
+	checkEmptyField(&priorityValidationErrors, "badge", priority.Badge)
+
+	if len(priority.Name) > 50 {
+		priorityValidationErrors["name"] = "The name can't be longer than 50 characters."
+	}
+	if priority.Badge != "" && (len(priority.Badge) > 100 || !badgePattern.MatchString(priority.Badge)) {
+		priorityValidationErrors["badge"] = "The badge must be a list of CSS class names."
+	}
+
+	// If there are validation errors, return a ValidationError with the error map
+	if len(priorityValidationErrors) > 0 {
+		return &ValidationError{Errors: priorityValidationErrors}
+	}
+
+	return nil
+}
@@ -14,9 +14,9 @@ func (app *application) routes() http.Handler {
 	r.Use(middleware.Recoverer)
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*"}, // in production change to frontend domain
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-Timezone", "If-Match", "If-None-Match"},
+		ExposedHeaders:   []string{"Link", "Location", "Deprecation", "ETag"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
@@ -24,14 +24,74 @@ func (app *application) routes() http.Handler {
 	r.Get("/users", app.AllUsers) // my test route removed in production
 	r.Post("/users/register", app.RegisterUser)
 	r.Post("/users/login", app.LoginUser)
+	r.Post("/users/token/refresh", app.RefreshToken)
+	r.Post("/users/password/forgot", app.ForgotPassword)
+	r.Post("/users/password/reset", app.ResetPassword)
+	r.Get("/.well-known/jwks.json", app.JWKS)
 
 	r.Route("/", func(r chi.Router) {
 		r.Use(app.Authenticate)
 		r.Post("/users/logout", app.LogoutUser)
-		r.Get("/priorities", app.AllPriorities)
+		r.Route("/users/sessions", func(r chi.Router) {
+			r.Get("/", app.AllSessions)
+			r.Delete("/", app.RevokeAllSessions)
+			r.Delete("/{id}", app.RevokeSession)
+		})
+		r.Route("/priorities", func(r chi.Router) {
+			r.Get("/", app.AllPriorities)
+			r.Post("/", app.CreatePriority)
+			r.Put("/{id}", app.UpdatePriority)
+			r.Delete("/{id}", app.DeletePriority)
+		})
 
-		r.Route("/todo", func(r chi.Router) {
+		r.Get("/tags", app.AllTags)
+
+		r.Route("/lists", func(r chi.Router) {
+			r.Get("/", app.AllLists)
+			r.Post("/", app.CreateList)
+			r.Get("/{id}", app.GetList)
+			r.Put("/{id}", app.UpdateList)
+			r.Delete("/{id}", app.DeleteList)
+			r.Post("/{id}/archive", app.ArchiveList)
+			r.Post("/{id}/unarchive", app.UnarchiveList)
+			r.Get("/{id}/todos", app.ListTodos)
+		})
+
+		r.Route("/todos", func(r chi.Router) {
 			r.Get("/", app.AllTodos)
+			r.Post("/", app.CreateTodo)
+			r.Get("/search", app.SearchTodos)
+			r.Get("/overdue", app.OverdueTodos)
+			r.Get("/due/today", app.TodosDueToday)
+			r.Get("/due/week", app.TodosDueThisWeek)
+			r.Get("/reminders", app.TodoReminders)
+			r.Get("/trash", app.TrashTodos)
+			r.Delete("/trash", app.EmptyTrash)
+			r.Delete("/trash/{id}", app.PurgeTodo)
+			r.Post("/undo", app.UndoTodo)
+			r.Post("/redo", app.RedoTodo)
+			r.Post("/bulk", app.BulkTodos)
+			r.Get("/{id}", app.GetTodo)
+			r.Put("/{id}", app.ReplaceTodo)
+			r.Patch("/{id}", app.PatchTodo)
+			r.Delete("/{id}", app.DeleteTodoByID)
+			r.Post("/{id}/complete", app.CompleteTodo)
+			r.Post("/{id}/uncomplete", app.UncompleteTodo)
+			r.Post("/{id}/restore", app.RestoreTodo)
+			r.Post("/{id}/move", app.MoveTodo)
+			r.Get("/{id}/occurrences", app.TodoOccurrences)
+			r.Get("/{id}/history", app.TodoHistory)
+			r.Get("/{id}/items", app.ChecklistItems)
+			r.Post("/{id}/items", app.AddChecklistItem)
+			r.Put("/{id}/items/order", app.ReorderChecklist)
+			r.Patch("/{id}/items/{itemID}", app.UpdateChecklistItem)
+			r.Delete("/{id}/items/{itemID}", app.DeleteChecklistItem)
+		})
+
+		// Deprecated RPC-style aliases, kept for the existing Vue client
+		r.Route("/todo", func(r chi.Router) {
+			r.Use(app.Deprecated("/todos"))
+			r.Get("/", app.LegacyAllTodos)
 			r.Post("/save", app.SaveTodo)
 			r.Post("/delete", app.DeleteTodo)
 		})
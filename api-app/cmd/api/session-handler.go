@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"task-app/utils"
+)
+
+// currentSessionID returns the session of the bearer token, set by
+// Authenticate.
+func currentSessionID(r *http.Request) int {
+	claims, _ := r.Context().Value(claimsKey).(*utils.Claims)
+	if claims == nil {
+		return 0
+	}
+
+	return int(claims.SessionID)
+}
+
+// AllSessions handles GET /users/sessions, listing the devices the user is
+// signed in on. The session of the request is marked current.
+func (app *application) AllSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := app.models.Session.GetAll(r.Context(), userID)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	currentID := currentSessionID(r)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"sessions": sessions})
+}
+
+// RevokeSession handles DELETE /users/sessions/{id}, signing one device out.
+// Its access tokens stop working at once and its refresh tokens are revoked.
+func (app *application) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := readIDParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	if err := app.models.Session.Revoke(r.Context(), id, userID); err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+	app.auth.clear()
+	if id == currentSessionID(r) {
+		app.clearRefreshCookie(w)
+	}
+
+	app.writeJSON(w, http.StatusOK, jsonResponse{
+		Error:   false,
+		Message: "Session has been signed out.",
+	})
+}
+
+// RevokeAllSessions handles DELETE /users/sessions, logging the user out
+// everywhere. With ?keep_current=true the session of the request survives.
+func (app *application) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	keepCurrent, _ := strconv.ParseBool(r.URL.Query().Get("keep_current"))
+	exceptID := 0
+	if keepCurrent {
+		exceptID = currentSessionID(r)
+	}
+
+	revoked, err := app.models.Session.RevokeAll(r.Context(), userID, exceptID)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+	app.auth.clear()
+	if !keepCurrent {
+		app.clearRefreshCookie(w)
+	}
+
+	app.writeJSON(w, http.StatusOK, jsonResponse{
+		Error:   false,
+		Message: "Sessions have been signed out.",
+		Data:    envelope{"revoked": revoked},
+	})
+}
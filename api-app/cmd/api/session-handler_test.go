@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestSessions(t *testing.T) {
+	handler := newTestApp(t).routes()
+	phone := signUp(t, handler, "ada")
+	laptop := logIn(t, handler, "ada", "password")
+	tablet := logIn(t, handler, "ada", "password")
+	bob := signUp(t, handler, "bob")
+
+	type session struct {
+		ID      int  `json:"id"`
+		Current bool `json:"current"`
+	}
+	sessions := func(token string) []session {
+		t.Helper()
+
+		w := do(t, handler, http.MethodGet, "/users/sessions", token, nil)
+		wantStatus(t, "GET /users/sessions", w, http.StatusOK)
+		var resp struct {
+			Sessions []session `json:"sessions"`
+		}
+		decode(t, w, &resp)
+
+		return resp.Sessions
+	}
+
+	all := sessions(laptop.Token)
+	if len(all) != 3 {
+		t.Fatalf("sessions = %+v, want 3", all)
+	}
+	var current, other int
+	for _, s := range all {
+		if s.Current {
+			current = s.ID
+		} else {
+			other = s.ID
+		}
+	}
+	if current == 0 {
+		t.Fatalf("sessions = %+v, want one current", all)
+	}
+
+	w := do(t, handler, http.MethodDelete, fmt.Sprintf("/users/sessions/%d", other), bob.Token, nil)
+	wantStatus(t, "revoking another user's session", w, http.StatusNotFound)
+	w = do(t, handler, http.MethodDelete, fmt.Sprintf("/users/sessions/%d", other), laptop.Token, nil)
+	wantStatus(t, "revoking a session", w, http.StatusOK)
+	if n := len(sessions(laptop.Token)); n != 2 {
+		t.Fatalf("sessions after revoking one = %d, want 2", n)
+	}
+
+	w = do(t, handler, http.MethodDelete, "/users/sessions?keep_current=true", laptop.Token, nil)
+	wantStatus(t, "revoking the other sessions", w, http.StatusOK)
+	for name, token := range map[string]string{"phone": phone.Token, "tablet": tablet.Token} {
+		w := do(t, handler, http.MethodGet, "/lists", token, nil)
+		wantStatus(t, "GET /lists on the "+name, w, http.StatusUnauthorized)
+	}
+	if all := sessions(laptop.Token); len(all) != 1 || all[0].ID != current {
+		t.Fatalf("sessions after revoking the others = %+v, want the current one", all)
+	}
+
+	w = do(t, handler, http.MethodDelete, "/users/sessions", laptop.Token, nil)
+	wantStatus(t, "revoking every session", w, http.StatusOK)
+	w = do(t, handler, http.MethodGet, "/lists", laptop.Token, nil)
+	wantStatus(t, "GET /lists after revoking every session", w, http.StatusUnauthorized)
+
+	// Other users are left signed in
+	w = do(t, handler, http.MethodGet, "/lists", bob.Token, nil)
+	wantStatus(t, "GET /lists of another user", w, http.StatusOK)
+}
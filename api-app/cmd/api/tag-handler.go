@@ -0,0 +1,26 @@
+package main
+
+import "net/http"
+
+// AllTags handles GET /tags: the user's tags in use, with how many todos carry each.
+func (app *application) AllTags(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	tags, err := app.models.Tag.GetAll(r.Context(), userID)
+	if err != nil {
+		app.errorLog.Println(err)
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	payload := jsonResponse{
+		Error:   false,
+		Message: "OK",
+		Data:    envelope{"tags": tags},
+	}
+
+	app.writeJSON(w, http.StatusOK, payload)
+}
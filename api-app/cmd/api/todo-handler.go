@@ -1,8 +1,29 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"task-app/db/data"
+	"task-app/recurrence"
+	"time"
+)
+
+const (
+	// defaultTodoPageSize is the page size of GET /todos when no limit is given
+	defaultTodoPageSize = 50
+	// maxTodoPageSize caps the limit query parameter
+	maxTodoPageSize = 200
+
+	maxTodoTags  = 20
+	maxTagLength = 50
+
+	// defaultOccurrences and maxOccurrences bound GET /todos/{id}/occurrences
+	defaultOccurrences = 5
+	maxOccurrences     = 50
 )
 
 func (app *application) SaveTodo(w http.ResponseWriter, r *http.Request) {
@@ -18,9 +39,11 @@ func (app *application) SaveTodo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var requestPayload struct {
-		ID         int    `json:"id"`
-		PriorityID int    `json:"priority_id"`
-		Text       string `json:"text"`
+		ID         int      `json:"id"`
+		PriorityID int      `json:"priority_id"`
+		ListID     int      `json:"list_id"`
+		Text       string   `json:"text"`
+		Tags       []string `json:"tags"`
 	}
 	err := app.readJSON(w, r, &requestPayload)
 	if err != nil {
@@ -32,7 +55,9 @@ func (app *application) SaveTodo(w http.ResponseWriter, r *http.Request) {
 		ID:         requestPayload.ID,
 		UserID:     int(userID),
 		PriorityID: requestPayload.PriorityID,
+		ListID:     requestPayload.ListID,
 		Text:       requestPayload.Text,
+		Tags:       requestPayload.Tags,
 	}
 
 	err = validateTodoInputs(&todo)
@@ -45,13 +70,24 @@ func (app *application) SaveTodo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if todo.ID == 0 {
-		err = todo.Insert()
+		err = app.models.Todo.Insert(r.Context(), &todo)
 		if err != nil {
 			app.errorJSON(w, err)
 			return
 		}
 	} else {
-		err = todo.Update()
+		// The legacy payload has no due dates, so keep the ones already stored
+		existing, err := app.models.Todo.Get(r.Context(), todo.ID, todo.UserID)
+		if err != nil {
+			app.errorJSON(w, err)
+			return
+		}
+		existing.PriorityID = todo.PriorityID
+		existing.ListID = todo.ListID // 0 keeps the current list
+		existing.Text = todo.Text
+		existing.Tags = todo.Tags // nil keeps the stored tags
+
+		err = app.models.Todo.Update(r.Context(), existing)
 		if err != nil {
 			app.errorJSON(w, err)
 			return
@@ -66,22 +102,209 @@ func (app *application) SaveTodo(w http.ResponseWriter, r *http.Request) {
 	app.writeJSON(w, http.StatusAccepted, payload)
 }
 
+// AllTodos handles GET /todos, one page at a time. See readTodoFilter for
+// the query parameters; next_cursor is null on the last page.
 func (app *application) AllTodos(w http.ResponseWriter, r *http.Request) {
-	// Retrieve the user ID from the request context
-	userID := r.Context().Value(userIDKey).(int64)
-	if userID == 0 {
-		// handle missing userID case
-		app.writeJSON(w, http.StatusUnauthorized, jsonResponse{
-			Error:   true,
-			Message: "Unauthorized.",
-		})
+	app.pagedTodos(w, r, defaultTodoPageSize, nil)
+}
+
+// LegacyAllTodos handles the deprecated GET /todo, which still returns every
+// todo unless the client asks for a limit. The Vue list shows them in the
+// order the user arranged them, unless it asks for another sort.
+func (app *application) LegacyAllTodos(w http.ResponseWriter, r *http.Request) {
+	if qs := r.URL.Query(); !qs.Has("sort") {
+		qs.Set("sort", data.SortPosition)
+		r.URL.RawQuery = qs.Encode()
+	}
+
+	app.pagedTodos(w, r, 0, nil)
+}
+
+// pagedTodos answers with a page of todos matching the query string. scope,
+// when not nil, narrows the filter down further, e.g. to one list.
+func (app *application) pagedTodos(w http.ResponseWriter, r *http.Request, defaultLimit int, scope func(*data.TodoFilter)) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	filter, err := readTodoFilter(r, defaultLimit)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+	if scope != nil {
+		scope(&filter)
+	}
 
+	total, err := app.models.Todo.Count(r.Context(), userID, filter)
+	if err != nil {
+		app.errorLog.Println(err)
+		app.errorJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
-	todos, err := app.models.Todo.GetAll(int(userID))
+	// Fetch one extra row to learn whether another page follows
+	limit := filter.Limit
+	if limit > 0 {
+		filter.Limit++
+	}
+
+	todos, err := app.models.Todo.GetAll(r.Context(), userID, filter)
 	if err != nil {
 		app.errorLog.Println(err)
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	var nextCursor *string
+	if limit > 0 && len(todos) > limit {
+		todos = todos[:limit]
+		cursor := filter.CursorFor(todos[limit-1]).Encode()
+		nextCursor = &cursor
+	}
+
+	payload := jsonResponse{
+		Error:   false,
+		Message: "OK",
+		Data:    envelope{"todos": todos, "next_cursor": nextCursor, "total": total},
+	}
+
+	app.writeJSON(w, http.StatusOK, payload)
+}
+
+// OverdueTodos handles GET /todos/overdue: open todos whose due date has passed.
+func (app *application) OverdueTodos(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	open := false
+	app.listTodos(w, r, userID, data.TodoFilter{Completed: &open, DueBefore: &now, Sort: data.SortDueAt})
+}
+
+// TodosDueToday handles GET /todos/due/today, where "today" is the calendar
+// day in the client's timezone (?tz= or the X-Timezone header).
+func (app *application) TodosDueToday(w http.ResponseWriter, r *http.Request) {
+	app.todosDueWithin(w, r, func(today time.Time) (time.Time, time.Time) {
+		return today, today.AddDate(0, 0, 1)
+	})
+}
+
+// TodosDueThisWeek handles GET /todos/due/week, Monday to Sunday in the
+// client's timezone.
+func (app *application) TodosDueThisWeek(w http.ResponseWriter, r *http.Request) {
+	app.todosDueWithin(w, r, func(today time.Time) (time.Time, time.Time) {
+		daysSinceMonday := (int(today.Weekday()) + 6) % 7
+		monday := today.AddDate(0, 0, -daysSinceMonday)
+		return monday, monday.AddDate(0, 0, 7)
+	})
+}
+
+// todosDueWithin lists todos due in the window that bounds computes from the
+// start of today in the client's timezone.
+func (app *application) todosDueWithin(w http.ResponseWriter, r *http.Request, bounds func(today time.Time) (time.Time, time.Time)) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	loc, err := readTimezone(r)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	from, before := bounds(today)
+
+	app.listTodos(w, r, userID, data.TodoFilter{DueFrom: &from, DueBefore: &before, Sort: data.SortDueAt})
+}
+
+// SearchTodos handles GET /todos/search?q=, returning the best matches first
+// with highlighted snippets. Each word of q also matches longer words it
+// starts ("gro" finds "groceries").
+func (app *application) SearchTodos(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	limit := defaultTodoPageSize
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxTodoPageSize {
+			app.errorJSON(w, fmt.Errorf("limit must be between 1 and %d", maxTodoPageSize))
+			return
+		}
+		limit = parsed
+	}
+
+	results, err := app.models.Todo.Search(r.Context(), userID, r.URL.Query().Get("q"), limit)
+	if errors.Is(err, data.ErrEmptySearch) {
+		app.errorJSON(w, errors.New("q must contain at least one word"))
+		return
+	}
+	if err != nil {
+		app.errorLog.Println(err)
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	payload := jsonResponse{
+		Error:   false,
+		Message: "OK",
+		Data:    envelope{"results": results},
+	}
+
+	app.writeJSON(w, http.StatusOK, payload)
+}
+
+// TodoReminders handles GET /todos/reminders. Clients poll it with ?since=
+// set to the server_time of their previous poll to get newly fired reminders.
+func (app *application) TodoReminders(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var since time.Time
+	if value := r.URL.Query().Get("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			app.errorJSON(w, errors.New("since must be an RFC 3339 timestamp"))
+			return
+		}
+		since = parsed
+	}
+
+	serverTime := time.Now().UTC()
+	open := false
+
+	todos, err := app.models.Todo.GetAll(r.Context(), userID, data.TodoFilter{Completed: &open, ReminderFiredAfter: &since})
+	if err != nil {
+		app.errorLog.Println(err)
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	payload := jsonResponse{
+		Error:   false,
+		Message: "OK",
+		Data:    envelope{"todos": todos, "server_time": serverTime},
+	}
+
+	app.writeJSON(w, http.StatusOK, payload)
+}
+
+func (app *application) listTodos(w http.ResponseWriter, r *http.Request, userID int, filter data.TodoFilter) {
+	todos, err := app.models.Todo.GetAll(r.Context(), userID, filter)
+	if err != nil {
+		app.errorLog.Println(err)
+		app.errorJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -115,7 +338,11 @@ func (app *application) DeleteTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = app.models.Todo.Delete(requestPayload.ID, int(userID))
+	err = app.models.Todo.Delete(r.Context(), requestPayload.ID, int(userID), 0)
+	if isContextError(err) {
+		app.errorJSON(w, err)
+		return
+	}
 	if err != nil {
 		app.writeJSON(w, http.StatusInternalServerError, jsonResponse{
 			Error:   true,
@@ -139,6 +366,30 @@ func validateTodoInputs(todo *data.Todo) error {
 	checkEmptyField(&todoValidationErrors, "priority_id", todo.PriorityID)
 	checkEmptyField(&todoValidationErrors, "text", todo.Text)
 
+	if todo.DueAt != nil && todo.RemindAt != nil && todo.RemindAt.After(*todo.DueAt) {
+		todoValidationErrors["remind_at"] = "The reminder can't be set after the due date."
+	}
+
+	if todo.Recurrence != "" {
+		if _, err := recurrence.Parse(todo.Recurrence); err != nil {
+			todoValidationErrors["recurrence"] = err.Error()
+		} else if todo.DueAt == nil {
+			todoValidationErrors["due_at"] = "A recurring todo needs a due date."
+		}
+	}
+
+	if todo.Tags != nil {
+		tags := data.NormalizeTags(todo.Tags)
+		if len(tags) > maxTodoTags {
+			todoValidationErrors["tags"] = fmt.Sprintf("A todo can't have more than %d tags.", maxTodoTags)
+		}
+		for _, tag := range tags {
+			if len(tag) > maxTagLength {
+				todoValidationErrors["tags"] = fmt.Sprintf("Tags can't be longer than %d characters.", maxTagLength)
+			}
+		}
+	}
+
 	// If there are validation errors, return a ValidationError with the error map
 	if len(todoValidationErrors) > 0 {
 		return &ValidationError{Errors: todoValidationErrors}
@@ -147,3 +398,733 @@ func validateTodoInputs(todo *data.Todo) error {
 	// No validation errors
 	return nil
 }
+
+// CreateTodo handles POST /todos and answers with the stored record.
+func (app *application) CreateTodo(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var requestPayload struct {
+		PriorityID int        `json:"priority_id"`
+		ListID     int        `json:"list_id"`
+		Text       string     `json:"text"`
+		DueAt      *time.Time `json:"due_at"`
+		RemindAt   *time.Time `json:"remind_at"`
+		Recurrence string     `json:"recurrence"`
+		Tags       []string   `json:"tags"`
+	}
+	err := app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	rule, err := zonedRecurrence(r, requestPayload.Recurrence)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	todo := data.Todo{
+		UserID:     userID,
+		PriorityID: requestPayload.PriorityID,
+		ListID:     requestPayload.ListID,
+		Text:       requestPayload.Text,
+		DueAt:      requestPayload.DueAt,
+		RemindAt:   requestPayload.RemindAt,
+		Recurrence: rule,
+		Tags:       requestPayload.Tags,
+	}
+
+	err = validateTodoInputs(&todo)
+	if validationErr, ok := err.(*ValidationError); ok {
+		app.errorJSONWithData(w, err, envelope{"errors": validationErr.Errors})
+		return
+	}
+
+	err = app.models.Todo.Insert(r.Context(), &todo)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	app.writeTodo(w, r, todo.ID, userID, http.StatusCreated, "Todo has been successfully created.")
+}
+
+// GetTodo handles GET /todos/{id}. The todo's ETag in If-None-Match gets a
+// 304 Not Modified.
+func (app *application) GetTodo(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := readIDParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	app.writeTodo(w, r, id, userID, http.StatusOK, "OK")
+}
+
+// ReplaceTodo handles PUT /todos/{id}; every field must be supplied.
+func (app *application) ReplaceTodo(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := readIDParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	version, ok := app.ifMatchVersion(w, r, id, userID)
+	if !ok {
+		return
+	}
+
+	var requestPayload struct {
+		PriorityID int        `json:"priority_id"`
+		ListID     int        `json:"list_id"`
+		Text       string     `json:"text"`
+		DueAt      *time.Time `json:"due_at"`
+		RemindAt   *time.Time `json:"remind_at"`
+		Recurrence string     `json:"recurrence"`
+		Tags       []string   `json:"tags"`
+	}
+	err = app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	rule, err := zonedRecurrence(r, requestPayload.Recurrence)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	todo := data.Todo{
+		ID:         id,
+		UserID:     userID,
+		PriorityID: requestPayload.PriorityID,
+		ListID:     requestPayload.ListID,
+		Text:       requestPayload.Text,
+		DueAt:      requestPayload.DueAt,
+		RemindAt:   requestPayload.RemindAt,
+		Recurrence: rule,
+		// PUT replaces the whole todo, so omitted tags and rules are cleared
+		Tags:    data.NormalizeTags(requestPayload.Tags),
+		Version: version,
+	}
+
+	app.updateTodo(w, r, &todo)
+}
+
+// PatchTodo handles PATCH /todos/{id}; omitted fields keep their current value.
+func (app *application) PatchTodo(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := readIDParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	version, ok := app.ifMatchVersion(w, r, id, userID)
+	if !ok {
+		return
+	}
+
+	var requestPayload struct {
+		PriorityID *int         `json:"priority_id"`
+		ListID     *int         `json:"list_id"`
+		Text       *string      `json:"text"`
+		DueAt      nullableTime `json:"due_at"`
+		RemindAt   nullableTime `json:"remind_at"`
+		Recurrence *string      `json:"recurrence"`
+		Tags       *[]string    `json:"tags"`
+	}
+	err = app.readJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	todo, err := app.models.Todo.Get(r.Context(), id, userID)
+	if err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+	// Without If-Match, the patch applies to whatever is stored by then
+	todo.Version = version
+
+	if requestPayload.PriorityID != nil {
+		todo.PriorityID = *requestPayload.PriorityID
+	}
+	if requestPayload.ListID != nil {
+		todo.ListID = *requestPayload.ListID
+	}
+	if requestPayload.Text != nil {
+		todo.Text = *requestPayload.Text
+	}
+	if requestPayload.DueAt.Set {
+		todo.DueAt = requestPayload.DueAt.Value
+	}
+	if requestPayload.RemindAt.Set {
+		todo.RemindAt = requestPayload.RemindAt.Value
+	}
+	if requestPayload.Recurrence != nil {
+		// An empty rule stops the todo from recurring
+		if todo.Recurrence, err = zonedRecurrence(r, *requestPayload.Recurrence); err != nil {
+			app.errorJSON(w, err)
+			return
+		}
+	}
+	if requestPayload.Tags != nil {
+		todo.Tags = data.NormalizeTags(*requestPayload.Tags)
+	}
+
+	app.updateTodo(w, r, todo)
+}
+
+// DeleteTodoByID handles DELETE /todos/{id}, moving the todo to the trash.
+func (app *application) DeleteTodoByID(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := readIDParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	version, ok := app.ifMatchVersion(w, r, id, userID)
+	if !ok {
+		return
+	}
+
+	err = app.models.Todo.Delete(r.Context(), id, userID, version)
+	if errors.Is(err, data.ErrVersionConflict) {
+		app.preconditionFailed(w, r, id, userID)
+		return
+	}
+	if err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	payload := jsonResponse{
+		Error:   false,
+		Message: "Todo has been moved to the trash.",
+	}
+
+	app.writeJSON(w, http.StatusOK, payload)
+}
+
+// MoveTodo handles POST /todos/{id}/move, the drop of a drag and drop. The body
+// names the todo to follow (after_id), the one to precede (before_id), or both,
+// from the same list.
+func (app *application) MoveTodo(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := readIDParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var requestPayload struct {
+		AfterID  int `json:"after_id"`
+		BeforeID int `json:"before_id"`
+	}
+	if err := app.readJSON(w, r, &requestPayload); err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+	if requestPayload.AfterID < 0 || requestPayload.BeforeID < 0 {
+		app.errorJSON(w, data.ErrInvalidMove)
+		return
+	}
+
+	if err := app.models.Todo.Move(r.Context(), id, userID, requestPayload.AfterID, requestPayload.BeforeID); err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	app.writeTodo(w, r, id, userID, http.StatusOK, "Todo has been successfully moved.")
+}
+
+// CompleteTodo handles POST /todos/{id}/complete.
+func (app *application) CompleteTodo(w http.ResponseWriter, r *http.Request) {
+	app.setTodoCompleted(w, r, true)
+}
+
+// UncompleteTodo handles POST /todos/{id}/uncomplete.
+func (app *application) UncompleteTodo(w http.ResponseWriter, r *http.Request) {
+	app.setTodoCompleted(w, r, false)
+}
+
+func (app *application) setTodoCompleted(w http.ResponseWriter, r *http.Request, completed bool) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := readIDParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	nextID, err := app.models.Todo.SetCompleted(r.Context(), id, userID, completed)
+	if err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	message := "Todo has been marked as not done."
+	if completed {
+		message = "Todo has been marked as done."
+	}
+
+	if nextID == 0 {
+		app.writeTodo(w, r, id, userID, http.StatusOK, message)
+		return
+	}
+
+	// Completing a recurring todo also answers with its next occurrence
+	todo, err := app.models.Todo.Get(r.Context(), id, userID)
+	if err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+	next, err := app.models.Todo.Get(r.Context(), nextID, userID)
+	if err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/todos/%d", todo.ID))
+
+	app.writeJSON(w, http.StatusOK, jsonResponse{
+		Error:   false,
+		Message: message,
+		Data:    envelope{"todo": todo, "next_todo": next},
+	}, headers)
+}
+
+// TodoOccurrences handles GET /todos/{id}/occurrences?count=, previewing the
+// next due dates of a recurring todo (5 by default), in the rule's time zone.
+func (app *application) TodoOccurrences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := readIDParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	count := defaultOccurrences
+	if value := r.URL.Query().Get("count"); value != "" {
+		count, err = strconv.Atoi(value)
+		if err != nil || count < 1 || count > maxOccurrences {
+			app.errorJSON(w, fmt.Errorf("count must be between 1 and %d", maxOccurrences))
+			return
+		}
+	}
+
+	todo, err := app.models.Todo.Get(r.Context(), id, userID)
+	if err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	occurrences := []time.Time{}
+	if todo.Recurrence != "" && todo.DueAt != nil {
+		rule, err := recurrence.Parse(todo.Recurrence)
+		if err != nil {
+			app.errorJSON(w, err, http.StatusInternalServerError)
+			return
+		}
+		occurrences = append(occurrences, rule.Occurrences(*todo.DueAt, todo.RecurrenceIndex, count)...)
+	}
+
+	app.writeJSON(w, http.StatusOK, jsonResponse{
+		Error:   false,
+		Message: "OK",
+		Data:    envelope{"occurrences": occurrences},
+	})
+}
+
+// TodoHistory handles GET /todos/{id}/history, listing the changes made to a
+// todo, oldest first. It also works for todos in the trash.
+func (app *application) TodoHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := readIDParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	events, err := app.models.Todo.History(r.Context(), id, userID)
+	if err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, jsonResponse{
+		Error:   false,
+		Message: "OK",
+		Data:    envelope{"events": events},
+	})
+}
+
+// UndoTodo handles POST /todos/undo, reverting the user's latest create,
+// update, move or delete of a todo, if it was recent enough.
+func (app *application) UndoTodo(w http.ResponseWriter, r *http.Request) {
+	app.undoTodo(w, r, app.models.Todo.Undo, "Change has been undone.")
+}
+
+// RedoTodo handles POST /todos/redo, reapplying the change the last undo
+// reverted, until another change is made.
+func (app *application) RedoTodo(w http.ResponseWriter, r *http.Request) {
+	app.undoTodo(w, r, app.models.Todo.Redo, "Change has been redone.")
+}
+
+// undoTodo answers with the event undo reverted or reapplied, and the todo it
+// left, which is null once the todo is in the trash.
+func (app *application) undoTodo(w http.ResponseWriter, r *http.Request, undo func(context.Context, int, time.Duration) (*data.TodoEvent, error), message string) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	event, err := undo(r.Context(), userID, app.config.undoWindow)
+	if err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	todo, err := app.models.Todo.Get(r.Context(), event.TodoID, userID)
+	if err != nil && !errors.Is(err, data.ErrNotFound) {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	headers := make(http.Header)
+	if todo != nil {
+		headers.Set("Location", fmt.Sprintf("/todos/%d", todo.ID))
+	}
+
+	app.writeJSON(w, http.StatusOK, jsonResponse{
+		Error:   false,
+		Message: message,
+		Data:    envelope{"event": event, "todo": todo},
+	}, headers)
+}
+
+// zonedRecurrence puts a recurrence rule in canonical form, reading it in the
+// request's time zone unless it names its own TZID. Invalid rules come back
+// unchanged for validateTodoInputs to report.
+func zonedRecurrence(r *http.Request, rule string) (string, error) {
+	parsed, err := recurrence.Parse(rule)
+	if rule == "" || err != nil {
+		return rule, nil
+	}
+
+	if parsed.Location == nil {
+		loc, err := readTimezone(r)
+		if err != nil {
+			return "", err
+		}
+		// Parsed again so that a date-only UNTIL is read in that zone too
+		if parsed, err = recurrence.Parse(rule + ";TZID=" + loc.String()); err != nil {
+			return rule, nil
+		}
+	}
+
+	return parsed.String(), nil
+}
+
+// readTodoFilter builds a data.TodoFilter from the list query string:
+//
+//	list_id         keeps the todos of one list
+//	archived        true to include todos in archived lists
+//	completed       true or false
+//	priority_id     repeatable, keeps todos with any of the priorities
+//	tag             repeatable, keeps todos with any of the tags
+//	tag_match       any (default) or all, to require every tag
+//	q               text contains, ignoring case
+//	created_from    inclusive lower bound on created_at
+//	created_before  exclusive upper bound on created_at
+//	updated_from    inclusive lower bound on updated_at
+//	updated_before  exclusive upper bound on updated_at
+//	sort            created_at, updated_at, priority, due_at or position (default: id)
+//	direction       asc (default) or desc
+//	limit           page size, at most maxTodoPageSize
+//	cursor          next_cursor from the previous page, same sort and direction
+//
+// Date bounds take an RFC 3339 timestamp or a YYYY-MM-DD date, which means
+// midnight in the client's timezone (see readTimezone).
+func readTodoFilter(r *http.Request, defaultLimit int) (data.TodoFilter, error) {
+	var filter data.TodoFilter
+	qs := r.URL.Query()
+
+	if value := qs.Get("list_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id < 1 {
+			return filter, errors.New("list_id must be a positive integer")
+		}
+		filter.ListID = id
+	}
+	if value := qs.Get("archived"); value != "" {
+		includeArchived, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, errors.New("archived must be true or false")
+		}
+		filter.IncludeArchived = includeArchived
+	}
+
+	if value := qs.Get("completed"); value != "" {
+		completed, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, errors.New("completed must be true or false")
+		}
+		filter.Completed = &completed
+	}
+
+	for _, value := range qs["priority_id"] {
+		id, err := strconv.Atoi(value)
+		if err != nil || id < 1 {
+			return filter, errors.New("priority_id must be a positive integer")
+		}
+		filter.PriorityIDs = append(filter.PriorityIDs, id)
+	}
+
+	filter.Tags = qs["tag"]
+	switch match := qs.Get("tag_match"); match {
+	case "", "any":
+	case "all":
+		filter.TagsMatchAll = true
+	default:
+		return filter, errors.New("tag_match must be any or all")
+	}
+
+	filter.Text = strings.TrimSpace(qs.Get("q"))
+
+	loc, err := readTimezone(r)
+	if err != nil {
+		return filter, err
+	}
+
+	bounds := []struct {
+		param string
+		dest  **time.Time
+	}{
+		{"created_from", &filter.CreatedFrom},
+		{"created_before", &filter.CreatedBefore},
+		{"updated_from", &filter.UpdatedFrom},
+		{"updated_before", &filter.UpdatedBefore},
+	}
+	for _, bound := range bounds {
+		value := qs.Get(bound.param)
+		if value == "" {
+			continue
+		}
+		t, err := parseTimeParam(value, loc)
+		if err != nil {
+			return filter, fmt.Errorf("%s must be an RFC 3339 timestamp or a YYYY-MM-DD date", bound.param)
+		}
+		*bound.dest = &t
+	}
+
+	switch sort := qs.Get("sort"); sort {
+	case data.SortDefault, data.SortCreatedAt, data.SortUpdatedAt, data.SortPriority, data.SortDueAt, data.SortPosition:
+		filter.Sort = sort
+	default:
+		return filter, fmt.Errorf("cannot sort by %q", sort)
+	}
+
+	switch direction := qs.Get("direction"); direction {
+	case "", "asc":
+	case "desc":
+		filter.Descending = true
+	default:
+		return filter, errors.New("direction must be asc or desc")
+	}
+
+	filter.Limit = defaultLimit
+	if value := qs.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxTodoPageSize {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxTodoPageSize)
+		}
+		filter.Limit = limit
+	}
+
+	if value := qs.Get("cursor"); value != "" {
+		cursor, err := filter.DecodeCursor(value)
+		if err != nil {
+			return filter, errors.New("cursor is invalid or was issued for a different sort")
+		}
+		filter.After = cursor
+	}
+
+	return filter, nil
+}
+
+// parseTimeParam accepts an RFC 3339 timestamp or a YYYY-MM-DD date, read as
+// midnight in loc.
+func parseTimeParam(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	return time.ParseInLocation(time.DateOnly, value, loc)
+}
+
+// updateTodo validates and stores todo, then answers with the stored record.
+func (app *application) updateTodo(w http.ResponseWriter, r *http.Request, todo *data.Todo) {
+	err := validateTodoInputs(todo)
+	if validationErr, ok := err.(*ValidationError); ok {
+		app.errorJSONWithData(w, err, envelope{"errors": validationErr.Errors})
+		return
+	}
+
+	err = app.models.Todo.Update(r.Context(), todo)
+	if errors.Is(err, data.ErrVersionConflict) {
+		app.preconditionFailed(w, r, todo.ID, todo.UserID)
+		return
+	}
+	if err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	app.writeTodo(w, r, todo.ID, todo.UserID, http.StatusOK, "Todo has been successfully saved.")
+}
+
+// writeTodo reloads a todo, so the response carries server-set fields such as
+// timestamps and the joined priority, and its ETag. Writes also get a
+// Location header, and reads whose If-None-Match has the ETag a 304.
+func (app *application) writeTodo(w http.ResponseWriter, r *http.Request, id, userID, status int, message string) {
+	todo, err := app.models.Todo.Get(r.Context(), id, userID)
+	if err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", todoETag(todo))
+	if r.Method == http.MethodGet {
+		if etagMatch(r.Header.Get("If-None-Match"), todoETag(todo), false) {
+			w.Header().Set("ETag", todoETag(todo))
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	} else {
+		headers.Set("Location", fmt.Sprintf("/todos/%d", todo.ID))
+	}
+
+	payload := jsonResponse{
+		Error:   false,
+		Message: message,
+		Data:    envelope{"todo": todo},
+	}
+
+	app.writeJSON(w, status, payload, headers)
+}
+
+// todoETag is the strong entity tag of a todo: its version, which every
+// change bumps.
+func todoETag(todo *data.Todo) string {
+	return strconv.Quote(strconv.Itoa(todo.Version))
+}
+
+// etagMatch reports whether header, an If-Match or If-None-Match list of
+// entity tags, has etag or is "*". If-Match compares strongly, so weak tags
+// never match it.
+func etagMatch(header, etag string, strong bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if !strong {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ifMatchVersion checks the If-Match header of a write to a todo, returning
+// the version the write must apply to, or 0 when there is no precondition.
+// When the todo has changed it answers with a 412 and returns false. Handlers
+// call it before reading the body, so a stale write fails the same way
+// whatever it carries.
+func (app *application) ifMatchVersion(w http.ResponseWriter, r *http.Request, id, userID int) (int, bool) {
+	header := r.Header.Get("If-Match")
+	if header == "" || strings.TrimSpace(header) == "*" {
+		return 0, true
+	}
+
+	todo, err := app.models.Todo.Get(r.Context(), id, userID)
+	if err != nil {
+		app.notFoundOrError(w, err)
+		return 0, false
+	}
+	if !etagMatch(header, todoETag(todo), true) {
+		app.writePreconditionFailed(w, todo)
+		return 0, false
+	}
+
+	return todo.Version, true
+}
+
+// preconditionFailed answers a write that lost a race with another change
+// to the todo with a 412 and the current copy.
+func (app *application) preconditionFailed(w http.ResponseWriter, r *http.Request, id, userID int) {
+	todo, err := app.models.Todo.Get(r.Context(), id, userID)
+	if err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	app.writePreconditionFailed(w, todo)
+}
+
+// writePreconditionFailed writes a 412 carrying todo, so the client can
+// merge its change into the current copy and retry with the new ETag.
+func (app *application) writePreconditionFailed(w http.ResponseWriter, todo *data.Todo) {
+	headers := make(http.Header)
+	headers.Set("ETag", todoETag(todo))
+
+	app.writeJSON(w, http.StatusPreconditionFailed, jsonResponse{
+		Error:   true,
+		Message: data.ErrVersionConflict.Error(),
+		Data:    envelope{"todo": todo},
+	}, headers)
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"task-app/db/data"
+	"testing"
+)
+
+// todoResponse is the answer of the handlers writing a todo.
+type todoResponse struct {
+	Data struct {
+		Todo struct {
+			ID         int    `json:"id"`
+			PriorityID int    `json:"priority_id"`
+			Text       string `json:"text"`
+			Completed  bool   `json:"completed"`
+			Version    int    `json:"version"`
+		} `json:"todo"`
+	} `json:"data"`
+}
+
+func decodeTodo(t *testing.T, w *httptest.ResponseRecorder) todoResponse {
+	t.Helper()
+
+	var resp todoResponse
+	decode(t, w, &resp)
+
+	return resp
+}
+
+func TestTodoHandlers(t *testing.T) {
+	handler := newTestApp(t).routes()
+	ada, bob := signUp(t, handler, "ada"), signUp(t, handler, "bob")
+
+	w := do(t, handler, http.MethodPost, "/todos", ada.Token, envelope{"priority_id": 1, "text": "Write tests"})
+	wantStatus(t, "POST /todos", w, http.StatusCreated)
+	created := decodeTodo(t, w).Data.Todo
+	path := fmt.Sprintf("/todos/%d", created.ID)
+	if w.Header().Get("Location") != path || w.Header().Get("ETag") != `"1"` {
+		t.Errorf("POST /todos headers = %v, want Location %s and ETag \"1\"", w.Header(), path)
+	}
+	if created.Text != "Write tests" || created.PriorityID != 1 {
+		t.Errorf("created todo = %+v", created)
+	}
+
+	w = do(t, handler, http.MethodPost, "/todos", ada.Token, envelope{"priority_id": 1})
+	wantStatus(t, "POST /todos without text", w, http.StatusBadRequest)
+	w = do(t, handler, http.MethodGet, "/todos/nope", ada.Token, nil)
+	wantStatus(t, "GET with a bad ID", w, http.StatusBadRequest)
+	w = do(t, handler, http.MethodGet, path, bob.Token, nil)
+	wantStatus(t, "GET of another user's todo", w, http.StatusNotFound)
+	w = do(t, handler, http.MethodPatch, path, bob.Token, envelope{"text": "Mine"})
+	wantStatus(t, "PATCH of another user's todo", w, http.StatusNotFound)
+
+	w = do(t, handler, http.MethodPatch, path, ada.Token, envelope{"text": "Write more tests"})
+	wantStatus(t, "PATCH", w, http.StatusOK)
+	if patched := decodeTodo(t, w).Data.Todo; patched.Text != "Write more tests" || patched.PriorityID != 1 {
+		t.Errorf("patched todo = %+v, want the new text and the priority kept", patched)
+	}
+
+	// PUT replaces every field, so it needs them all
+	w = do(t, handler, http.MethodPut, path, ada.Token, envelope{"priority_id": 3})
+	wantStatus(t, "PUT without text", w, http.StatusBadRequest)
+	w = do(t, handler, http.MethodPut, path, ada.Token, envelope{"priority_id": 3, "text": "Ship it"})
+	wantStatus(t, "PUT", w, http.StatusOK)
+	if replaced := decodeTodo(t, w).Data.Todo; replaced.Text != "Ship it" || replaced.PriorityID != 3 {
+		t.Errorf("replaced todo = %+v", replaced)
+	}
+
+	w = do(t, handler, http.MethodGet, path, ada.Token, nil)
+	wantStatus(t, "GET", w, http.StatusOK)
+	if got := decodeTodo(t, w).Data.Todo; got.Text != "Ship it" {
+		t.Errorf("GET = %+v, want the replaced todo", got)
+	}
+
+	w = do(t, handler, http.MethodPost, path+"/complete", ada.Token, nil)
+	wantStatus(t, "complete", w, http.StatusOK)
+	if completed := decodeTodo(t, w).Data.Todo; !completed.Completed {
+		t.Errorf("completed todo = %+v", completed)
+	}
+	w = do(t, handler, http.MethodPost, path+"/complete", bob.Token, nil)
+	wantStatus(t, "complete another user's todo", w, http.StatusNotFound)
+	w = do(t, handler, http.MethodPost, path+"/uncomplete", ada.Token, nil)
+	wantStatus(t, "uncomplete", w, http.StatusOK)
+	if reopened := decodeTodo(t, w).Data.Todo; reopened.Completed {
+		t.Errorf("reopened todo = %+v", reopened)
+	}
+
+	w = do(t, handler, http.MethodDelete, path, bob.Token, nil)
+	wantStatus(t, "DELETE of another user's todo", w, http.StatusNotFound)
+	// Deleted todos wait in the trash until restored
+	w = do(t, handler, http.MethodDelete, path, ada.Token, nil)
+	wantStatus(t, "DELETE", w, http.StatusOK)
+	w = do(t, handler, http.MethodGet, path, ada.Token, nil)
+	wantStatus(t, "GET of a deleted todo", w, http.StatusNotFound)
+	w = do(t, handler, http.MethodPost, path+"/restore", ada.Token, nil)
+	wantStatus(t, "restore", w, http.StatusOK)
+	w = do(t, handler, http.MethodGet, path, ada.Token, nil)
+	wantStatus(t, "GET of a restored todo", w, http.StatusOK)
+
+	w = do(t, handler, http.MethodGet, path+"/history", ada.Token, nil)
+	wantStatus(t, "GET history", w, http.StatusOK)
+	var history struct {
+		Data struct {
+			Events []struct {
+				Action string `json:"action"`
+			} `json:"events"`
+		} `json:"data"`
+	}
+	decode(t, w, &history)
+	var actions []string
+	for _, event := range history.Data.Events {
+		actions = append(actions, event.Action)
+	}
+	if fmt.Sprint(actions) != "[created updated updated completed uncompleted deleted restored]" {
+		t.Errorf("history = %v", actions)
+	}
+	w = do(t, handler, http.MethodGet, path+"/history", bob.Token, nil)
+	wantStatus(t, "GET history of another user's todo", w, http.StatusNotFound)
+}
+
+func TestLegacyTodoRoutes(t *testing.T) {
+	handler := newTestApp(t).routes()
+	ada := signUp(t, handler, "ada")
+
+	// wantDeprecated fails the test unless w points to the route replacing
+	// the one it answered
+	wantDeprecated := func(what string, w *httptest.ResponseRecorder) {
+		t.Helper()
+
+		if got := w.Header().Get("Deprecation"); got != "true" {
+			t.Errorf("%s Deprecation = %q, want \"true\"", what, got)
+		}
+		if got, want := w.Header().Get("Link"), `</todos>; rel="successor-version"`; got != want {
+			t.Errorf("%s Link = %q, want %q", what, got, want)
+		}
+	}
+
+	type legacyTodo struct {
+		ID    int      `json:"id"`
+		Text  string   `json:"text"`
+		Tags  []string `json:"tags"`
+		DueAt *string  `json:"due_at"`
+	}
+	all := func() []legacyTodo {
+		t.Helper()
+
+		w := do(t, handler, http.MethodGet, "/todo/", ada.Token, nil)
+		wantStatus(t, "GET /todo", w, http.StatusOK)
+		wantDeprecated("GET /todo", w)
+		var resp struct {
+			Data struct {
+				Todos []legacyTodo `json:"todos"`
+			} `json:"data"`
+		}
+		decode(t, w, &resp)
+		return resp.Data.Todos
+	}
+
+	w := do(t, handler, http.MethodPost, "/todo/save", "", envelope{"priority_id": 1, "text": "Legacy"})
+	wantStatus(t, "POST /todo/save without a token", w, http.StatusUnauthorized)
+
+	w = do(t, handler, http.MethodPost, "/todo/save", ada.Token, envelope{"priority_id": 1, "text": "Legacy", "tags": []string{"old"}})
+	wantStatus(t, "POST /todo/save", w, http.StatusAccepted)
+	wantDeprecated("POST /todo/save", w)
+	todos := all()
+	if len(todos) != 1 || todos[0].Text != "Legacy" {
+		t.Fatalf("GET /todo = %+v, want the saved todo", todos)
+	}
+	id := todos[0].ID
+
+	// Saving with an ID updates the todo, keeping what the legacy payload
+	// doesn't carry
+	path := fmt.Sprintf("/todos/%d", id)
+	w = do(t, handler, http.MethodPatch, path, ada.Token, envelope{"due_at": "2030-01-02T09:00:00Z"})
+	wantStatus(t, "PATCH the due date", w, http.StatusOK)
+	if w.Header().Get("Deprecation") != "" {
+		t.Error("PATCH /todos/{id} answered with a Deprecation header")
+	}
+	w = do(t, handler, http.MethodPost, "/todo/save", ada.Token, envelope{"id": id, "priority_id": 2, "text": "Renamed"})
+	wantStatus(t, "POST /todo/save of an existing todo", w, http.StatusAccepted)
+	if todos := all(); len(todos) != 1 || todos[0].Text != "Renamed" || todos[0].DueAt == nil || !slices.Equal(todos[0].Tags, []string{"old"}) {
+		t.Fatalf("GET /todo after the update = %+v, want it renamed with its due date and tags", todos)
+	}
+
+	w = do(t, handler, http.MethodPost, "/todo/delete", ada.Token, envelope{"id": id})
+	wantStatus(t, "POST /todo/delete", w, http.StatusOK)
+	wantDeprecated("POST /todo/delete", w)
+	w = do(t, handler, http.MethodGet, path, ada.Token, nil)
+	wantStatus(t, "GET after POST /todo/delete", w, http.StatusNotFound)
+	if todos := all(); len(todos) != 0 {
+		t.Errorf("GET /todo after the delete = %+v, want none", todos)
+	}
+}
+
+// racingTodos is a TodoRepository where another change to a todo always
+// lands between a handler reading it and writing it back.
+type racingTodos struct {
+	data.TodoRepository
+}
+
+func (r racingTodos) Update(ctx context.Context, todo *data.Todo) error {
+	current, err := r.Get(ctx, todo.ID, todo.UserID)
+	if err != nil {
+		return err
+	}
+	current.Text += " (edited elsewhere)"
+	if err := r.TodoRepository.Update(ctx, current); err != nil {
+		return err
+	}
+
+	return r.TodoRepository.Update(ctx, todo)
+}
+
+func TestTodoPreconditions(t *testing.T) {
+	app := newTestApp(t)
+	handler := app.routes()
+	ada := signUp(t, handler, "ada")
+
+	w := do(t, handler, http.MethodPost, "/todos", ada.Token, envelope{"priority_id": 1, "text": "Draft"})
+	wantStatus(t, "POST /todos", w, http.StatusCreated)
+	path := fmt.Sprintf("/todos/%d", decodeTodo(t, w).Data.Todo.ID)
+
+	withHeader := func(method, header, value string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+		r := newRequest(t, method, path, ada.Token, body)
+		r.Header.Set(header, value)
+		return serve(handler, r)
+	}
+
+	// Reads compare weakly, so W/"1" matches the ETag "1"
+	for _, value := range []string{`"1"`, `W/"1"`, `"7", "1"`, "*"} {
+		w := withHeader(http.MethodGet, "If-None-Match", value, nil)
+		wantStatus(t, "GET with If-None-Match "+value, w, http.StatusNotModified)
+		if w.Header().Get("ETag") != `"1"` || w.Body.Len() != 0 {
+			t.Errorf("304 for If-None-Match %s has ETag %q and a %d byte body", value, w.Header().Get("ETag"), w.Body.Len())
+		}
+	}
+	wantStatus(t, "GET with another If-None-Match", withHeader(http.MethodGet, "If-None-Match", `"2"`, nil), http.StatusOK)
+
+	// Writes compare strongly: a weak tag never matches
+	for _, value := range []string{`W/"1"`, `"2"`} {
+		w := withHeader(http.MethodPatch, "If-Match", value, envelope{"text": "Lost"})
+		wantStatus(t, "PATCH with If-Match "+value, w, http.StatusPreconditionFailed)
+		if current := decodeTodo(t, w).Data.Todo; current.Text != "Draft" || w.Header().Get("ETag") != `"1"` {
+			t.Errorf("412 answer = %+v, ETag %s; want the current copy", current, w.Header().Get("ETag"))
+		}
+	}
+	w = withHeader(http.MethodDelete, "If-Match", `"2"`, nil)
+	wantStatus(t, "DELETE with a stale If-Match", w, http.StatusPreconditionFailed)
+
+	w = withHeader(http.MethodPatch, "If-Match", `"7", "1"`, envelope{"text": "Second draft"})
+	wantStatus(t, "PATCH with a matching If-Match", w, http.StatusOK)
+	if patched := decodeTodo(t, w).Data.Todo; patched.Version != 2 || w.Header().Get("ETag") != `"2"` {
+		t.Errorf("patched todo = %+v, ETag %s; want version 2", patched, w.Header().Get("ETag"))
+	}
+
+	// "*" only asks for the todo to exist
+	w = withHeader(http.MethodPut, "If-Match", "*", envelope{"priority_id": 1, "text": "Final"})
+	wantStatus(t, "PUT with If-Match *", w, http.StatusOK)
+	if replaced := decodeTodo(t, w).Data.Todo; replaced.Text != "Final" || replaced.Version != 3 {
+		t.Errorf("replaced todo = %+v, want version 3", replaced)
+	}
+
+	// A change landing after the If-Match check still fails the write
+	app.models.Todo = racingTodos{app.models.Todo}
+	w = withHeader(http.MethodPatch, "If-Match", `"3"`, envelope{"text": "Too late"})
+	wantStatus(t, "PATCH losing a race", w, http.StatusPreconditionFailed)
+	if current := decodeTodo(t, w).Data.Todo; current.Text != "Final (edited elsewhere)" || w.Header().Get("ETag") != `"4"` {
+		t.Errorf("412 answer = %+v, ETag %s; want the concurrent change", current, w.Header().Get("ETag"))
+	}
+}
+
+func TestUndoTodo(t *testing.T) {
+	handler := newTestApp(t).routes()
+	ada := signUp(t, handler, "ada")
+
+	w := do(t, handler, http.MethodPost, "/todos/undo", ada.Token, nil)
+	wantStatus(t, "undo with nothing done", w, http.StatusConflict)
+
+	w = do(t, handler, http.MethodPost, "/todos", ada.Token, envelope{"priority_id": 1, "text": "Draft"})
+	wantStatus(t, "POST /todos", w, http.StatusCreated)
+	path := fmt.Sprintf("/todos/%d", decodeTodo(t, w).Data.Todo.ID)
+	w = do(t, handler, http.MethodPatch, path, ada.Token, envelope{"text": "Final"})
+	wantStatus(t, "PATCH", w, http.StatusOK)
+
+	w = do(t, handler, http.MethodPost, "/todos/undo", ada.Token, nil)
+	wantStatus(t, "undo", w, http.StatusOK)
+	if undone := decodeTodo(t, w).Data.Todo; undone.Text != "Draft" {
+		t.Errorf("undone todo = %+v, want the text before the edit", undone)
+	}
+
+	w = do(t, handler, http.MethodPost, "/todos/redo", ada.Token, nil)
+	wantStatus(t, "redo", w, http.StatusOK)
+	if redone := decodeTodo(t, w).Data.Todo; redone.Text != "Final" {
+		t.Errorf("redone todo = %+v, want the edit back", redone)
+	}
+	w = do(t, handler, http.MethodPost, "/todos/redo", ada.Token, nil)
+	wantStatus(t, "second redo", w, http.StatusConflict)
+}
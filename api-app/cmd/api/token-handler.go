@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"task-app/db/data"
+	"time"
+)
+
+// refreshCookieName is the cookie refresh tokens travel in with
+// -refresh-cookie. Its path covers the refresh and logout endpoints.
+const (
+	refreshCookieName = "refresh_token"
+	refreshCookiePath = "/users"
+)
+
+// RefreshToken handles POST /users/token/refresh, exchanging a refresh token,
+// from the refresh_token cookie or field, for a new access token and the next
+// refresh token. Each refresh token works once: replaying one signs out its
+// session.
+func (app *application) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var requestPayload struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	// Cookie clients may send no body at all
+	if _, err := r.Cookie(refreshCookieName); err != nil {
+		if err := app.readJSON(w, r, &requestPayload); err != nil {
+			app.errorJSON(w, err)
+			return
+		}
+	}
+
+	refreshToken := readRefreshToken(r, requestPayload.RefreshToken)
+	if refreshToken == "" {
+		app.errorJSON(w, errors.New("a refresh token is required"))
+		return
+	}
+
+	next, stored, err := app.models.RefreshToken.Rotate(r.Context(), refreshToken, app.config.refreshTokenTTL)
+	if err != nil {
+		// A replayed token signed its session out
+		if errors.Is(err, data.ErrTokenReused) {
+			app.auth.clear()
+		}
+		app.clearRefreshCookie(w)
+		app.errorJSON(w, err)
+		return
+	}
+
+	user, err := app.models.User.GetByID(r.Context(), stored.UserID)
+	if err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	tokens, err := app.issueTokens(w, user, stored.SessionID, next)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, jsonResponse{
+		Error:   false,
+		Message: "Token has been refreshed.",
+		Data:    tokens,
+	})
+}
+
+// startSession signs user in on a new session, recording the client of r,
+// and returns the session's first tokens as issueTokens does.
+func (app *application) startSession(w http.ResponseWriter, r *http.Request, user *data.User) (envelope, error) {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	session := &data.Session{
+		UserID:    user.ID,
+		UserAgent: r.UserAgent(),
+		IP:        ip,
+		ExpiresAt: time.Now().Add(app.config.refreshTokenTTL),
+	}
+	if err := app.models.Session.Insert(r.Context(), session); err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := app.models.RefreshToken.Issue(r.Context(), user.ID, session.ID, app.config.refreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.issueTokens(w, user, session.ID, refreshToken)
+}
+
+// issueTokens signs an access token for user within a session and returns it
+// with refreshToken, ready for the response. With -refresh-cookie the refresh
+// token is set as a cookie on w instead.
+func (app *application) issueTokens(w http.ResponseWriter, user *data.User, sessionID int, refreshToken string) (envelope, error) {
+	expiresAt := time.Now().Add(app.config.accessTokenTTL)
+	token, err := app.keys.GenerateToken(user.Email, int64(user.ID), int64(sessionID), app.config.accessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := envelope{"token": token, "expires_at": expiresAt.UTC()}
+	if !app.config.refreshCookie {
+		tokens["refresh_token"] = refreshToken
+		return tokens, nil
+	}
+
+	http.SetCookie(w, app.refreshCookie(refreshToken, int(app.config.refreshTokenTTL.Seconds())))
+
+	return tokens, nil
+}
+
+// clearRefreshCookie removes the refresh token cookie, if there is one.
+func (app *application) clearRefreshCookie(w http.ResponseWriter) {
+	if !app.config.refreshCookie {
+		return
+	}
+
+	http.SetCookie(w, app.refreshCookie("", -1))
+}
+
+// refreshCookie returns the refresh token cookie holding value for maxAge
+// seconds, a negative maxAge deleting it. It is Secure unless
+// -refresh-cookie-secure is turned off.
+func (app *application) refreshCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     refreshCookieName,
+		Value:    value,
+		Path:     refreshCookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   app.config.refreshCookieSecure,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+// readRefreshToken returns the refresh token of the request: the cookie's,
+// or else the one from the body.
+func readRefreshToken(r *http.Request, fromBody string) string {
+	if cookie, err := r.Cookie(refreshCookieName); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+
+	return fromBody
+}
+
+// JWKS handles GET /.well-known/jwks.json, publishing the public keys tokens
+// may be signed with, so other services can check them.
+func (app *application) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	app.writeJSON(w, http.StatusOK, envelope{"keys": app.keys.JWKS()})
+}
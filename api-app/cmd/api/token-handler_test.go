@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRefreshToken(t *testing.T) {
+	handler := newTestApp(t).routes()
+	session := signUp(t, handler, "ada")
+
+	w := do(t, handler, http.MethodPost, "/users/token/refresh", "", envelope{"refresh_token": session.RefreshToken})
+	wantStatus(t, "refresh", w, http.StatusOK)
+	var resp struct {
+		Data tokens `json:"data"`
+	}
+	decode(t, w, &resp)
+	next := resp.Data
+	if next.Token == "" || next.RefreshToken == "" || next.RefreshToken == session.RefreshToken {
+		t.Fatalf("refresh = %+v, want new tokens", next)
+	}
+	w = do(t, handler, http.MethodGet, "/lists", next.Token, nil)
+	wantStatus(t, "GET /lists with the new token", w, http.StatusOK)
+
+	w = do(t, handler, http.MethodPost, "/users/token/refresh", "", envelope{})
+	wantStatus(t, "refresh without a token", w, http.StatusBadRequest)
+
+	// Replaying the used refresh token signs the whole session out
+	w = do(t, handler, http.MethodPost, "/users/token/refresh", "", envelope{"refresh_token": session.RefreshToken})
+	wantStatus(t, "refresh with a used token", w, http.StatusUnauthorized)
+	w = do(t, handler, http.MethodPost, "/users/token/refresh", "", envelope{"refresh_token": next.RefreshToken})
+	wantStatus(t, "refresh after a replay", w, http.StatusUnauthorized)
+	w = do(t, handler, http.MethodGet, "/lists", next.Token, nil)
+	wantStatus(t, "GET /lists after a replay", w, http.StatusUnauthorized)
+}
+
+func TestRefreshCookie(t *testing.T) {
+	for _, secure := range []bool{true, false} {
+		app := newTestApp(t)
+		app.config.refreshCookie, app.config.refreshCookieSecure = true, secure
+		handler := app.routes()
+		signUp(t, handler, "ada")
+
+		// cookie returns the refresh token cookie w sets, checking its flags
+		cookie := func(what string, w *httptest.ResponseRecorder) *http.Cookie {
+			t.Helper()
+
+			for _, c := range w.Result().Cookies() {
+				if c.Name != refreshCookieName {
+					continue
+				}
+				if c.Secure != secure || !c.HttpOnly || c.SameSite != http.SameSiteStrictMode || c.Path != refreshCookiePath {
+					t.Errorf("%s cookie = %+v, want Secure %v, HttpOnly, SameSite=Strict on %s", what, c, secure, refreshCookiePath)
+				}
+				return c
+			}
+			t.Fatalf("%s set no %s cookie", what, refreshCookieName)
+			return nil
+		}
+
+		w := do(t, handler, http.MethodPost, "/users/login", "", envelope{"email": "ada@example.com", "password": "password"})
+		wantStatus(t, "login", w, http.StatusOK)
+		var resp struct {
+			Data tokens `json:"data"`
+		}
+		decode(t, w, &resp)
+		if resp.Data.RefreshToken != "" {
+			t.Errorf("login answered the refresh token %q in the body, want it only in the cookie", resp.Data.RefreshToken)
+		}
+		refresh := cookie("login", w)
+
+		// Cookie clients refresh without a body
+		r := httptest.NewRequest(http.MethodPost, "/users/token/refresh", nil)
+		r.AddCookie(refresh)
+		w = serve(handler, r)
+		wantStatus(t, "refresh with the cookie", w, http.StatusOK)
+		if next := cookie("refresh", w); next.Value == "" || next.Value == refresh.Value {
+			t.Errorf("refresh cookie = %q, want the next refresh token", next.Value)
+		} else {
+			refresh = next
+		}
+
+		r = newRequest(t, http.MethodPost, "/users/logout", resp.Data.Token, nil)
+		r.AddCookie(refresh)
+		w = serve(handler, r)
+		wantStatus(t, "logout", w, http.StatusOK)
+		if cleared := cookie("logout", w); cleared.Value != "" || cleared.MaxAge >= 0 {
+			t.Errorf("logout cookie = %+v, want it deleted", cleared)
+		}
+	}
+}
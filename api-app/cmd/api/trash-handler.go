@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"task-app/db/data"
+)
+
+// TrashTodos handles GET /todos/trash, listing the deleted todos with the same
+// query parameters as GET /todos. Todos of archived lists are included.
+func (app *application) TrashTodos(w http.ResponseWriter, r *http.Request) {
+	app.pagedTodos(w, r, defaultTodoPageSize, func(filter *data.TodoFilter) {
+		filter.Trashed = true
+		filter.IncludeArchived = true
+	})
+}
+
+// RestoreTodo handles POST /todos/{id}/restore, taking a todo out of the
+// trash with its tags and checklist.
+func (app *application) RestoreTodo(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := readIDParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	if err := app.models.Todo.Restore(r.Context(), id, userID); err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	app.writeTodo(w, r, id, userID, http.StatusOK, "Todo has been successfully restored.")
+}
+
+// PurgeTodo handles DELETE /todos/trash/{id}, deleting a todo in the trash
+// for good.
+func (app *application) PurgeTodo(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := readIDParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	if err := app.models.Todo.Purge(r.Context(), id, userID); err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, jsonResponse{
+		Error:   false,
+		Message: "Todo has been permanently deleted.",
+	})
+}
+
+// EmptyTrash handles DELETE /todos/trash, deleting every todo in the trash
+// for good.
+func (app *application) EmptyTrash(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.authUserID(w, r)
+	if !ok {
+		return
+	}
+
+	purged, err := app.models.Todo.EmptyTrash(r.Context(), userID)
+	if err != nil {
+		app.notFoundOrError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, jsonResponse{
+		Error:   false,
+		Message: "Trash has been emptied.",
+		Data:    envelope{"purged_todos": purged},
+	})
+}
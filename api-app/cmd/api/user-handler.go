@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"io"
 	"net/http"
 	"task-app/db/data"
 	"task-app/utils"
@@ -15,9 +17,10 @@ type ValidationErrors struct {
 }
 
 func (app *application) AllUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := app.models.User.GetAll()
+	users, err := app.models.User.GetAll(r.Context())
 	if err != nil {
 		app.errorLog.Println(err)
+		app.errorJSON(w, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -38,7 +41,7 @@ func (app *application) RegisterUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = validateRegisterUserInputs(app, &user)
+	err = validateRegisterUserInputs(r.Context(), app, &user)
 	if err != nil {
 		if validationErr, ok := err.(*ValidationError); ok {
 			// Map validation errors as needed
@@ -47,7 +50,7 @@ func (app *application) RegisterUser(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	_, err = app.models.User.Insert(user)
+	_, err = app.models.User.Insert(r.Context(), user)
 	if err != nil {
 		app.errorJSON(w, err)
 		return
@@ -90,7 +93,11 @@ func (app *application) LoginUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	
-	user, err := app.models.User.GetByEmail(creds.Email)
+	user, err := app.models.User.GetByEmail(r.Context(), creds.Email)
+	if isContextError(err) {
+		app.errorJSON(w, err)
+		return
+	}
 	if err != nil {
 		payload.Error = true
 		payload.Message = "Authentication failed."
@@ -108,40 +115,67 @@ func (app *application) LoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := utils.GenerateToken(user.Email, int64(user.ID))
+	tokens, err := app.startSession(w, r, user)
 	if err != nil {
 		app.errorJSON(w, err)
 		return
 	}
+	tokens["user"] = user
 
 	payload = jsonResponse{
 		Error:   false,
 		Message: "Welcome! It's great to see you again!",
-		Data: envelope{"user": user, "token": token},
+		Data: tokens,
 	}
 
 	app.writeJSON(w, http.StatusOK, payload)
 }
 
 func (app *application) LogoutUser(w http.ResponseWriter, r *http.Request) {
-		// Extract the userID from the request context (set by Authenticate middleware)
-		_, ok := r.Context().Value(userIDKey).(int64)
+		// Extract the token claims from the request context (set by Authenticate middleware)
+		claims, ok := r.Context().Value(claimsKey).(*utils.Claims)
 		if !ok {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 	var requestPayload struct {
-		Token string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
 
+	// The body is optional, only needed for a refresh token not in a cookie
 	err := app.readJSON(w, r, &requestPayload)
-	if err != nil {
+	if err != nil && !errors.Is(err, io.EOF) {
 		app.errorJSONWithData(w, errors.New("invalid json"), requestPayload)
 		
 		return
 	}
 
-	utils.InvalidateToken(requestPayload.Token)
+	// Revoke the bearer token itself until it expires
+	err = app.models.Revocation.Revoke(r.Context(), claims.ID, claims.ExpiresAt)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	// End the session, so its refresh tokens can't mint new access tokens
+	err = app.models.Session.Revoke(r.Context(), int(claims.SessionID), int(claims.UserID))
+	if err != nil && !errors.Is(err, data.ErrNotFound) {
+		app.errorJSON(w, err)
+		return
+	}
+
+	// A refresh token from another of the user's sessions is signed out as
+	// well; someone else's token is left alone
+	refreshToken := readRefreshToken(r, requestPayload.RefreshToken)
+	if refreshToken != "" {
+		err = app.models.RefreshToken.Revoke(r.Context(), refreshToken, int(claims.UserID))
+		if err != nil && !errors.Is(err, data.ErrTokenInvalid) {
+			app.errorJSON(w, err)
+			return
+		}
+	}
+	app.auth.clear()
+	app.clearRefreshCookie(w)
 
 	payload := jsonResponse{
 		Error:   false,
@@ -151,7 +185,7 @@ func (app *application) LogoutUser(w http.ResponseWriter, r *http.Request) {
 	app.writeJSON(w, http.StatusOK, payload)
 }
 
-func validateRegisterUserInputs(app *application, user *data.User) error {
+func validateRegisterUserInputs(ctx context.Context, app *application, user *data.User) error {
 	var userValidationErrors = map[string]string{}
 
 	// Check for empty fields and add error messages to map
@@ -162,7 +196,7 @@ func validateRegisterUserInputs(app *application, user *data.User) error {
 
 	// Check if email already exists
 	if user.Email != "" {
-		emailExists, err := app.models.User.EmailExists(user.Email)
+		emailExists, err := app.models.User.EmailExists(ctx, user.Email)
 		if err != nil {
 			userValidationErrors["email"] = "An error occurred while checking email availability. Please try again later."
 		} else if emailExists {
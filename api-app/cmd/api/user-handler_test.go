@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRegisterUser(t *testing.T) {
+	handler := newTestApp(t).routes()
+	signUp(t, handler, "ada")
+
+	tests := []struct {
+		name  string
+		body  envelope
+		field string
+	}{
+		{"taken email", envelope{"name": "Ada", "email": "ada@example.com", "password": "x", "confirm_password": "x"}, "email"},
+		{"no name", envelope{"email": "bob@example.com", "password": "x", "confirm_password": "x"}, "name"},
+		{"passwords differ", envelope{"name": "Bob", "email": "bob@example.com", "password": "x", "confirm_password": "y"}, "confirm_password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(t, handler, http.MethodPost, "/users/register", "", tt.body)
+			wantStatus(t, "register", w, http.StatusBadRequest)
+
+			var resp struct {
+				Data struct {
+					Errors map[string]string `json:"errors"`
+				} `json:"data"`
+			}
+			decode(t, w, &resp)
+			if resp.Data.Errors[tt.field] == "" {
+				t.Errorf("errors = %v, want one for %s", resp.Data.Errors, tt.field)
+			}
+		})
+	}
+}
+
+func TestLoginUser(t *testing.T) {
+	handler := newTestApp(t).routes()
+	session := signUp(t, handler, "ada")
+
+	for name, body := range map[string]envelope{
+		"wrong password": {"email": "ada@example.com", "password": "wrong"},
+		"unknown email":  {"email": "bob@example.com", "password": "password"},
+		"no password":    {"email": "ada@example.com"},
+	} {
+		w := do(t, handler, http.MethodPost, "/users/login", "", body)
+		wantStatus(t, "login with "+name, w, http.StatusBadRequest)
+	}
+
+	// New users start with an Inbox, which needs the token to see
+	w := do(t, handler, http.MethodGet, "/lists", session.Token, nil)
+	wantStatus(t, "GET /lists", w, http.StatusOK)
+	var resp struct {
+		Data struct {
+			Lists []struct {
+				Inbox bool `json:"inbox"`
+			} `json:"lists"`
+		} `json:"data"`
+	}
+	decode(t, w, &resp)
+	if len(resp.Data.Lists) != 1 || !resp.Data.Lists[0].Inbox {
+		t.Errorf("lists = %+v, want the Inbox", resp.Data.Lists)
+	}
+
+	for name, token := range map[string]string{"no token": "", "bad token": "not-a-token"} {
+		w := do(t, handler, http.MethodGet, "/lists", token, nil)
+		wantStatus(t, "GET /lists with "+name, w, http.StatusUnauthorized)
+	}
+}
+
+func TestLogoutUser(t *testing.T) {
+	handler := newTestApp(t).routes()
+	session := signUp(t, handler, "ada")
+	other := logIn(t, handler, "ada", "password")
+
+	w := do(t, handler, http.MethodPost, "/users/logout", session.Token, nil)
+	wantStatus(t, "logout", w, http.StatusOK)
+
+	// The access token is revoked and the session's refresh token with it
+	w = do(t, handler, http.MethodGet, "/lists", session.Token, nil)
+	wantStatus(t, "GET /lists after logout", w, http.StatusUnauthorized)
+	w = do(t, handler, http.MethodPost, "/users/token/refresh", "", envelope{"refresh_token": session.RefreshToken})
+	wantStatus(t, "refresh after logout", w, http.StatusUnauthorized)
+
+	// Other sessions stay signed in
+	w = do(t, handler, http.MethodGet, "/lists", other.Token, nil)
+	wantStatus(t, "GET /lists on another session", w, http.StatusOK)
+
+	// Someone else's refresh token is left alone
+	bob := signUp(t, handler, "bob")
+	w = do(t, handler, http.MethodPost, "/users/logout", bob.Token, envelope{"refresh_token": other.RefreshToken})
+	wantStatus(t, "logout with another user's refresh token", w, http.StatusOK)
+	w = do(t, handler, http.MethodGet, "/lists", other.Token, nil)
+	wantStatus(t, "GET /lists after another user's logout", w, http.StatusOK)
+
+	// The user's own refresh token of another session is signed out with them
+	third := logIn(t, handler, "ada", "password")
+	w = do(t, handler, http.MethodPost, "/users/logout", third.Token, envelope{"refresh_token": other.RefreshToken})
+	wantStatus(t, "logout with a refresh token of another session", w, http.StatusOK)
+	w = do(t, handler, http.MethodGet, "/lists", other.Token, nil)
+	wantStatus(t, "GET /lists on the session signed out", w, http.StatusUnauthorized)
+}
@@ -0,0 +1,321 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrChecklistOrder is returned by Reorder when the IDs aren't exactly the todo's items
+var ErrChecklistOrder = errors.New("the order must list every checklist item of the todo once")
+
+// ChecklistItem is one step of a todo.
+type ChecklistItem struct {
+	ID     int    `json:"id,omitempty"`
+	TodoID int    `json:"todo_id,omitempty"`
+	Text   string `json:"text,omitempty"`
+	Done   bool   `json:"done"`
+	// Position orders a todo's items, lowest first
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// ChecklistRepository stores the checklist items of todos. Every method
+// takes the todo owner's ID, and changing an item bumps the todo's
+// updated_at. Items go away with their todo.
+type ChecklistRepository interface {
+	// GetAll returns the items of one of the user's todos, by position
+	GetAll(ctx context.Context, todoID, userID int) ([]ChecklistItem, error)
+	Get(ctx context.Context, ID, todoID, userID int) (*ChecklistItem, error)
+	// Insert adds an item after the todo's others
+	Insert(ctx context.Context, item *ChecklistItem, userID int) error
+	// Update changes an item's text and done flag
+	Update(ctx context.Context, item *ChecklistItem, userID int) error
+	// Reorder positions the todo's items in the order of itemIDs, which must
+	// list each of them once
+	Reorder(ctx context.Context, todoID, userID int, itemIDs []int) error
+	Delete(ctx context.Context, ID, todoID, userID int) error
+}
+
+// checklistSelect loads items in the column order scanChecklistItem expects
+const checklistSelect = "SELECT id, todo_id, text, done, position, created_at, updated_at FROM checklist_items"
+
+func scanChecklistItem(row rowScanner, item *ChecklistItem) error {
+	return row.Scan(&item.ID, &item.TodoID, &item.Text, &item.Done, &item.Position, &item.CreatedAt, &item.UpdatedAt)
+}
+
+// checklistProgress is the share of done items as a whole percentage, 0 for
+// an empty checklist.
+func checklistProgress(items []ChecklistItem) int {
+	if len(items) == 0 {
+		return 0
+	}
+
+	done := 0
+	for _, item := range items {
+		if item.Done {
+			done++
+		}
+	}
+
+	return done * 100 / len(items)
+}
+
+// SQLChecklistRepository is the database-backed ChecklistRepository.
+type SQLChecklistRepository struct {
+	sqlStore
+}
+
+func (r *SQLChecklistRepository) GetAll(ctx context.Context, todoID, userID int) (items []ChecklistItem, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	var found int
+	err = r.db.QueryRowContext(ctx, r.rebind("SELECT 1 FROM todos WHERE id = ? AND user_id = ? AND deleted_at IS NULL"), todoID, userID).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	todos := []Todo{{ID: todoID}}
+	if err = r.loadChecklists(ctx, r.db, todos); err != nil {
+		return nil, err
+	}
+
+	return todos[0].Checklist, nil
+}
+
+func (r *SQLChecklistRepository) Get(ctx context.Context, ID, todoID, userID int) (_ *ChecklistItem, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	query := r.rebind(checklistSelect + " WHERE id = ? AND todo_id = ? AND EXISTS (SELECT 1 FROM todos WHERE id = ? AND user_id = ? AND deleted_at IS NULL)")
+
+	var item ChecklistItem
+	err = scanChecklistItem(r.db.QueryRowContext(ctx, query, ID, todoID, todoID, userID), &item)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+func (r *SQLChecklistRepository) Insert(ctx context.Context, item *ChecklistItem, userID int) (err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	now := timeNow()
+	if err = r.touchTodo(ctx, tx, item.TodoID, userID, now); err != nil {
+		return err
+	}
+
+	query := r.rebind(`
+        INSERT INTO checklist_items (todo_id, text, done, position, created_at, updated_at)
+        VALUES (?, ?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM checklist_items WHERE todo_id = ?), ?, ?)
+        RETURNING id, position`)
+	err = tx.QueryRowContext(ctx, query, item.TodoID, item.Text, item.Done, item.TodoID, now, now).Scan(&item.ID, &item.Position)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	item.CreatedAt, item.UpdatedAt = now, now
+
+	return nil
+}
+
+func (r *SQLChecklistRepository) Update(ctx context.Context, item *ChecklistItem, userID int) (err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	now := timeNow()
+	if err = r.touchTodo(ctx, tx, item.TodoID, userID, now); err != nil {
+		return err
+	}
+
+	query := r.rebind("UPDATE checklist_items SET text = ?, done = ?, updated_at = ? WHERE id = ? AND todo_id = ?")
+	result, err := tx.ExecContext(ctx, query, item.Text, item.Done, now, item.ID, item.TodoID)
+	if err != nil {
+		return err
+	}
+	if err = expectRows(result); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	item.UpdatedAt = now
+
+	return nil
+}
+
+func (r *SQLChecklistRepository) Reorder(ctx context.Context, todoID, userID int, itemIDs []int) (err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	now := timeNow()
+	if err = r.touchTodo(ctx, tx, todoID, userID, now); err != nil {
+		return err
+	}
+
+	var count int
+	err = tx.QueryRowContext(ctx, r.rebind("SELECT COUNT(*) FROM checklist_items WHERE todo_id = ?"), todoID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count != len(itemIDs) || !distinct(itemIDs) {
+		return ErrChecklistOrder
+	}
+
+	query := r.rebind("UPDATE checklist_items SET position = ?, updated_at = ? WHERE id = ? AND todo_id = ?")
+	for i, id := range itemIDs {
+		result, err := tx.ExecContext(ctx, query, i+1, now, id, todoID)
+		if err != nil {
+			return err
+		}
+		// An ID from another todo matches nothing
+		if err = expectRows(result); errors.Is(err, ErrNotFound) {
+			return ErrChecklistOrder
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLChecklistRepository) Delete(ctx context.Context, ID, todoID, userID int) (err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	if err = r.touchTodo(ctx, tx, todoID, userID, timeNow()); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, r.rebind("DELETE FROM checklist_items WHERE id = ? AND todo_id = ?"), ID, todoID)
+	if err != nil {
+		return err
+	}
+	if err = expectRows(result); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// touchTodo bumps the updated_at of one of the user's todos, failing with
+// ErrNotFound when the todo isn't theirs or is in the trash.
+func (s sqlStore) touchTodo(ctx context.Context, db dbtx, todoID, userID int, now time.Time) error {
+	result, err := db.ExecContext(ctx, s.rebind("UPDATE todos SET updated_at = ?, version = version + 1 WHERE id = ? AND user_id = ? AND deleted_at IS NULL"), now, todoID, userID)
+	if err != nil {
+		return err
+	}
+
+	return expectRows(result)
+}
+
+// loadChecklists fills in the Checklist and Progress of todos, batched like
+// loadTags.
+func (s sqlStore) loadChecklists(ctx context.Context, db dbtx, todos []Todo) error {
+	index := make(map[int]int, len(todos))
+	for i := range todos {
+		todos[i].Checklist = []ChecklistItem{}
+		index[todos[i].ID] = i
+	}
+
+	for start := 0; start < len(todos); start += tagBatchSize {
+		batch := todos[start:min(start+tagBatchSize, len(todos))]
+
+		args := make([]any, len(batch))
+		for i, todo := range batch {
+			args[i] = todo.ID
+		}
+		query := s.rebind(checklistSelect + " WHERE todo_id IN (" + placeholders(len(batch)) + ") ORDER BY position, id")
+
+		if err := func() error {
+			rows, err := db.QueryContext(ctx, query, args...)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+				var item ChecklistItem
+				if err := scanChecklistItem(rows, &item); err != nil {
+					return err
+				}
+				todo := &todos[index[item.TodoID]]
+				todo.Checklist = append(todo.Checklist, item)
+			}
+
+			return rows.Err()
+		}(); err != nil {
+			return err
+		}
+	}
+
+	for i := range todos {
+		todos[i].Progress = checklistProgress(todos[i].Checklist)
+	}
+
+	return nil
+}
+
+// distinct reports whether no ID appears twice.
+func distinct(ids []int) bool {
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return false
+		}
+		seen[id] = true
+	}
+
+	return true
+}
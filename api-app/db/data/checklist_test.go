@@ -0,0 +1,70 @@
+package data_test
+
+import (
+	"context"
+	"task-app/db/data"
+	"testing"
+)
+
+func TestChecklistRepository(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		ada, bob := newUser(t, models, "ada"), newUser(t, models, "bob")
+		todo := newTodo(t, models, ada, "Pack")
+
+		var items []*data.ChecklistItem
+		for _, text := range []string{"Passport", "Tickets", "Charger"} {
+			item := &data.ChecklistItem{TodoID: todo.ID, Text: text}
+			if err := models.Checklist.Insert(ctx, item, ada); err != nil {
+				t.Fatalf("Insert(%q) error = %v", text, err)
+			}
+			items = append(items, item)
+		}
+		err := models.Checklist.Insert(ctx, &data.ChecklistItem{TodoID: todo.ID, Text: "Theirs"}, bob)
+		wantErr(t, "Insert() into another user's todo", err, data.ErrNotFound)
+
+		items[0].Done = true
+		if err := models.Checklist.Update(ctx, items[0], ada); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		got, err := models.Checklist.Get(ctx, items[0].ID, todo.ID, ada)
+		if err != nil || !got.Done || got.Text != "Passport" {
+			t.Fatalf("Get() after Update() = %+v, %v", got, err)
+		}
+		_, err = models.Checklist.Get(ctx, items[0].ID, todo.ID, bob)
+		wantErr(t, "Get() of another user's item", err, data.ErrNotFound)
+
+		// The todo carries its checklist and how much of it is done
+		loaded, err := models.Todo.Get(ctx, todo.ID, ada)
+		if err != nil || len(loaded.Checklist) != 3 || loaded.Progress != 33 {
+			t.Fatalf("Todo.Get() = %d items at %d%%, %v; want 3 at 33%%", len(loaded.Checklist), loaded.Progress, err)
+		}
+
+		order := []int{items[2].ID, items[0].ID, items[1].ID}
+		if err := models.Checklist.Reorder(ctx, todo.ID, ada, order); err != nil {
+			t.Fatalf("Reorder() error = %v", err)
+		}
+		all, err := models.Checklist.GetAll(ctx, todo.ID, ada)
+		if err != nil || len(all) != 3 {
+			t.Fatalf("GetAll() = %d items, %v; want 3", len(all), err)
+		}
+		for i, item := range all {
+			if item.ID != order[i] {
+				t.Fatalf("GetAll()[%d] = item %d, want %d", i, item.ID, order[i])
+			}
+		}
+
+		// The order must name each item once
+		for _, ids := range [][]int{order[:2], {order[0], order[0], order[1]}, {order[0], order[1], 999}} {
+			wantErr(t, "Reorder() with a bad order", models.Checklist.Reorder(ctx, todo.ID, ada, ids), data.ErrChecklistOrder)
+		}
+
+		if err := models.Checklist.Delete(ctx, items[1].ID, todo.ID, ada); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		wantErr(t, "second Delete()", models.Checklist.Delete(ctx, items[1].ID, todo.ID, ada), data.ErrNotFound)
+		if all, err := models.Checklist.GetAll(ctx, todo.ID, ada); err != nil || len(all) != 2 {
+			t.Fatalf("GetAll() after Delete() = %d items, %v; want 2", len(all), err)
+		}
+	})
+}
@@ -0,0 +1,316 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrInboxList is returned when trying to archive or delete a user's Inbox
+	ErrInboxList = errors.New("the inbox cannot be archived or deleted")
+	// ErrArchivedList is returned when adding or moving a todo into an archived list
+	ErrArchivedList = errors.New("the list is archived")
+)
+
+// InboxName is the name given to the list every user starts with
+const InboxName = "Inbox"
+
+// List is a named group of a user's todos, a project.
+type List struct {
+	ID     int    `json:"id,omitempty"`
+	UserID int    `json:"user_id,omitempty"`
+	Name   string `json:"name,omitempty"`
+	// Position orders a user's lists, lowest first
+	Position int `json:"position"`
+	// Inbox marks the list todos land in when no list is given
+	Inbox      bool       `json:"inbox"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at,omitempty"`
+	UpdatedAt  time.Time  `json:"updated_at,omitempty"`
+}
+
+// ListRepository stores each user's lists.
+type ListRepository interface {
+	// GetAll returns the user's lists by position, leaving out archived
+	// ones unless includeArchived is set
+	GetAll(ctx context.Context, userID int, includeArchived bool) ([]List, error)
+	Get(ctx context.Context, ID, userID int) (*List, error)
+	// Inbox returns the user's Inbox, creating it if needed
+	Inbox(ctx context.Context, userID int) (*List, error)
+	// Insert adds a list after the user's others
+	Insert(ctx context.Context, list *List) error
+	// Update renames and repositions a list
+	Update(ctx context.Context, list *List) error
+	SetArchived(ctx context.Context, ID, userID int, archived bool) error
+	// Delete moves the list's todos to the end of the Inbox, returning how
+	// many moved, then deletes the list
+	Delete(ctx context.Context, ID, userID int) (int, error)
+}
+
+// listSelect loads lists in the column order scanList expects
+const listSelect = "SELECT id, user_id, name, position, is_inbox, archived_at, created_at, updated_at FROM lists"
+
+// todoNotArchived keeps todos out of archived lists, for the todos table aliased t
+const todoNotArchived = "NOT EXISTS (SELECT 1 FROM lists l WHERE l.id = t.list_id AND l.archived_at IS NOT NULL)"
+
+func scanList(row rowScanner, list *List) error {
+	return row.Scan(&list.ID, &list.UserID, &list.Name, &list.Position, &list.Inbox, &list.ArchivedAt, &list.CreatedAt, &list.UpdatedAt)
+}
+
+// SQLListRepository is the database-backed ListRepository.
+type SQLListRepository struct {
+	sqlStore
+}
+
+func (r *SQLListRepository) GetAll(ctx context.Context, userID int, includeArchived bool) (lists []List, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	query := listSelect + " WHERE user_id = ?"
+	if !includeArchived {
+		query += " AND archived_at IS NULL"
+	}
+	query += " ORDER BY position, id"
+
+	rows, err := r.db.QueryContext(ctx, r.rebind(query), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() // Ensure the result set is closed after function execution
+
+	for rows.Next() {
+		var list List
+		if err := scanList(rows, &list); err != nil {
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return lists, nil
+}
+
+func (r *SQLListRepository) Get(ctx context.Context, ID, userID int) (_ *List, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	var list List
+	err = scanList(r.db.QueryRowContext(ctx, r.rebind(listSelect+" WHERE id = ? AND user_id = ?"), ID, userID), &list)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+func (r *SQLListRepository) Inbox(ctx context.Context, userID int) (_ *List, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	inboxID, err := r.inboxID(ctx, r.db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var list List
+	err = scanList(r.db.QueryRowContext(ctx, r.rebind(listSelect+" WHERE id = ?"), inboxID), &list)
+	if err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+func (r *SQLListRepository) Insert(ctx context.Context, list *List) (err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	now := timeNow()
+	query := r.rebind(`
+        INSERT INTO lists (user_id, name, position, is_inbox, created_at, updated_at)
+        VALUES (?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM lists WHERE user_id = ?), ?, ?, ?)
+        RETURNING id, position`)
+
+	err = r.db.QueryRowContext(ctx, query, list.UserID, list.Name, list.UserID, false, now, now).Scan(&list.ID, &list.Position)
+	if err != nil {
+		return err
+	}
+	list.Inbox, list.ArchivedAt = false, nil
+	list.CreatedAt, list.UpdatedAt = now, now
+
+	return nil
+}
+
+func (r *SQLListRepository) Update(ctx context.Context, list *List) (err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	query := r.rebind("UPDATE lists SET name = ?, position = ?, updated_at = ? WHERE id = ? AND user_id = ?")
+	result, err := r.db.ExecContext(ctx, query, list.Name, list.Position, timeNow(), list.ID, list.UserID)
+	if err != nil {
+		return err
+	}
+
+	return expectRows(result)
+}
+
+func (r *SQLListRepository) SetArchived(ctx context.Context, ID, userID int, archived bool) (err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	// The Inbox never gets archived; archiving twice keeps the first archived_at
+	query := "UPDATE lists SET archived_at = COALESCE(archived_at, ?), updated_at = ? WHERE id = ? AND user_id = ? AND NOT is_inbox"
+	args := []any{timeNow(), timeNow(), ID, userID}
+	if !archived {
+		query = "UPDATE lists SET archived_at = NULL, updated_at = ? WHERE id = ? AND user_id = ? AND NOT is_inbox"
+		args = args[1:]
+	}
+
+	result, err := r.db.ExecContext(ctx, r.rebind(query), args...)
+	if err != nil {
+		return err
+	}
+
+	if err = expectRows(result); errors.Is(err, ErrNotFound) {
+		return r.inboxOrNotFound(ctx, ID, userID)
+	}
+
+	return err
+}
+
+func (r *SQLListRepository) Delete(ctx context.Context, ID, userID int) (moved int, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	inboxID, err := r.inboxID(ctx, tx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if inboxID == ID {
+		return 0, ErrInboxList
+	}
+
+	moved, err = r.moveTodosToList(ctx, tx, userID, ID, inboxID)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, r.rebind("DELETE FROM lists WHERE id = ? AND user_id = ?"), ID, userID)
+	if err != nil {
+		return 0, err
+	}
+	if err = expectRows(result); err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return moved, nil
+}
+
+// inboxOrNotFound tells apart the two reasons a write guarded by NOT is_inbox
+// can match nothing.
+func (r *SQLListRepository) inboxOrNotFound(ctx context.Context, ID, userID int) error {
+	var inbox bool
+	err := r.db.QueryRowContext(ctx, r.rebind("SELECT is_inbox FROM lists WHERE id = ? AND user_id = ?"), ID, userID).Scan(&inbox)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if inbox {
+		return ErrInboxList
+	}
+
+	return ErrNotFound
+}
+
+// inboxID returns the ID of the user's Inbox, creating the list first for
+// users who don't have one yet.
+func (s sqlStore) inboxID(ctx context.Context, db dbtx, userID int) (int, error) {
+	query := s.rebind("SELECT id FROM lists WHERE user_id = ? AND is_inbox")
+
+	var id int
+	err := db.QueryRowContext(ctx, query, userID).Scan(&id)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return id, err
+	}
+
+	// Only a missing Inbox is inserted, sparing a write and an ID on every
+	// call. A request racing this one may create it first, which the
+	// conflict clause turns into a no-op.
+	now := timeNow()
+	_, err = db.ExecContext(ctx, s.rebind(`
+        INSERT INTO lists (user_id, name, position, is_inbox, created_at, updated_at)
+        VALUES (?, ?, 0, ?, ?, ?)
+        ON CONFLICT (user_id) WHERE is_inbox DO NOTHING`), userID, InboxName, true, now, now)
+	if err != nil {
+		return 0, err
+	}
+
+	err = db.QueryRowContext(ctx, query, userID).Scan(&id)
+
+	return id, err
+}
+
+// checkList makes sure a todo goes into one of its owner's lists that isn't archived.
+func (s sqlStore) checkList(ctx context.Context, db dbtx, listID, userID int) error {
+	var archivedAt *time.Time
+	err := db.QueryRowContext(ctx, s.rebind("SELECT archived_at FROM lists WHERE id = ? AND user_id = ?"), listID, userID).Scan(&archivedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("todos.list_id: %w", ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	if archivedAt != nil {
+		return ErrArchivedList
+	}
+
+	return nil
+}
+
+// expectRows turns a write that matched nothing into ErrNotFound.
+func expectRows(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
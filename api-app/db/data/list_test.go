@@ -0,0 +1,90 @@
+package data_test
+
+import (
+	"context"
+	"task-app/db/data"
+	"testing"
+)
+
+func TestListRepository(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		ada, bob := newUser(t, models, "ada"), newUser(t, models, "bob")
+
+		// Users start with their Inbox
+		lists, err := models.List.GetAll(ctx, ada, false)
+		if err != nil || len(lists) != 1 || !lists[0].Inbox || lists[0].Name != data.InboxName {
+			t.Fatalf("GetAll() of a new user = %+v, %v; want their Inbox", lists, err)
+		}
+		inbox, err := models.List.Inbox(ctx, ada)
+		if err != nil || inbox.ID != lists[0].ID {
+			t.Fatalf("Inbox() = %+v, %v; want list %d", inbox, err, lists[0].ID)
+		}
+
+		work := &data.List{UserID: ada, Name: "Work"}
+		if err := models.List.Insert(ctx, work); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+		if work.ID == 0 || work.Position <= inbox.Position {
+			t.Fatalf("Insert() = %+v; want a new list after the Inbox", work)
+		}
+
+		work.Name = "Office"
+		if err := models.List.Update(ctx, work); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		got, err := models.List.Get(ctx, work.ID, ada)
+		if err != nil || got.Name != "Office" {
+			t.Fatalf("Get() after Update() = %+v, %v", got, err)
+		}
+
+		_, err = models.List.Get(ctx, work.ID, bob)
+		wantErr(t, "Get() of another user's list", err, data.ErrNotFound)
+		wantErr(t, "Update() of another user's list", models.List.Update(ctx, &data.List{ID: work.ID, UserID: bob, Name: "Mine"}), data.ErrNotFound)
+
+		// Archived lists are left out unless asked for
+		if err := models.List.SetArchived(ctx, work.ID, ada, true); err != nil {
+			t.Fatalf("SetArchived(true) error = %v", err)
+		}
+		for includeArchived, want := range map[bool]int{false: 1, true: 2} {
+			lists, err := models.List.GetAll(ctx, ada, includeArchived)
+			if err != nil || len(lists) != want {
+				t.Errorf("GetAll(%v) = %d lists, %v; want %d", includeArchived, len(lists), err, want)
+			}
+		}
+		err = models.Todo.Insert(ctx, &data.Todo{UserID: ada, PriorityID: 1, ListID: work.ID, Text: "Late"})
+		wantErr(t, "Todo.Insert() into an archived list", err, data.ErrArchivedList)
+		if err := models.List.SetArchived(ctx, work.ID, ada, false); err != nil {
+			t.Fatalf("SetArchived(false) error = %v", err)
+		}
+
+		wantErr(t, "SetArchived() of the Inbox", models.List.SetArchived(ctx, inbox.ID, ada, true), data.ErrInboxList)
+		_, err = models.List.Delete(ctx, inbox.ID, ada)
+		wantErr(t, "Delete() of the Inbox", err, data.ErrInboxList)
+
+		// Deleting a list moves its todos to the end of the Inbox, in order
+		kept := newTodo(t, models, ada, "Kept")
+		var IDs []int
+		for _, text := range []string{"Report", "Review"} {
+			todo := &data.Todo{UserID: ada, PriorityID: 1, ListID: work.ID, Text: text}
+			if err := models.Todo.Insert(ctx, todo); err != nil {
+				t.Fatalf("Todo.Insert() error = %v", err)
+			}
+			IDs = append(IDs, todo.ID)
+		}
+		if err := models.Todo.Move(ctx, IDs[1], ada, 0, IDs[0]); err != nil {
+			t.Fatalf("Todo.Move() error = %v", err)
+		}
+		moved, err := models.List.Delete(ctx, work.ID, ada)
+		if err != nil || moved != 2 {
+			t.Fatalf("Delete() = %d, %v; want 2 todos moved", moved, err)
+		}
+		todos, err := models.Todo.GetAll(ctx, ada, data.TodoFilter{ListID: inbox.ID, Sort: data.SortPosition})
+		if err != nil || len(todos) != 3 || todos[0].ID != kept.ID || todos[1].ID != IDs[1] || todos[2].ID != IDs[0] {
+			t.Fatalf("Inbox after Delete() = %+v, %v; want todos %d, %d and %d", todos, err, kept.ID, IDs[1], IDs[0])
+		}
+		wantReassigned(t, models, IDs[0], ada, "list_id", work.ID, inbox.ID)
+		_, err = models.List.Get(ctx, work.ID, ada)
+		wantErr(t, "Get() after Delete()", err, data.ErrNotFound)
+	})
+}
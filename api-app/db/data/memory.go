@@ -0,0 +1,2075 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// memoryStore holds the records shared by the in-memory repositories, so
+// todos can be joined with their priority just like the SQL queries do.
+type memoryStore struct {
+	mu         sync.Mutex
+	nextID     map[string]int
+	users      map[int]User
+	priorities map[int]Priority
+	todos      map[int]Todo
+	tags       map[int]Tag
+	lists      map[int]List
+	checklist  map[int]ChecklistItem
+	events     []TodoEvent
+	// refreshTokens are keyed by the hash of the token
+	refreshTokens map[string]RefreshToken
+	// revoked maps revoked token IDs to their expiry
+	revoked  map[string]time.Time
+	sessions map[int]Session
+	// passwordResets are keyed by the hash of the token
+	passwordResets map[string]passwordReset
+}
+
+func newMemoryStore() *memoryStore {
+	s := &memoryStore{
+		nextID:     map[string]int{},
+		users:      map[int]User{},
+		priorities: map[int]Priority{},
+		todos:      map[int]Todo{},
+		tags:       map[int]Tag{},
+		lists:      map[int]List{},
+		checklist:  map[int]ChecklistItem{},
+
+		refreshTokens: map[string]RefreshToken{},
+		revoked:       map[string]time.Time{},
+		sessions:      map[int]Session{},
+
+		passwordResets: map[string]passwordReset{},
+	}
+
+	// Same defaults as the seed_priorities migration
+	now := timeNow()
+	for _, p := range []Priority{{Name: "Low", Badge: "is-info"}, {Name: "Medium", Badge: "is-warning"}, {Name: "High", Badge: "is-danger"}} {
+		p.ID = s.newID("priorities")
+		p.Rank = p.ID * 10
+		p.CreatedAt, p.UpdatedAt = now, now
+		s.priorities[p.ID] = p
+	}
+
+	return s
+}
+
+// newID returns the next auto-increment value for table. Callers hold mu.
+func (s *memoryStore) newID(table string) int {
+	s.nextID[table]++
+	return s.nextID[table]
+}
+
+// checkContext gives the in-memory repositories the same cancellation
+// behaviour as the SQL ones.
+func checkContext(ctx context.Context) (err error) {
+	err = ctx.Err()
+	contextErr(ctx, &err)
+	return err
+}
+
+// MemoryUserRepository is an in-memory UserRepository.
+type MemoryUserRepository struct {
+	store *memoryStore
+}
+
+func (r *MemoryUserRepository) GetAll(ctx context.Context) ([]User, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var users []User
+	for _, user := range r.store.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+
+	return users, nil
+}
+
+func (r *MemoryUserRepository) Insert(ctx context.Context, user User) (int, error) {
+	hashedPassword, err := hashPassword(user.Password)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := checkContext(ctx); err != nil {
+		return 0, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, existing := range r.store.users {
+		if strings.EqualFold(existing.Email, user.Email) {
+			return 0, fmt.Errorf("users.email: %w", ErrDuplicate)
+		}
+	}
+
+	now := timeNow()
+	user.ID = r.store.newID("users")
+	user.Password = hashedPassword
+	user.ConfirmPassword = ""
+	user.CreatedAt, user.UpdatedAt = now, now
+	r.store.users[user.ID] = user
+	r.store.inbox(user.ID)
+
+	return user.ID, nil
+}
+
+func (r *MemoryUserRepository) EmailExists(ctx context.Context, email string) (bool, error) {
+	_, err := r.GetByEmail(ctx, email)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (r *MemoryUserRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, user := range r.store.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (r *MemoryUserRepository) GetByID(ctx context.Context, ID int) (*User, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.users[ID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return &user, nil
+}
+
+// MemoryPriorityRepository is an in-memory PriorityRepository.
+type MemoryPriorityRepository struct {
+	store *memoryStore
+}
+
+func (r *MemoryPriorityRepository) GetAll(ctx context.Context, userID int) ([]Priority, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var priorities []Priority
+	for _, priority := range r.store.priorities {
+		if priority.visibleTo(userID) {
+			priorities = append(priorities, priority)
+		}
+	}
+	sort.Slice(priorities, func(i, j int) bool {
+		if priorities[i].Rank != priorities[j].Rank {
+			return priorities[i].Rank < priorities[j].Rank
+		}
+		return priorities[i].ID < priorities[j].ID
+	})
+
+	return priorities, nil
+}
+
+func (r *MemoryPriorityRepository) Get(ctx context.Context, ID, userID int) (*Priority, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	priority, ok := r.store.priorities[ID]
+	if !ok || !priority.visibleTo(userID) {
+		return nil, ErrNotFound
+	}
+
+	return &priority, nil
+}
+
+func (r *MemoryPriorityRepository) Insert(ctx context.Context, priority *Priority) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if r.store.priorityNameTaken(*priority) {
+		return fmt.Errorf("priorities.name: %w", ErrDuplicate)
+	}
+
+	now := timeNow()
+	priority.ID = r.store.newID("priorities")
+	priority.CreatedAt, priority.UpdatedAt = now, now
+	r.store.priorities[priority.ID] = *priority
+
+	return nil
+}
+
+func (r *MemoryPriorityRepository) Update(ctx context.Context, priority *Priority) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	existing, ok := r.store.priorities[priority.ID]
+	if !ok || existing.UserID == nil || priority.UserID == nil || *existing.UserID != *priority.UserID {
+		return ErrNotFound
+	}
+	if r.store.priorityNameTaken(*priority) {
+		return fmt.Errorf("priorities.name: %w", ErrDuplicate)
+	}
+
+	existing.Name, existing.Badge, existing.Rank = priority.Name, priority.Badge, priority.Rank
+	existing.UpdatedAt = timeNow()
+	r.store.priorities[priority.ID] = existing
+
+	return nil
+}
+
+func (r *MemoryPriorityRepository) Delete(ctx context.Context, ID, userID, reassignTo int) (int, error) {
+	if err := checkContext(ctx); err != nil {
+		return 0, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	existing, ok := r.store.priorities[ID]
+	if !ok || existing.UserID == nil || *existing.UserID != userID {
+		return 0, ErrNotFound
+	}
+
+	if reassignTo == 0 {
+		for _, priority := range r.store.priorities {
+			if priority.UserID != nil {
+				continue
+			}
+			if reassignTo == 0 || priority.Rank < r.store.priorities[reassignTo].Rank ||
+				(priority.Rank == r.store.priorities[reassignTo].Rank && priority.ID < reassignTo) {
+				reassignTo = priority.ID
+			}
+		}
+	}
+	target, ok := r.store.priorities[reassignTo]
+	if !ok || reassignTo == ID || !target.visibleTo(userID) {
+		return 0, fmt.Errorf("reassign priority: %w", ErrNotFound)
+	}
+
+	moved, err := r.store.reassignTodos(userID, "priority_id", ID, reassignTo, func(todo *Todo) *int { return &todo.PriorityID })
+	if err != nil {
+		return 0, err
+	}
+	delete(r.store.priorities, ID)
+
+	return moved, nil
+}
+
+// visibleTo reports whether the user may see and assign the priority.
+func (p Priority) visibleTo(userID int) bool {
+	return p.UserID == nil || *p.UserID == userID
+}
+
+// priorityNameTaken mirrors the unique indexes on priorities. Callers hold mu.
+func (s *memoryStore) priorityNameTaken(priority Priority) bool {
+	for _, existing := range s.priorities {
+		if existing.ID != priority.ID && existing.Name == priority.Name && sameOwner(existing.UserID, priority.UserID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sameOwner(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
+
+// MemoryTodoRepository is an in-memory TodoRepository.
+type MemoryTodoRepository struct {
+	store *memoryStore
+}
+
+func (r *MemoryTodoRepository) Insert(ctx context.Context, todo *Todo) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	return r.store.createTodo(todo)
+}
+
+// createTodo mirrors SQLTodoRepository.createTodo. Callers hold mu.
+func (s *memoryStore) createTodo(todo *Todo) error {
+	if priority, ok := s.priorities[todo.PriorityID]; !ok || !priority.visibleTo(todo.UserID) {
+		return fmt.Errorf("todos.priority_id: %w", ErrNotFound)
+	}
+
+	if todo.ListID == 0 {
+		todo.ListID = s.inbox(todo.UserID).ID
+	} else if err := s.checkList(todo.ListID, todo.UserID); err != nil {
+		return err
+	}
+
+	position, err := s.lastPosition(todo.ListID)
+	if err != nil {
+		return err
+	}
+
+	now := timeNow()
+	todo.ID = s.newID("todos")
+	todo.Position = position
+	todo.DueAt, todo.RemindAt = utc(todo.DueAt), utc(todo.RemindAt)
+	todo.CreatedAt, todo.UpdatedAt = now, now
+	todo.Version = 1
+	todo.Tags = NormalizeTags(todo.Tags)
+	s.registerTags(todo.UserID, todo.Tags)
+	s.todos[todo.ID] = *todo
+
+	return s.recordCreated(*todo)
+}
+
+func (r *MemoryTodoRepository) Update(ctx context.Context, todo *Todo) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	return r.store.updateTodo(todo, TodoUpdated)
+}
+
+// updateTodo mirrors SQLTodoRepository.updateTodo. Callers hold mu.
+func (s *memoryStore) updateTodo(todo *Todo, action string) error {
+	existing, ok := s.todo(todo.ID, todo.UserID)
+	if !ok {
+		return ErrNotFound
+	}
+	if todo.Version != 0 && todo.Version != existing.Version {
+		return ErrVersionConflict
+	}
+	before := existing
+	if priority, ok := s.priorities[todo.PriorityID]; !ok || !priority.visibleTo(todo.UserID) {
+		return fmt.Errorf("todos.priority_id: %w", ErrNotFound)
+	}
+
+	if todo.ListID != 0 && todo.ListID != existing.ListID {
+		if err := s.checkList(todo.ListID, todo.UserID); err != nil {
+			return err
+		}
+		position, err := s.lastPosition(todo.ListID)
+		if err != nil {
+			return err
+		}
+		existing.ListID, existing.Position = todo.ListID, position
+	}
+	todo.ListID, todo.Position = existing.ListID, existing.Position
+
+	if !sameTime(existing.RemindAt, todo.RemindAt) {
+		existing.ReminderFiredAt = nil
+	}
+	if existing.Recurrence != todo.Recurrence {
+		existing.RecurrenceIndex = 0
+	}
+	existing.Recurrence = todo.Recurrence
+	existing.PriorityID = todo.PriorityID
+	existing.Text = todo.Text
+	existing.DueAt, existing.RemindAt = utc(todo.DueAt), utc(todo.RemindAt)
+	if todo.Tags != nil {
+		todo.Tags = NormalizeTags(todo.Tags)
+		existing.Tags = todo.Tags
+		s.registerTags(todo.UserID, todo.Tags)
+	}
+	changes, err := todoChanges(&before, todo)
+	if err != nil {
+		return err
+	}
+	if len(changes) > 0 {
+		s.recordEvent(TodoEvent{TodoID: todo.ID, UserID: todo.UserID, Action: action, Changes: changes})
+	}
+
+	existing.UpdatedAt = timeNow()
+	existing.Version++
+	todo.Version = existing.Version
+	s.todos[todo.ID] = existing
+
+	return nil
+}
+
+func (r *MemoryTodoRepository) Get(ctx context.Context, ID, userID int) (*Todo, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	todo, ok := r.store.todo(ID, userID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	todo.Priority = r.store.priorities[todo.PriorityID]
+	r.store.loadChecklist(&todo)
+
+	return &todo, nil
+}
+
+func (r *MemoryTodoRepository) GetAll(ctx context.Context, userID int, filter TodoFilter) ([]Todo, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var todos []Todo
+	for _, todo := range r.store.todos {
+		if todo.UserID != userID {
+			continue
+		}
+		// The priority's rank is a sort key, which the cursor compares
+		todo.Priority = r.store.priorities[todo.PriorityID]
+		if !filter.matches(todo) || (filter.ListID == 0 && !filter.IncludeArchived && r.store.archived(todo.ListID)) {
+			continue
+		}
+		r.store.loadChecklist(&todo)
+		todos = append(todos, todo)
+	}
+
+	sort.Slice(todos, func(i, j int) bool { return filter.less(todos[i], todos[j]) })
+	if filter.Limit > 0 && len(todos) > filter.Limit {
+		todos = todos[:filter.Limit]
+	}
+
+	return todos, nil
+}
+
+func (r *MemoryTodoRepository) Count(ctx context.Context, userID int, filter TodoFilter) (int, error) {
+	if err := checkContext(ctx); err != nil {
+		return 0, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	filter.After = nil
+	count := 0
+	for _, todo := range r.store.todos {
+		if todo.UserID == userID && filter.matches(todo) && (filter.ListID != 0 || filter.IncludeArchived || !r.store.archived(todo.ListID)) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (r *MemoryTodoRepository) Search(ctx context.Context, userID int, query string, limit int) ([]TodoSearchResult, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, ErrEmptySearch
+	}
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var results []TodoSearchResult
+	for _, todo := range r.store.todos {
+		if todo.UserID != userID || todo.DeletedAt != nil || r.store.archived(todo.ListID) {
+			continue
+		}
+		snippet, rank, ok := matchTerms(todo.Text, terms)
+		if !ok {
+			continue
+		}
+		todo.Priority = r.store.priorities[todo.PriorityID]
+		r.store.loadChecklist(&todo)
+		results = append(results, TodoSearchResult{Todo: todo, Snippet: highlight(snippet), Rank: rank})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].ID < results[j].ID
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+func (r *MemoryTodoRepository) SetCompleted(ctx context.Context, ID, userID int, completed bool) (int, error) {
+	if err := checkContext(ctx); err != nil {
+		return 0, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	return r.store.setCompleted(ID, userID, completed)
+}
+
+// setCompleted does the work of SetCompleted. Callers hold mu.
+func (s *memoryStore) setCompleted(ID, userID int, completed bool) (int, error) {
+	todo, ok := s.todo(ID, userID)
+	if !ok {
+		return 0, ErrNotFound
+	}
+
+	// Completing a completed todo, or reopening an open one, changes nothing
+	if completed == todo.Completed {
+		return 0, nil
+	}
+
+	action := TodoUncompleted
+	if completed {
+		action = TodoCompleted
+	}
+	change, err := fieldChange(todo.Completed, completed)
+	if err != nil {
+		return 0, err
+	}
+	s.recordEvent(TodoEvent{TodoID: ID, UserID: userID, Action: action, Changes: map[string]FieldChange{"completed": change}})
+
+	now := timeNow()
+	todo.CompletedAt = nil
+	if completed {
+		todo.CompletedAt = &now
+	}
+	todo.Completed = completed
+	todo.UpdatedAt = now
+	todo.Version++
+
+	var next Todo
+	if completed {
+		if next, ok, err = nextOccurrence(todo); err != nil {
+			return 0, err
+		}
+		if ok {
+			if next.Position, err = s.lastPosition(next.ListID); err != nil {
+				return 0, err
+			}
+			next.ID = s.newID("todos")
+			next.CreatedAt, next.UpdatedAt = now, now
+			next.Version = 1
+			s.todos[next.ID] = next
+			if err = s.recordCreated(next); err != nil {
+				return 0, err
+			}
+
+			// The checklist starts over, with nothing done
+			for _, item := range s.checklist {
+				if item.TodoID == ID {
+					item.ID, item.TodoID, item.Done = s.newID("checklist_items"), next.ID, false
+					item.CreatedAt, item.UpdatedAt = now, now
+					s.checklist[item.ID] = item
+				}
+			}
+
+			// The rule moves to the new todo, as in the SQL version
+			todo.Recurrence = ""
+		}
+	}
+	s.todos[ID] = todo
+
+	return next.ID, nil
+}
+
+func (r *MemoryTodoRepository) FireReminders(ctx context.Context, now time.Time) (int, error) {
+	if err := checkContext(ctx); err != nil {
+		return 0, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	fired := 0
+	now = now.UTC()
+	for id, todo := range r.store.todos {
+		if todo.RemindAt == nil || todo.RemindAt.After(now) || todo.ReminderFiredAt != nil || todo.Completed || todo.DeletedAt != nil || r.store.archived(todo.ListID) {
+			continue
+		}
+		todo.ReminderFiredAt = &now
+		r.store.todos[id] = todo
+		fired++
+	}
+
+	return fired, nil
+}
+
+func (r *MemoryTodoRepository) Move(ctx context.Context, ID, userID, afterID, beforeID int) error {
+	if ID == afterID || ID == beforeID || (afterID == 0 && beforeID == 0) {
+		return ErrInvalidMove
+	}
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	todo, ok := r.store.todo(ID, userID)
+	if !ok {
+		return ErrNotFound
+	}
+	listID := todo.ListID
+
+	neighbours := func() (lower, upper string, err error) {
+		if afterID != 0 {
+			after, ok := r.store.todo(afterID, userID)
+			if !ok {
+				return "", "", fmt.Errorf("after_id: %w", ErrNotFound)
+			}
+			if after.ListID != listID {
+				return "", "", ErrInvalidMove
+			}
+			lower = after.Position
+		}
+		if beforeID != 0 {
+			before, ok := r.store.todo(beforeID, userID)
+			if !ok {
+				return "", "", fmt.Errorf("before_id: %w", ErrNotFound)
+			}
+			if before.ListID != listID {
+				return "", "", ErrInvalidMove
+			}
+			upper = before.Position
+		}
+
+		// Same as the MIN and MAX queries of the SQL version
+		for id, other := range r.store.todos {
+			if other.ListID != listID || id == ID || other.DeletedAt != nil {
+				continue
+			}
+			if beforeID == 0 && other.Position > lower && (upper == "" || other.Position < upper) {
+				upper = other.Position
+			}
+			if afterID == 0 && other.Position < upper && other.Position > lower {
+				lower = other.Position
+			}
+		}
+
+		return lower, upper, nil
+	}
+
+	lower, upper, err := neighbours()
+	if err != nil {
+		return err
+	}
+	if afterID != 0 && beforeID != 0 && lower > upper {
+		return ErrInvalidMove
+	}
+	if upper != "" && lower >= upper {
+		r.store.rebalancePositions(listID)
+		if lower, upper, err = neighbours(); err != nil {
+			return err
+		}
+		if upper != "" && lower >= upper {
+			return ErrInvalidMove
+		}
+	}
+
+	key, err := positionBetween(lower, upper)
+	if err != nil {
+		return err
+	}
+	from := r.store.todos[ID].Position
+	todo = r.store.todos[ID]
+	todo.Position, todo.UpdatedAt = key, timeNow()
+	todo.Version++
+	r.store.todos[ID] = todo
+
+	if len(key) > maxPositionLength {
+		r.store.rebalancePositions(listID)
+	}
+
+	change, err := fieldChange(from, r.store.todos[ID].Position)
+	if err != nil {
+		return err
+	}
+	r.store.recordEvent(TodoEvent{TodoID: ID, UserID: userID, Action: TodoMoved, Changes: map[string]FieldChange{"position": change}})
+
+	return nil
+}
+
+func (r *MemoryTodoRepository) Delete(ctx context.Context, ID, userID, version int) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	return r.store.deleteTodo(ID, userID, version)
+}
+
+// deleteTodo mirrors SQLTodoRepository.deleteTodo. Callers hold mu.
+func (s *memoryStore) deleteTodo(ID, userID, version int) error {
+	if todo, ok := s.todo(ID, userID); ok && version != 0 && todo.Version != version {
+		return ErrVersionConflict
+	}
+	if err := s.setDeleted(ID, userID, true); err != nil {
+		return err
+	}
+	s.recordEvent(TodoEvent{TodoID: ID, UserID: userID, Action: TodoDeleted})
+
+	return nil
+}
+
+func (r *MemoryTodoRepository) Restore(ctx context.Context, ID, userID int) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if err := r.store.setDeleted(ID, userID, false); err != nil {
+		return err
+	}
+	r.store.recordEvent(TodoEvent{TodoID: ID, UserID: userID, Action: TodoRestored})
+
+	return nil
+}
+
+// setDeleted mirrors SQLTodoRepository.setDeleted. Callers hold mu.
+func (s *memoryStore) setDeleted(ID, userID int, deleted bool) error {
+	todo, ok := s.todos[ID]
+	if !ok || todo.UserID != userID || (todo.DeletedAt != nil) == deleted {
+		return ErrNotFound
+	}
+
+	now := timeNow()
+	todo.DeletedAt, todo.UpdatedAt = nil, now
+	todo.Version++
+	if deleted {
+		todo.DeletedAt = &now
+	}
+	s.todos[ID] = todo
+
+	return nil
+}
+
+func (r *MemoryTodoRepository) Undo(ctx context.Context, userID int, window time.Duration) (*TodoEvent, error) {
+	return r.undo(ctx, userID, window, true)
+}
+
+func (r *MemoryTodoRepository) Redo(ctx context.Context, userID int, window time.Duration) (*TodoEvent, error) {
+	return r.undo(ctx, userID, window, false)
+}
+
+func (r *MemoryTodoRepository) undo(ctx context.Context, userID int, window time.Duration, undo bool) (*TodoEvent, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	// Same stacks as the SQL version: the latest live event to undo, the
+	// first undone one after it to redo
+	found, live := -1, -1
+	for i, event := range r.store.events {
+		if _, ok := r.store.todos[event.TodoID]; !ok || event.UserID != userID || !undoable(event.Action) {
+			continue
+		}
+		if event.UndoneAt == nil {
+			live, found = i, -1
+		} else if found < 0 {
+			found = i
+		}
+	}
+	nothing := ErrNothingToRedo
+	if undo {
+		found, nothing = live, ErrNothingToUndo
+	}
+	if found < 0 {
+		return nil, nothing
+	}
+
+	event := r.store.events[found]
+	now := timeNow()
+	since := event.CreatedAt
+	if !undo {
+		since = *event.UndoneAt
+	}
+	if since.Before(now.Add(-window)) {
+		return nil, nothing
+	}
+
+	action := TodoRedone
+	if undo {
+		action = TodoUndone
+	}
+	if event.Action == TodoUpdated {
+		current, ok := r.store.todo(event.TodoID, event.UserID)
+		if !ok {
+			return nil, ErrUndoConflict
+		}
+		target, err := revertedTodo(current, event.Changes, undo)
+		if err != nil {
+			return nil, err
+		}
+		if err = r.store.updateTodo(&target, action); errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("%w: %v", ErrUndoConflict, err)
+		} else if err != nil {
+			return nil, err
+		}
+	} else if event.Action == TodoMoved {
+		current, ok := r.store.todo(event.TodoID, event.UserID)
+		if !ok {
+			return nil, ErrUndoConflict
+		}
+		key, err := revertedPosition(current.Position, event.Changes, undo)
+		if err != nil {
+			return nil, err
+		}
+		change, err := fieldChange(current.Position, key)
+		if err != nil {
+			return nil, err
+		}
+		stored := r.store.todos[current.ID]
+		stored.Position, stored.UpdatedAt = key, timeNow()
+		stored.Version++
+		r.store.todos[current.ID] = stored
+		r.store.recordEvent(TodoEvent{TodoID: event.TodoID, UserID: event.UserID, Action: action, Changes: map[string]FieldChange{"position": change}})
+	} else {
+		deleted := trashedBy(event.Action, undo)
+		if err := r.store.setDeleted(event.TodoID, event.UserID, deleted); err != nil {
+			return nil, ErrUndoConflict
+		}
+		change, err := fieldChange(!deleted, deleted)
+		if err != nil {
+			return nil, err
+		}
+		r.store.recordEvent(TodoEvent{TodoID: event.TodoID, UserID: event.UserID, Action: action, Changes: map[string]FieldChange{"deleted": change}})
+	}
+
+	event.UndoneAt = nil
+	if undo {
+		event.UndoneAt = &now
+	}
+	r.store.events[found] = event
+
+	return &event, nil
+}
+
+func (r *MemoryTodoRepository) Purge(ctx context.Context, ID, userID int) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	todo, ok := r.store.todos[ID]
+	if !ok || todo.UserID != userID || todo.DeletedAt == nil {
+		return ErrNotFound
+	}
+	r.store.purgeTodo(ID)
+
+	return nil
+}
+
+func (r *MemoryTodoRepository) EmptyTrash(ctx context.Context, userID int) (int, error) {
+	if err := checkContext(ctx); err != nil {
+		return 0, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	purged := 0
+	for id, todo := range r.store.todos {
+		if todo.UserID == userID && todo.DeletedAt != nil {
+			r.store.purgeTodo(id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+func (r *MemoryTodoRepository) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	if err := checkContext(ctx); err != nil {
+		return 0, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	purged := 0
+	for id, todo := range r.store.todos {
+		if todo.DeletedAt != nil && todo.DeletedAt.Before(before) {
+			r.store.purgeTodo(id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// todo returns one of the user's todos, leaving out those in the trash.
+// Callers hold mu.
+func (s *memoryStore) todo(ID, userID int) (Todo, bool) {
+	todo, ok := s.todos[ID]
+	if !ok || todo.UserID != userID || todo.DeletedAt != nil {
+		return Todo{}, false
+	}
+
+	return todo, true
+}
+
+// purgeTodo removes a todo for good, recording that in its history, which
+// stays. Callers hold mu.
+func (s *memoryStore) purgeTodo(ID int) {
+	todo := s.todos[ID]
+	delete(s.todos, ID)
+
+	// Mirror the ON DELETE CASCADE of checklist_items
+	for id, item := range s.checklist {
+		if item.TodoID == ID {
+			delete(s.checklist, id)
+		}
+	}
+	s.recordEvent(TodoEvent{TodoID: ID, UserID: todo.UserID, Action: TodoPurged})
+}
+
+func (r *MemoryTodoRepository) History(ctx context.Context, ID, userID int) ([]TodoEvent, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	// Todos in the trash and purged ones keep their history
+	events := []TodoEvent{}
+	for _, event := range r.store.events {
+		if event.TodoID == ID && event.UserID == userID {
+			events = append(events, event)
+		}
+	}
+	if todo, ok := r.store.todos[ID]; len(events) == 0 && (!ok || todo.UserID != userID) {
+		return nil, ErrNotFound
+	}
+
+	return events, nil
+}
+
+// recordEvent appends event to the history. Callers hold mu.
+func (s *memoryStore) recordEvent(event TodoEvent) {
+	event.ID = s.newID("todo_events")
+	event.CreatedAt = timeNow()
+	s.events = append(s.events, event)
+}
+
+// reassignTodos mirrors sqlStore.reassignTodos, fieldOf pointing at the
+// field of a todo named field. Callers hold mu.
+func (s *memoryStore) reassignTodos(userID int, field string, from, to int, fieldOf func(*Todo) *int) (int, error) {
+	change, err := fieldChange(from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	var IDs []int
+	for ID, todo := range s.todos {
+		if todo.UserID == userID && *fieldOf(&todo) == from {
+			IDs = append(IDs, ID)
+		}
+	}
+	// In ID order, like the SQL events
+	sort.Ints(IDs)
+
+	now := timeNow()
+	for _, ID := range IDs {
+		todo := s.todos[ID]
+		*fieldOf(&todo) = to
+		todo.UpdatedAt = now
+		todo.Version++
+		s.todos[ID] = todo
+		s.recordEvent(TodoEvent{TodoID: ID, UserID: userID, Action: TodoUpdated, Changes: map[string]FieldChange{field: change}})
+	}
+
+	return len(IDs), nil
+}
+
+// recordCreated records the creation of todo. Callers hold mu.
+func (s *memoryStore) recordCreated(todo Todo) error {
+	changes, err := todoChanges(nil, &todo)
+	if err != nil {
+		return err
+	}
+	s.recordEvent(TodoEvent{TodoID: todo.ID, UserID: todo.UserID, Action: TodoCreated, Changes: changes})
+
+	return nil
+}
+
+func (r *MemoryTodoRepository) Bulk(ctx context.Context, userID int, ops []BulkOperation, atomic bool) ([]BulkResult, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	// Snapshots stand in for the transaction and savepoints
+	results := bulkResults(ops)
+	start := r.store.snapshot()
+	for i, op := range ops {
+		savepoint := r.store.snapshot()
+
+		id, nextID, err := r.store.bulkOperation(userID, op)
+		if err != nil {
+			results[i].Status, results[i].Error = BulkFailed, err.Error()
+			if atomic {
+				r.store.restore(start)
+				rollBack(results)
+				return results, nil
+			}
+			r.store.restore(savepoint)
+			continue
+		}
+		results[i].ID, results[i].NextID, results[i].Status = id, nextID, BulkOK
+	}
+
+	return results, nil
+}
+
+// bulkOperation mirrors SQLTodoRepository.bulkOperation. Callers hold mu.
+func (s *memoryStore) bulkOperation(userID int, op BulkOperation) (id, nextID int, err error) {
+	switch op.Op {
+	case BulkCreate:
+		todo := op.Todo
+		todo.UserID = userID
+		err = s.createTodo(&todo)
+		return todo.ID, 0, err
+	case BulkUpdate:
+		todo := op.Todo
+		todo.ID, todo.UserID = op.ID, userID
+		return op.ID, 0, s.updateTodo(&todo, TodoUpdated)
+	case BulkDelete:
+		return op.ID, 0, s.deleteTodo(op.ID, userID, op.Version)
+	case BulkComplete:
+		nextID, err = s.setCompleted(op.ID, userID, op.Completed)
+		return op.ID, nextID, err
+	case BulkSetPriority, BulkMoveToList:
+		todo, ok := s.todo(op.ID, userID)
+		if !ok {
+			return op.ID, 0, ErrNotFound
+		}
+		todo.Tags = nil
+		if op.Op == BulkSetPriority {
+			todo.PriorityID = op.PriorityID
+		} else {
+			todo.ListID = op.ListID
+		}
+		return op.ID, 0, s.updateTodo(&todo, TodoUpdated)
+	}
+
+	return op.ID, 0, fmt.Errorf("unknown operation %q", op.Op)
+}
+
+// snapshot copies the records a todo change can touch, for restore to roll
+// back to. Callers hold mu.
+func (s *memoryStore) snapshot() *memoryStore {
+	return &memoryStore{
+		nextID:    maps.Clone(s.nextID),
+		todos:     maps.Clone(s.todos),
+		tags:      maps.Clone(s.tags),
+		lists:     maps.Clone(s.lists),
+		checklist: maps.Clone(s.checklist),
+		events:    slices.Clone(s.events),
+	}
+}
+
+// restore rolls back to a snapshot. Callers hold mu.
+func (s *memoryStore) restore(snapshot *memoryStore) {
+	s.nextID, s.todos, s.tags, s.lists = snapshot.nextID, snapshot.todos, snapshot.tags, snapshot.lists
+	s.checklist, s.events = snapshot.checklist, snapshot.events
+}
+
+// matchTerms reports whether every term prefixes a word of text. It returns
+// text with the matching words marked, and the share of words that matched
+// as a rank.
+func matchTerms(text string, terms []string) (string, float64, bool) {
+	var b strings.Builder
+	found := map[string]bool{}
+	words, matched := 0, 0
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if !unicode.IsLetter(runes[i]) && !unicode.IsDigit(runes[i]) {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+			j++
+		}
+		word := string(runes[i:j])
+		words++
+
+		hit := false
+		for _, term := range terms {
+			if strings.HasPrefix(strings.ToLower(word), term) {
+				found[term], hit = true, true
+			}
+		}
+		if hit {
+			matched++
+			b.WriteString(matchStart + word + matchEnd)
+		} else {
+			b.WriteString(word)
+		}
+		i = j
+	}
+
+	for _, term := range terms {
+		if !found[term] {
+			return "", 0, false
+		}
+	}
+
+	return b.String(), float64(matched) / float64(words), true
+}
+
+// lastPosition mirrors sqlStore.lastPosition. Callers hold mu.
+func (s *memoryStore) lastPosition(listID int) (string, error) {
+	for rebalanced := false; ; rebalanced = true {
+		key, err := positionBetween(s.maxPosition(listID), "")
+		if err != nil || len(key) <= maxPositionLength || rebalanced {
+			return key, err
+		}
+		s.rebalancePositions(listID)
+	}
+}
+
+// maxPosition mirrors sqlStore.maxPosition. Callers hold mu.
+func (s *memoryStore) maxPosition(listID int) string {
+	last := ""
+	for _, todo := range s.todos {
+		if todo.ListID == listID && todo.DeletedAt == nil && todo.Position > last {
+			last = todo.Position
+		}
+	}
+
+	return last
+}
+
+// listTodos returns all the todos of the list, trash included, in their
+// order. Callers hold mu.
+func (s *memoryStore) listTodos(listID int) []Todo {
+	var todos []Todo
+	for _, todo := range s.todos {
+		if todo.ListID == listID {
+			todos = append(todos, todo)
+		}
+	}
+	sort.Slice(todos, func(i, j int) bool {
+		if todos[i].Position != todos[j].Position {
+			return todos[i].Position < todos[j].Position
+		}
+		return todos[i].ID < todos[j].ID
+	})
+
+	return todos
+}
+
+// rebalancePositions mirrors sqlStore.rebalancePositions. Callers hold mu.
+func (s *memoryStore) rebalancePositions(listID int) {
+	todos := s.listTodos(listID)
+	for i, key := range spreadPositions(len(todos)) {
+		todo := todos[i]
+		todo.Position = key
+		s.todos[todo.ID] = todo
+	}
+}
+
+// moveTodosToList mirrors sqlStore.moveTodosToList. Callers hold mu.
+func (s *memoryStore) moveTodosToList(userID, from, to int) (int, error) {
+	last := s.maxPosition(to)
+	todos := s.listTodos(from)
+	keys := spreadPositions(len(todos))
+	for i, key := range keys {
+		todo := todos[i]
+		todo.Position = last + key
+		s.todos[todo.ID] = todo
+	}
+
+	moved, err := s.reassignTodos(userID, "list_id", from, to, func(todo *Todo) *int { return &todo.ListID })
+	if err != nil {
+		return 0, err
+	}
+	if len(keys) > 0 && len(last+keys[0]) > maxPositionLength {
+		s.rebalancePositions(to)
+	}
+
+	return moved, nil
+}
+
+// registerTags gives new tag names of the user an ID. Callers hold mu.
+func (s *memoryStore) registerTags(userID int, names []string) {
+	for _, name := range names {
+		known := false
+		for _, tag := range s.tags {
+			if tag.UserID == userID && tag.Name == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			id := s.newID("tags")
+			s.tags[id] = Tag{ID: id, UserID: userID, Name: name, CreatedAt: timeNow()}
+		}
+	}
+}
+
+// MemoryTagRepository is an in-memory TagRepository.
+type MemoryTagRepository struct {
+	store *memoryStore
+}
+
+func (r *MemoryTagRepository) GetAll(ctx context.Context, userID int) ([]Tag, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	counts := map[string]int{}
+	for _, todo := range r.store.todos {
+		if todo.UserID != userID || todo.DeletedAt != nil {
+			continue
+		}
+		for _, name := range todo.Tags {
+			counts[name]++
+		}
+	}
+
+	var tags []Tag
+	for _, tag := range r.store.tags {
+		if tag.UserID == userID && counts[tag.Name] > 0 {
+			tag.TodoCount = counts[tag.Name]
+			tags = append(tags, tag)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+
+	return tags, nil
+}
+
+// MemoryListRepository is an in-memory ListRepository.
+type MemoryListRepository struct {
+	store *memoryStore
+}
+
+func (r *MemoryListRepository) GetAll(ctx context.Context, userID int, includeArchived bool) ([]List, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var lists []List
+	for _, list := range r.store.lists {
+		if list.UserID == userID && (includeArchived || list.ArchivedAt == nil) {
+			lists = append(lists, list)
+		}
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		if lists[i].Position != lists[j].Position {
+			return lists[i].Position < lists[j].Position
+		}
+		return lists[i].ID < lists[j].ID
+	})
+
+	return lists, nil
+}
+
+func (r *MemoryListRepository) Get(ctx context.Context, ID, userID int) (*List, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	list, ok := r.store.lists[ID]
+	if !ok || list.UserID != userID {
+		return nil, ErrNotFound
+	}
+
+	return &list, nil
+}
+
+func (r *MemoryListRepository) Inbox(ctx context.Context, userID int) (*List, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	inbox := r.store.inbox(userID)
+	return &inbox, nil
+}
+
+func (r *MemoryListRepository) Insert(ctx context.Context, list *List) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	position := 0
+	for _, existing := range r.store.lists {
+		if existing.UserID == list.UserID {
+			position = max(position, existing.Position)
+		}
+	}
+
+	now := timeNow()
+	list.ID = r.store.newID("lists")
+	list.Position = position + 1
+	list.Inbox, list.ArchivedAt = false, nil
+	list.CreatedAt, list.UpdatedAt = now, now
+	r.store.lists[list.ID] = *list
+
+	return nil
+}
+
+func (r *MemoryListRepository) Update(ctx context.Context, list *List) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	existing, ok := r.store.lists[list.ID]
+	if !ok || existing.UserID != list.UserID {
+		return ErrNotFound
+	}
+
+	existing.Name, existing.Position = list.Name, list.Position
+	existing.UpdatedAt = timeNow()
+	r.store.lists[list.ID] = existing
+
+	return nil
+}
+
+func (r *MemoryListRepository) SetArchived(ctx context.Context, ID, userID int, archived bool) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	list, ok := r.store.lists[ID]
+	if !ok || list.UserID != userID {
+		return ErrNotFound
+	}
+	if list.Inbox {
+		return ErrInboxList
+	}
+
+	now := timeNow()
+	if !archived {
+		list.ArchivedAt = nil
+	} else if list.ArchivedAt == nil {
+		list.ArchivedAt = &now
+	}
+	list.UpdatedAt = now
+	r.store.lists[ID] = list
+
+	return nil
+}
+
+func (r *MemoryListRepository) Delete(ctx context.Context, ID, userID int) (int, error) {
+	if err := checkContext(ctx); err != nil {
+		return 0, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	list, ok := r.store.lists[ID]
+	if !ok || list.UserID != userID {
+		return 0, ErrNotFound
+	}
+	if list.Inbox {
+		return 0, ErrInboxList
+	}
+
+	inboxID := r.store.inbox(userID).ID
+	moved, err := r.store.moveTodosToList(userID, ID, inboxID)
+	if err != nil {
+		return 0, err
+	}
+	delete(r.store.lists, ID)
+
+	return moved, nil
+}
+
+// inbox returns the user's Inbox, creating it if needed. Callers hold mu.
+func (s *memoryStore) inbox(userID int) List {
+	for _, list := range s.lists {
+		if list.UserID == userID && list.Inbox {
+			return list
+		}
+	}
+
+	now := timeNow()
+	list := List{ID: s.newID("lists"), UserID: userID, Name: InboxName, Inbox: true, CreatedAt: now, UpdatedAt: now}
+	s.lists[list.ID] = list
+
+	return list
+}
+
+// checkList mirrors sqlStore.checkList. Callers hold mu.
+func (s *memoryStore) checkList(listID, userID int) error {
+	list, ok := s.lists[listID]
+	if !ok || list.UserID != userID {
+		return fmt.Errorf("todos.list_id: %w", ErrNotFound)
+	}
+	if list.ArchivedAt != nil {
+		return ErrArchivedList
+	}
+
+	return nil
+}
+
+// archived reports whether a list is archived. Callers hold mu.
+func (s *memoryStore) archived(listID int) bool {
+	return s.lists[listID].ArchivedAt != nil
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Equal(*b)
+}
+
+// MemoryChecklistRepository is an in-memory ChecklistRepository.
+type MemoryChecklistRepository struct {
+	store *memoryStore
+}
+
+func (r *MemoryChecklistRepository) GetAll(ctx context.Context, todoID, userID int) ([]ChecklistItem, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	todo, ok := r.store.todo(todoID, userID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	r.store.loadChecklist(&todo)
+
+	return todo.Checklist, nil
+}
+
+func (r *MemoryChecklistRepository) Get(ctx context.Context, ID, todoID, userID int) (*ChecklistItem, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	_, ok := r.store.todo(todoID, userID)
+	item, found := r.store.checklist[ID]
+	if !ok || !found || item.TodoID != todoID {
+		return nil, ErrNotFound
+	}
+
+	return &item, nil
+}
+
+func (r *MemoryChecklistRepository) Insert(ctx context.Context, item *ChecklistItem, userID int) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := timeNow()
+	if err := r.store.touchTodo(item.TodoID, userID, now); err != nil {
+		return err
+	}
+
+	item.Position = 1
+	for _, other := range r.store.checklist {
+		if other.TodoID == item.TodoID {
+			item.Position = max(item.Position, other.Position+1)
+		}
+	}
+	item.ID = r.store.newID("checklist_items")
+	item.CreatedAt, item.UpdatedAt = now, now
+	r.store.checklist[item.ID] = *item
+
+	return nil
+}
+
+func (r *MemoryChecklistRepository) Update(ctx context.Context, item *ChecklistItem, userID int) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	existing, ok := r.store.checklist[item.ID]
+	if !ok || existing.TodoID != item.TodoID {
+		return ErrNotFound
+	}
+	now := timeNow()
+	if err := r.store.touchTodo(item.TodoID, userID, now); err != nil {
+		return err
+	}
+
+	existing.Text, existing.Done = item.Text, item.Done
+	existing.UpdatedAt = now
+	r.store.checklist[item.ID] = existing
+	item.UpdatedAt = now
+
+	return nil
+}
+
+func (r *MemoryChecklistRepository) Reorder(ctx context.Context, todoID, userID int, itemIDs []int) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	todo, ok := r.store.todo(todoID, userID)
+	if !ok {
+		return ErrNotFound
+	}
+	r.store.loadChecklist(&todo)
+	if len(todo.Checklist) != len(itemIDs) || !distinct(itemIDs) {
+		return ErrChecklistOrder
+	}
+	for _, id := range itemIDs {
+		if item, ok := r.store.checklist[id]; !ok || item.TodoID != todoID {
+			return ErrChecklistOrder
+		}
+	}
+
+	now := timeNow()
+	for i, id := range itemIDs {
+		item := r.store.checklist[id]
+		item.Position, item.UpdatedAt = i+1, now
+		r.store.checklist[id] = item
+	}
+
+	return r.store.touchTodo(todoID, userID, now)
+}
+
+func (r *MemoryChecklistRepository) Delete(ctx context.Context, ID, todoID, userID int) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	item, ok := r.store.checklist[ID]
+	if !ok || item.TodoID != todoID {
+		return ErrNotFound
+	}
+	if err := r.store.touchTodo(todoID, userID, timeNow()); err != nil {
+		return err
+	}
+	delete(r.store.checklist, ID)
+
+	return nil
+}
+
+// touchTodo bumps the UpdatedAt of one of the user's todos. Callers hold mu.
+func (s *memoryStore) touchTodo(todoID, userID int, now time.Time) error {
+	todo, ok := s.todo(todoID, userID)
+	if !ok {
+		return ErrNotFound
+	}
+	todo.UpdatedAt = now
+	todo.Version++
+	s.todos[todoID] = todo
+
+	return nil
+}
+
+// loadChecklist fills in the Checklist and Progress of todo. Callers hold mu.
+func (s *memoryStore) loadChecklist(todo *Todo) {
+	todo.Checklist = []ChecklistItem{}
+	for _, item := range s.checklist {
+		if item.TodoID == todo.ID {
+			todo.Checklist = append(todo.Checklist, item)
+		}
+	}
+	sort.Slice(todo.Checklist, func(i, j int) bool {
+		a, b := todo.Checklist[i], todo.Checklist[j]
+		if a.Position != b.Position {
+			return a.Position < b.Position
+		}
+		return a.ID < b.ID
+	})
+	todo.Progress = checklistProgress(todo.Checklist)
+}
+
+// MemoryRefreshTokenRepository is an in-memory RefreshTokenRepository.
+type MemoryRefreshTokenRepository struct {
+	store *memoryStore
+}
+
+func (r *MemoryRefreshTokenRepository) Issue(ctx context.Context, userID, sessionID int, ttl time.Duration) (string, error) {
+	if err := checkContext(ctx); err != nil {
+		return "", err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	token, _, err := r.store.insertToken(userID, sessionID, ttl)
+
+	return token, err
+}
+
+// insertToken mirrors SQLRefreshTokenRepository.insertToken. Callers hold mu.
+func (s *memoryStore) insertToken(userID, sessionID int, ttl time.Duration) (string, *RefreshToken, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := timeNow()
+	stored := RefreshToken{
+		ID:        s.newID("refresh_tokens"),
+		UserID:    userID,
+		SessionID: sessionID,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	s.refreshTokens[hashToken(token)] = stored
+
+	return token, &stored, nil
+}
+
+func (r *MemoryRefreshTokenRepository) Rotate(ctx context.Context, token string, ttl time.Duration) (string, *RefreshToken, error) {
+	if err := checkContext(ctx); err != nil {
+		return "", nil, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	hash := hashToken(token)
+	current, ok := r.store.refreshTokens[hash]
+	if !ok || current.RevokedAt != nil || !current.ExpiresAt.After(timeNow()) {
+		return "", nil, ErrTokenInvalid
+	}
+	if current.UsedAt != nil {
+		r.store.endSession(current.SessionID)
+		return "", nil, ErrTokenReused
+	}
+
+	now := timeNow()
+	current.UsedAt = &now
+	r.store.refreshTokens[hash] = current
+
+	next, stored, err := r.store.insertToken(current.UserID, current.SessionID, ttl)
+	if err != nil {
+		return "", nil, err
+	}
+	if session, ok := r.store.sessions[stored.SessionID]; ok {
+		session.ExpiresAt = stored.ExpiresAt
+		r.store.sessions[stored.SessionID] = session
+	}
+
+	return next, stored, nil
+}
+
+func (r *MemoryRefreshTokenRepository) Revoke(ctx context.Context, token string, userID int) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.refreshTokens[hashToken(token)]
+	if !ok || stored.UserID != userID {
+		return ErrTokenInvalid
+	}
+	r.store.endSession(stored.SessionID)
+
+	return nil
+}
+
+func (r *MemoryRefreshTokenRepository) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	if err := checkContext(ctx); err != nil {
+		return 0, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	deleted := 0
+	for hash, token := range r.store.refreshTokens {
+		if token.ExpiresAt.Before(before) {
+			delete(r.store.refreshTokens, hash)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// MemorySessionRepository is an in-memory SessionRepository.
+type MemorySessionRepository struct {
+	store *memoryStore
+}
+
+func (r *MemorySessionRepository) Insert(ctx context.Context, session *Session) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := timeNow()
+	session.ID = r.store.newID("sessions")
+	session.CreatedAt, session.LastSeenAt = now, now
+	session.ExpiresAt = session.ExpiresAt.UTC()
+	r.store.sessions[session.ID] = *session
+
+	return nil
+}
+
+// activeSession returns one of the user's sessions unless it was revoked or
+// expired. Callers hold mu.
+func (s *memoryStore) activeSession(ID, userID int, now time.Time) (Session, bool) {
+	session, ok := s.sessions[ID]
+	if !ok || session.UserID != userID || session.RevokedAt != nil || !session.ExpiresAt.After(now) {
+		return Session{}, false
+	}
+
+	return session, true
+}
+
+func (r *MemorySessionRepository) GetAll(ctx context.Context, userID int) ([]Session, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	sessions := []Session{}
+	for id := range r.store.sessions {
+		if session, ok := r.store.activeSession(id, userID, timeNow()); ok {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastSeenAt.Equal(sessions[j].LastSeenAt) {
+			return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+		}
+		return sessions[i].ID > sessions[j].ID
+	})
+
+	return sessions, nil
+}
+
+func (r *MemorySessionRepository) Touch(ctx context.Context, ID, userID int, now time.Time) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	session, ok := r.store.activeSession(ID, userID, now)
+	if !ok {
+		return ErrNotFound
+	}
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval {
+		session.LastSeenAt = now.UTC()
+		r.store.sessions[ID] = session
+	}
+
+	return nil
+}
+
+func (r *MemorySessionRepository) Revoke(ctx context.Context, ID, userID int) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	session, ok := r.store.sessions[ID]
+	if !ok || session.UserID != userID || session.RevokedAt != nil {
+		return ErrNotFound
+	}
+	r.store.endSession(ID)
+
+	return nil
+}
+
+// endSession mirrors sqlStore.endSession. Callers hold mu.
+func (s *memoryStore) endSession(ID int) {
+	now := timeNow()
+	if session, ok := s.sessions[ID]; ok && session.RevokedAt == nil {
+		session.RevokedAt = &now
+		s.sessions[ID] = session
+	}
+	for hash, token := range s.refreshTokens {
+		if token.SessionID == ID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+			s.refreshTokens[hash] = token
+		}
+	}
+}
+
+func (r *MemorySessionRepository) RevokeAll(ctx context.Context, userID, exceptID int) (int, error) {
+	if err := checkContext(ctx); err != nil {
+		return 0, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	return r.store.revokeSessions(userID, exceptID), nil
+}
+
+// revokeSessions mirrors sqlStore.revokeSessions. Callers hold mu.
+func (s *memoryStore) revokeSessions(userID, exceptID int) int {
+	revoked := 0
+	for id := range s.sessions {
+		if _, ok := s.activeSession(id, userID, timeNow()); ok && id != exceptID {
+			s.endSession(id)
+			revoked++
+		}
+	}
+
+	return revoked
+}
+
+func (r *MemorySessionRepository) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	if err := checkContext(ctx); err != nil {
+		return 0, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	deleted := 0
+	for id, session := range r.store.sessions {
+		if session.ExpiresAt.Before(before) || (session.RevokedAt != nil && session.RevokedAt.Before(before)) {
+			delete(r.store.sessions, id)
+			deleted++
+		}
+	}
+
+	// Mirror the ON DELETE CASCADE of refresh_tokens
+	for hash, token := range r.store.refreshTokens {
+		if _, ok := r.store.sessions[token.SessionID]; !ok {
+			delete(r.store.refreshTokens, hash)
+		}
+	}
+
+	return deleted, nil
+}
+
+// MemoryPasswordResetRepository is an in-memory PasswordResetRepository.
+type MemoryPasswordResetRepository struct {
+	store *memoryStore
+}
+
+func (r *MemoryPasswordResetRepository) Issue(ctx context.Context, userID int, ttl time.Duration) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := checkContext(ctx); err != nil {
+		return "", err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for hash, reset := range r.store.passwordResets {
+		if reset.UserID == userID {
+			delete(r.store.passwordResets, hash)
+		}
+	}
+	r.store.passwordResets[hashToken(token)] = passwordReset{UserID: userID, ExpiresAt: timeNow().Add(ttl)}
+
+	return token, nil
+}
+
+func (r *MemoryPasswordResetRepository) Reset(ctx context.Context, token, password string) error {
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	hash := hashToken(token)
+	reset, ok := r.store.passwordResets[hash]
+	if !ok || reset.UsedAt != nil || !reset.ExpiresAt.After(timeNow()) {
+		return ErrResetTokenInvalid
+	}
+	user, ok := r.store.users[reset.UserID]
+	if !ok {
+		return ErrNotFound
+	}
+
+	now := timeNow()
+	reset.UsedAt = &now
+	r.store.passwordResets[hash] = reset
+
+	user.Password = hashedPassword
+	user.UpdatedAt = now
+	r.store.users[user.ID] = user
+	r.store.revokeSessions(user.ID, 0)
+
+	return nil
+}
+
+func (r *MemoryPasswordResetRepository) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	if err := checkContext(ctx); err != nil {
+		return 0, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	deleted := 0
+	for hash, reset := range r.store.passwordResets {
+		if reset.ExpiresAt.Before(before) {
+			delete(r.store.passwordResets, hash)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// MemoryRevocationStore is an in-memory RevocationStore.
+type MemoryRevocationStore struct {
+	store *memoryStore
+}
+
+func (r *MemoryRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.revoked[jti] = expiresAt
+
+	return nil
+}
+
+func (r *MemoryRevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	if err := checkContext(ctx); err != nil {
+		return false, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	_, ok := r.store.revoked[jti]
+
+	return ok, nil
+}
+
+func (r *MemoryRevocationStore) Prune(ctx context.Context, before time.Time) (int, error) {
+	if err := checkContext(ctx); err != nil {
+		return 0, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	pruned := 0
+	for jti, expiresAt := range r.store.revoked {
+		if expiresAt.Before(before) {
+			delete(r.store.revoked, jti)
+			pruned++
+		}
+	}
+
+	return pruned, nil
+}
@@ -1,26 +1,153 @@
 package data
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
 const dbTimeout = time.Second * 3
 
-var db *sql.DB
+// timeNow stamps created_at and friends in UTC, so timestamps written by
+// this package compare correctly with the UTC bounds used in filters and
+// cursors (SQLite compares them as text).
+func timeNow() time.Time {
+	return time.Now().UTC()
+}
+
+// driverPostgres is the database/sql driver name used for Postgres
+const driverPostgres = "pgx"
+
+var (
+	// ErrNotFound is returned when a record does not exist or belongs to another user
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicate is returned by repositories that enforce uniqueness themselves
+	ErrDuplicate = errors.New("duplicate value violates unique constraint")
+	// ErrVersionConflict is returned when a record changed since the version
+	// the caller based its change on
+	ErrVersionConflict = errors.New("the record has been changed since it was read")
+	// ErrCanceled is returned when the caller's context was canceled before the query finished
+	ErrCanceled = errors.New("the request was canceled")
+	// ErrTimeout is returned when a query did not finish before its deadline
+	ErrTimeout = errors.New("the database did not respond in time")
+)
+
+// contextErr replaces *err with ErrCanceled or ErrTimeout when the query
+// failed because ctx is done, so callers can tell those apart from genuine
+// database errors. The cancellation cause is kept in the chain.
+func contextErr(ctx context.Context, err *error) {
+	if *err == nil || ctx.Err() == nil {
+		return
+	}
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		*err = ErrTimeout
+	case errors.Is(ctx.Err(), context.Canceled):
+		*err = fmt.Errorf("%w: %w", ErrCanceled, context.Cause(ctx))
+	}
+}
 
-func New(dbPool *sql.DB) Models {
-	db = dbPool
+// New returns repositories backed by the given connection pool. Several
+// Models can coexist, each talking to its own database.
+func New(dbPool *sql.DB, driver string) Models {
+	store := sqlStore{db: dbPool, postgres: driver == driverPostgres}
 
 	return Models{
-		User: User{},
-		Priority: Priority{},
-		Todo: Todo{},
+		User:      &SQLUserRepository{store},
+		Priority:  &SQLPriorityRepository{store},
+		Todo:      &SQLTodoRepository{store},
+		Tag:       &SQLTagRepository{store},
+		List:      &SQLListRepository{store},
+		Checklist: &SQLChecklistRepository{store},
+
+		RefreshToken: &SQLRefreshTokenRepository{store},
+		Revocation:   &SQLRevocationStore{store},
+		Session:      &SQLSessionRepository{store},
+
+		PasswordReset: &SQLPasswordResetRepository{store},
+	}
+}
+
+// NewMemory returns repositories that keep everything in process memory,
+// seeded with the default priorities. Handy as a fake in handler tests.
+func NewMemory() Models {
+	store := newMemoryStore()
+
+	return Models{
+		User:      &MemoryUserRepository{store},
+		Priority:  &MemoryPriorityRepository{store},
+		Todo:      &MemoryTodoRepository{store},
+		Tag:       &MemoryTagRepository{store},
+		List:      &MemoryListRepository{store},
+		Checklist: &MemoryChecklistRepository{store},
+
+		RefreshToken: &MemoryRefreshTokenRepository{store},
+		Revocation:   &MemoryRevocationStore{store},
+		Session:      &MemorySessionRepository{store},
+
+		PasswordReset: &MemoryPasswordResetRepository{store},
 	}
 }
 
 type Models struct {
-	User User
-	Priority Priority
-	Todo Todo
+	User      UserRepository
+	Priority  PriorityRepository
+	Todo      TodoRepository
+	Tag       TagRepository
+	List      ListRepository
+	Checklist ChecklistRepository
+
+	RefreshToken RefreshTokenRepository
+	// Revocation defaults to the database; it can be swapped for another
+	// store, such as a RedisRevocationStore
+	Revocation RevocationStore
+	Session    SessionRepository
+
+	PasswordReset PasswordResetRepository
+}
+
+// dbtx is satisfied by both *sql.DB and *sql.Tx, so helpers can run inside
+// or outside a transaction.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// sqlStore carries the connection pool and dialect shared by the SQL repositories.
+type sqlStore struct {
+	db       *sql.DB
+	postgres bool
+}
+
+// rebind rewrites the "?" placeholders used throughout this package into the
+// numbered "$1, $2, ..." form when running against Postgres.
+func (s sqlStore) rebind(query string) string {
+	if !s.postgres {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+
+	n := 0
+	inQuote := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inQuote = !inQuote
+		case r == '?' && !inQuote:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
 }
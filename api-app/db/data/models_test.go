@@ -0,0 +1,60 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestContextErr(t *testing.T) {
+	failure := errors.New("connection reset")
+	shutdown := errors.New("shutting down")
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	stopped, stop := context.WithCancelCause(context.Background())
+	stop(shutdown)
+	expired, expire := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer expire()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want []error
+	}{
+		{"no error", canceled, nil, nil},
+		{"live context", context.Background(), failure, []error{failure}},
+		{"canceled", canceled, failure, []error{ErrCanceled, context.Canceled}},
+		{"canceled with a cause", stopped, failure, []error{ErrCanceled, shutdown}},
+		{"deadline exceeded", expired, failure, []error{ErrTimeout}},
+	}
+	for _, tt := range tests {
+		err := tt.err
+		contextErr(tt.ctx, &err)
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("%s: contextErr() set %v, want nil", tt.name, err)
+			}
+			continue
+		}
+		for _, want := range tt.want {
+			if !errors.Is(err, want) {
+				t.Errorf("%s: contextErr() set %v, want it to wrap %v", tt.name, err, want)
+			}
+		}
+	}
+
+	// A deadline is no cancellation, and the other way around
+	err := failure
+	contextErr(expired, &err)
+	if errors.Is(err, ErrCanceled) {
+		t.Errorf("contextErr() of an expired context set %v, want no ErrCanceled", err)
+	}
+	err = failure
+	contextErr(canceled, &err)
+	if errors.Is(err, ErrTimeout) {
+		t.Errorf("contextErr() of a canceled context set %v, want no ErrTimeout", err)
+	}
+}
@@ -0,0 +1,154 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrResetTokenInvalid is returned for a password reset token that doesn't
+// exist, has expired or was already used
+var ErrResetTokenInvalid = errors.New("the password reset link is invalid or has expired")
+
+// passwordReset is a stored reset token.
+type passwordReset struct {
+	UserID    int
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// PasswordResetRepository stores password reset tokens. Like refresh tokens,
+// only their hash is kept.
+type PasswordResetRepository interface {
+	// Issue creates a reset token for the user, valid for ttl, and returns
+	// it. Earlier tokens of the user stop working.
+	Issue(ctx context.Context, userID int, ttl time.Duration) (string, error)
+	// Reset uses up a reset token, sets the password of its user and signs
+	// all of the user's sessions out
+	Reset(ctx context.Context, token, password string) error
+	// DeleteExpired deletes the tokens that expired before the given time,
+	// for all users, and returns how many
+	DeleteExpired(ctx context.Context, before time.Time) (int, error)
+}
+
+// SQLPasswordResetRepository is the database-backed PasswordResetRepository.
+type SQLPasswordResetRepository struct {
+	sqlStore
+}
+
+func (r *SQLPasswordResetRepository) Issue(ctx context.Context, userID int, ttl time.Duration) (token string, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	token, err = newToken()
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	// Only the latest link sent works
+	if _, err = tx.ExecContext(ctx, r.rebind("DELETE FROM password_resets WHERE user_id = ?"), userID); err != nil {
+		return "", err
+	}
+
+	now := timeNow()
+	query := r.rebind("INSERT INTO password_resets (user_id, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?)")
+	if _, err = tx.ExecContext(ctx, query, userID, hashToken(token), now.Add(ttl), now); err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (r *SQLPasswordResetRepository) Reset(ctx context.Context, token, password string) (err error) {
+	// Hashing is deliberately slow, so it happens before the timeout starts
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	var resetID, userID int
+	var expiresAt time.Time
+	var usedAt *time.Time
+	query := r.rebind("SELECT id, user_id, expires_at, used_at FROM password_resets WHERE token_hash = ?")
+	err = tx.QueryRowContext(ctx, query, hashToken(token)).Scan(&resetID, &userID, &expiresAt, &usedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrResetTokenInvalid
+	}
+	if err != nil {
+		return err
+	}
+	if usedAt != nil || !expiresAt.After(timeNow()) {
+		return ErrResetTokenInvalid
+	}
+
+	// A request racing this one with the same token may have used it first
+	now := timeNow()
+	result, err := tx.ExecContext(ctx, r.rebind("UPDATE password_resets SET used_at = ? WHERE id = ? AND used_at IS NULL"), now, resetID)
+	if err != nil {
+		return err
+	}
+	if err = expectRows(result); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			err = ErrResetTokenInvalid
+		}
+		return err
+	}
+
+	result, err = tx.ExecContext(ctx, r.rebind("UPDATE users SET password = ?, updated_at = ? WHERE id = ?"), hashedPassword, now, userID)
+	if err != nil {
+		return err
+	}
+	if err = expectRows(result); err != nil {
+		return err
+	}
+
+	// Whoever knew the old password is signed out
+	if _, err = r.revokeSessions(ctx, tx, userID, 0); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLPasswordResetRepository) DeleteExpired(ctx context.Context, before time.Time) (deleted int, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	result, err := r.db.ExecContext(ctx, r.rebind("DELETE FROM password_resets WHERE expires_at < ?"), before.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(rowsAffected), nil
+}
@@ -0,0 +1,63 @@
+package data_test
+
+import (
+	"context"
+	"task-app/db/data"
+	"testing"
+	"time"
+)
+
+func TestPasswordResetRepository(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		ada := newUser(t, models, "ada")
+		session, refreshToken := newSession(t, models, ada)
+
+		token, err := models.PasswordReset.Issue(ctx, ada, time.Hour)
+		if err != nil || token == "" {
+			t.Fatalf("Issue() = %q, %v", token, err)
+		}
+		if err := models.PasswordReset.Reset(ctx, token, "new password"); err != nil {
+			t.Fatalf("Reset() error = %v", err)
+		}
+
+		user, err := models.User.GetByID(ctx, ada)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for password, want := range map[string]bool{"new password": true, "password": false} {
+			if ok, err := user.PasswordMatches(password); err != nil || ok != want {
+				t.Errorf("PasswordMatches(%q) after Reset() = %v, %v; want %v", password, ok, err, want)
+			}
+		}
+
+		// Every session was signed out
+		wantErr(t, "Session.Touch() after Reset()", models.Session.Touch(ctx, session.ID, ada, time.Now()), data.ErrNotFound)
+		_, _, err = models.RefreshToken.Rotate(ctx, refreshToken, time.Hour)
+		wantErr(t, "RefreshToken.Rotate() after Reset()", err, data.ErrTokenInvalid)
+
+		// A token works once
+		wantErr(t, "second Reset()", models.PasswordReset.Reset(ctx, token, "another"), data.ErrResetTokenInvalid)
+		wantErr(t, "Reset() with an unknown token", models.PasswordReset.Reset(ctx, "not-a-token", "another"), data.ErrResetTokenInvalid)
+
+		// Only the latest token issued works
+		first, err := models.PasswordReset.Issue(ctx, ada, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := models.PasswordReset.Issue(ctx, ada, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		wantErr(t, "Reset() with a replaced token", models.PasswordReset.Reset(ctx, first, "another"), data.ErrResetTokenInvalid)
+
+		// An expired token doesn't work, and goes with DeleteExpired
+		expired, err := models.PasswordReset.Issue(ctx, ada, -time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantErr(t, "Reset() with an expired token", models.PasswordReset.Reset(ctx, expired, "another"), data.ErrResetTokenInvalid)
+		if deleted, err := models.PasswordReset.DeleteExpired(ctx, time.Now()); err != nil || deleted != 1 {
+			t.Fatalf("DeleteExpired() = %d, %v; want 1", deleted, err)
+		}
+	})
+}
@@ -0,0 +1,340 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidMove is returned when a todo is moved next to itself, next to a
+// todo of another list or between two todos in the wrong order.
+var ErrInvalidMove = errors.New("after_id must come before before_id in the todo's list, and neither can be the moved todo")
+
+// Positions are fractional keys ordering the todos of each list: strings of
+// base-62 digits read as the digits after a radix point, compared byte by
+// byte (the column uses the "C" collation on Postgres). A key can always be
+// found between two others, so moving a todo updates just its own row. Keys never end in the lowest digit,
+// which would leave no room before them.
+const positionDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// maxPositionLength is how long a key may grow, through repeated moves into
+// the same gap, before the positions in its list are rebalanced.
+const maxPositionLength = 24
+
+// positionBetween returns a key sorting after lower and before upper. An
+// empty lower means the start of the list, an empty upper its end.
+func positionBetween(lower, upper string) (string, error) {
+	if upper != "" && lower >= upper {
+		return "", fmt.Errorf("no position between %q and %q", lower, upper)
+	}
+
+	return midpoint(lower, upper), nil
+}
+
+func midpoint(lower, upper string) string {
+	// Keep the common prefix, lower being padded with the lowest digit
+	if upper != "" {
+		n := 0
+		for n < len(upper) && digitAt(lower, n) == upper[n] {
+			n++
+		}
+		if n > 0 {
+			return upper[:n] + midpoint(tail(lower, n), upper[n:])
+		}
+	}
+
+	low := 0
+	if lower != "" {
+		low = strings.IndexByte(positionDigits, lower[0])
+	}
+	high := len(positionDigits)
+	if upper != "" {
+		high = strings.IndexByte(positionDigits, upper[0])
+	}
+
+	if high-low > 1 {
+		return string(positionDigits[(low+high+1)/2])
+	}
+	// The first digits are consecutive: a longer upper still has room
+	// below it at its first digit, otherwise go one digit deeper
+	if len(upper) > 1 {
+		return upper[:1]
+	}
+	return string(positionDigits[low]) + midpoint(tail(lower, 1), "")
+}
+
+func digitAt(key string, i int) byte {
+	if i < len(key) {
+		return key[i]
+	}
+	return positionDigits[0]
+}
+
+func tail(key string, n int) string {
+	if n < len(key) {
+		return key[n:]
+	}
+	return ""
+}
+
+// spreadPositions returns n ascending keys of equal length spaced evenly
+// apart, leaving room for many moves before keys grow again.
+func spreadPositions(n int) []string {
+	base := len(positionDigits)
+
+	// Aim for at least a full digit of room between neighbours
+	width, capacity := 1, base
+	for capacity < (n+1)*base {
+		width++
+		capacity *= base
+	}
+	step := capacity / (n + 1)
+
+	keys := make([]string, n)
+	for i := range keys {
+		value := (i + 1) * step
+		if value%base == 0 {
+			value++ // Keep the last digit above the lowest one
+		}
+
+		key := make([]byte, width)
+		for j := width - 1; j >= 0; j-- {
+			key[j] = positionDigits[value%base]
+			value /= base
+		}
+		keys[i] = string(key)
+	}
+
+	return keys
+}
+
+// lastPosition returns a key after all the todos of the list outside the
+// trash, rebalancing first when that key would be too long.
+func (s sqlStore) lastPosition(ctx context.Context, tx dbtx, listID int) (string, error) {
+	for rebalanced := false; ; rebalanced = true {
+		last, err := s.maxPosition(ctx, tx, listID)
+		if err != nil {
+			return "", err
+		}
+
+		key, err := positionBetween(last, "")
+		if err != nil || len(key) <= maxPositionLength || rebalanced {
+			return key, err
+		}
+		if err = s.rebalancePositions(ctx, tx, listID); err != nil {
+			return "", err
+		}
+	}
+}
+
+// maxPosition returns the last key of the todos of the list outside the
+// trash, or "" when there are none.
+func (s sqlStore) maxPosition(ctx context.Context, tx dbtx, listID int) (string, error) {
+	var last sql.NullString
+	err := tx.QueryRowContext(ctx, s.rebind("SELECT MAX(position) FROM todos WHERE list_id = ? AND deleted_at IS NULL"), listID).Scan(&last)
+
+	return last.String, err
+}
+
+// listTodoIDs returns the IDs of all the todos of the list, trash included,
+// in their order.
+func (s sqlStore) listTodoIDs(ctx context.Context, tx dbtx, listID int) ([]int, error) {
+	rows, err := tx.QueryContext(ctx, s.rebind("SELECT id FROM todos WHERE list_id = ? ORDER BY position, id"), listID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// rebalancePositions rewrites the positions of all the todos of the list with
+// short, evenly spaced keys, keeping their order.
+func (s sqlStore) rebalancePositions(ctx context.Context, tx dbtx, listID int) error {
+	ids, err := s.listTodoIDs(ctx, tx, listID)
+	if err != nil {
+		return err
+	}
+
+	// Leaves updated_at and version alone: the user didn't change these
+	// todos, whose order stays the same
+	query := s.rebind("UPDATE todos SET position = ? WHERE id = ?")
+	for i, key := range spreadPositions(len(ids)) {
+		if _, err := tx.ExecContext(ctx, query, key, ids[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// moveTodosToList moves the user's todos from one list to the end of another,
+// in their order, as deleting a list does, recording the change in the
+// history of each. It returns how many todos it moved.
+func (s sqlStore) moveTodosToList(ctx context.Context, tx dbtx, userID, from, to int) (int, error) {
+	last, err := s.maxPosition(ctx, tx, to)
+	if err != nil {
+		return 0, err
+	}
+	ids, err := s.listTodoIDs(ctx, tx, from)
+	if err != nil {
+		return 0, err
+	}
+
+	// Any key made longer by a suffix sorts after the last one, the suffixes
+	// keep the order
+	keys := spreadPositions(len(ids))
+	query := s.rebind("UPDATE todos SET position = ? WHERE id = ?")
+	for i, key := range keys {
+		if _, err = tx.ExecContext(ctx, query, last+key, ids[i]); err != nil {
+			return 0, err
+		}
+	}
+
+	moved, err := s.reassignTodos(ctx, tx, userID, "list_id", from, to)
+	if err != nil {
+		return 0, err
+	}
+	if len(keys) > 0 && len(last+keys[0]) > maxPositionLength {
+		if err = s.rebalancePositions(ctx, tx, to); err != nil {
+			return 0, err
+		}
+	}
+
+	return moved, nil
+}
+
+// Move places one of the user's todos between two others of its list. afterID
+// is the todo it should follow and beforeID the one it should precede; either
+// can be 0, in which case the todo goes right after afterID or right before
+// beforeID.
+func (r *SQLTodoRepository) Move(ctx context.Context, ID, userID, afterID, beforeID int) (err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	if ID == afterID || ID == beforeID || (afterID == 0 && beforeID == 0) {
+		return ErrInvalidMove
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	position := func(id int, field string) (key string, listID int, err error) {
+		err = tx.QueryRowContext(ctx, r.rebind("SELECT position, list_id FROM todos WHERE id = ? AND user_id = ? AND deleted_at IS NULL"), id, userID).Scan(&key, &listID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", 0, fmt.Errorf("%s: %w", field, ErrNotFound)
+		}
+		return key, listID, err
+	}
+
+	from, listID, err := position(ID, "todo")
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	// neighbour returns the key of a todo to move next to, which has to be in
+	// the same list
+	neighbour := func(id int, field string) (string, error) {
+		key, neighbourList, err := position(id, field)
+		if err == nil && neighbourList != listID {
+			return "", ErrInvalidMove
+		}
+		return key, err
+	}
+
+	// neighbours finds the keys to move between, ignoring the trash; a second
+	// pass after rebalancing copes with todos that ended up sharing a key
+	neighbours := func() (lower, upper string, err error) {
+		if afterID != 0 {
+			if lower, err = neighbour(afterID, "after_id"); err != nil {
+				return "", "", err
+			}
+		}
+		if beforeID != 0 {
+			if upper, err = neighbour(beforeID, "before_id"); err != nil {
+				return "", "", err
+			}
+		}
+
+		var next sql.NullString
+		switch {
+		case beforeID == 0:
+			err = tx.QueryRowContext(ctx, r.rebind("SELECT MIN(position) FROM todos WHERE list_id = ? AND id <> ? AND position > ? AND deleted_at IS NULL"), listID, ID, lower).Scan(&next)
+			upper = next.String
+		case afterID == 0:
+			err = tx.QueryRowContext(ctx, r.rebind("SELECT MAX(position) FROM todos WHERE list_id = ? AND id <> ? AND position < ? AND deleted_at IS NULL"), listID, ID, upper).Scan(&next)
+			lower = next.String
+		}
+
+		return lower, upper, err
+	}
+
+	lower, upper, err := neighbours()
+	if err != nil {
+		return err
+	}
+	if afterID != 0 && beforeID != 0 && lower > upper {
+		return ErrInvalidMove
+	}
+	if upper != "" && lower >= upper {
+		if err = r.rebalancePositions(ctx, tx, listID); err != nil {
+			return err
+		}
+		if lower, upper, err = neighbours(); err != nil {
+			return err
+		}
+		if upper != "" && lower >= upper {
+			return ErrInvalidMove
+		}
+	}
+
+	key, err := positionBetween(lower, upper)
+	if err != nil {
+		return err
+	}
+
+	query := r.rebind("UPDATE todos SET position = ?, updated_at = ?, version = version + 1 WHERE id = ? AND user_id = ?")
+	if _, err = tx.ExecContext(ctx, query, key, timeNow(), ID, userID); err != nil {
+		return err
+	}
+
+	if len(key) > maxPositionLength {
+		if err = r.rebalancePositions(ctx, tx, listID); err != nil {
+			return err
+		}
+		if key, _, err = position(ID, "todo"); err != nil {
+			return err
+		}
+	}
+
+	// Rebalancing leaves no event: the order it keeps is what undo restores
+	change, err := fieldChange(from, key)
+	if err != nil {
+		return err
+	}
+	err = r.recordEvent(ctx, tx, &TodoEvent{TodoID: ID, UserID: userID, Action: TodoMoved, Changes: map[string]FieldChange{"position": change}})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
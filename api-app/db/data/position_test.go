@@ -0,0 +1,276 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"task-app/db/dbtest"
+	"testing"
+)
+
+// checkBetween fails the test unless key is a valid key sorting between
+// lower and upper, either of which may be empty for an open end.
+func checkBetween(t *testing.T, lower, key, upper string) {
+	t.Helper()
+
+	if key <= lower || (upper != "" && key >= upper) {
+		t.Fatalf("key %q doesn't sort between %q and %q", key, lower, upper)
+	}
+	if key == "" || key[len(key)-1] == positionDigits[0] {
+		t.Fatalf("key %q ends in the lowest digit, leaving no room before it", key)
+	}
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(positionDigits, key[i]) < 0 {
+			t.Fatalf("key %q has a character outside the alphabet", key)
+		}
+	}
+}
+
+func TestPositionBetween(t *testing.T) {
+	tests := []struct {
+		lower, upper string
+		want         string
+	}{
+		{"", "", "V"},
+		{"V", "", "l"},
+		{"", "V", "G"},
+		// Adjacent digits leave no room at the same length
+		{"a", "b", "aV"},
+		{"a0", "a1", "a0V"},
+		{"az", "b", "azV"},
+		// A longer upper has room below it at its first digit
+		{"a", "bV", "b"},
+		// The ends of the alphabet
+		{"", "1", "0V"},
+		{"", "01", "00V"},
+		{"y", "", "z"},
+		{"z", "", "zV"},
+		{"zzz", "", "zzzV"},
+	}
+	for _, tt := range tests {
+		key, err := positionBetween(tt.lower, tt.upper)
+		if err != nil || key != tt.want {
+			t.Errorf("positionBetween(%q, %q) = %q, %v; want %q", tt.lower, tt.upper, key, err, tt.want)
+			continue
+		}
+		checkBetween(t, tt.lower, key, tt.upper)
+	}
+
+	for _, bounds := range [][2]string{{"b", "a"}, {"a", "a"}, {"a1", "a"}} {
+		if key, err := positionBetween(bounds[0], bounds[1]); err == nil {
+			t.Errorf("positionBetween(%q, %q) = %q, want an error", bounds[0], bounds[1], key)
+		}
+	}
+}
+
+func TestPositionBetweenRepeatedly(t *testing.T) {
+	// Moving todos into the same gap over and over: right after the lower
+	// neighbour, right before the upper one, and alternating
+	tests := []struct {
+		name string
+		next func(lower, key, upper string) (string, string)
+	}{
+		{"after lower", func(lower, key, upper string) (string, string) { return lower, key }},
+		{"before upper", func(lower, key, upper string) (string, string) { return key, upper }},
+		{"alternating", func(lower, key, upper string) (string, string) {
+			if len(key)%2 == 0 {
+				return lower, key
+			}
+			return key, upper
+		}},
+	}
+	for _, tt := range tests {
+		lower, upper := "a", "b"
+		for i := 0; i < 200; i++ {
+			key, err := positionBetween(lower, upper)
+			if err != nil {
+				t.Fatalf("%s: positionBetween(%q, %q) error = %v", tt.name, lower, upper, err)
+			}
+			checkBetween(t, lower, key, upper)
+			lower, upper = tt.next(lower, key, upper)
+		}
+		// Each insertion adds about one digit at worst, which is what
+		// maxPositionLength and rebalancing are there for
+		if len(lower) > 202 || len(upper) > 202 {
+			t.Errorf("%s: keys grew to %d and %d digits", tt.name, len(lower), len(upper))
+		}
+	}
+
+	// Appending halves the room left at the end every time, so keys keep
+	// growing; lastPosition rebalances them before they get too long
+	store := newMemoryStore()
+	for id := 1; id <= 1000; id++ {
+		key, err := store.lastPosition(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(key) > maxPositionLength {
+			t.Fatalf("append %d got a %d digit key, want at most %d", id, len(key), maxPositionLength)
+		}
+		store.todos[id] = Todo{ID: id, UserID: 1, ListID: 1, Position: key}
+	}
+	var keys []string
+	for _, todo := range store.todos {
+		keys = append(keys, todo.Position)
+	}
+	slices.Sort(keys)
+	if len(slices.Compact(keys)) != 1000 {
+		t.Error("appending gave two todos the same key")
+	}
+}
+
+func TestSpreadPositions(t *testing.T) {
+	base := len(positionDigits)
+	// The keys are as short as leaves a digit of room between neighbours
+	tests := []struct{ n, width int }{
+		{0, 0}, {1, 2}, {2, 2}, {base - 1, 2}, {base, 3}, {base + 1, 3}, {base*base - 1, 3}, {base * base, 4}, {5000, 4},
+	}
+	for _, tt := range tests {
+		n := tt.n
+		keys := spreadPositions(n)
+		if len(keys) != n {
+			t.Fatalf("spreadPositions(%d) returned %d keys", n, len(keys))
+		}
+
+		for i, key := range keys {
+			lower := ""
+			if i > 0 {
+				lower = keys[i-1]
+			}
+			checkBetween(t, lower, key, "")
+			if len(key) != len(keys[0]) {
+				t.Fatalf("spreadPositions(%d): keys %q and %q differ in length", n, keys[0], key)
+			}
+
+			// There is room for a key one digit longer, at most, between
+			// every pair of neighbours
+			between, err := positionBetween(lower, key)
+			if err != nil || len(between) > len(key)+1 {
+				t.Fatalf("spreadPositions(%d): positionBetween(%q, %q) = %q, %v", n, lower, key, between, err)
+			}
+		}
+		if n > 0 && len(keys[0]) != tt.width {
+			t.Errorf("spreadPositions(%d) made %d digit keys, want %d", n, len(keys[0]), tt.width)
+		}
+	}
+}
+
+func TestRebalancePositions(t *testing.T) {
+	// Positions out of order with their IDs, shared, or grown long
+	positions := []string{"b", "a", "a", strings.Repeat("az", 20), "c", "0V"}
+	// The order of the IDs, 1 based, by position then ID
+	wantOrder := []int{6, 2, 3, 4, 1, 5}
+
+	check := func(t *testing.T, got map[int]string) {
+		t.Helper()
+
+		spread := spreadPositions(len(positions))
+		for i, id := range wantOrder {
+			if got[id] != spread[i] {
+				t.Errorf("todo %d at %q, want %q", id, got[id], spread[i])
+			}
+		}
+	}
+
+	t.Run("memory", func(t *testing.T) {
+		store := newMemoryStore()
+		for i, position := range positions {
+			store.todos[i+1] = Todo{ID: i + 1, UserID: 1, ListID: 1, Position: position}
+		}
+		store.todos[7] = Todo{ID: 7, UserID: 1, ListID: 2, Position: "a"}
+
+		store.rebalancePositions(1)
+
+		got := map[int]string{}
+		for id, todo := range store.todos {
+			got[id] = todo.Position
+		}
+		check(t, got)
+		if got[7] != "a" {
+			t.Errorf("a todo of another list moved to %q", got[7])
+		}
+	})
+
+	dbtest.Run(t, func(t *testing.T, conn *sql.DB, driver string) {
+		dbtest.Migrate(t, conn, driver)
+		ctx := context.Background()
+		models := New(conn, driver)
+
+		var ids []int
+		for _, name := range []string{"ada", "bob"} {
+			id, err := models.User.Insert(ctx, User{Name: name, Email: name + "@example.com", Password: "password"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, id)
+		}
+		ada, bob := ids[0], ids[1]
+
+		todos := map[int]int{}
+		var inboxID int
+		for i := range positions {
+			todo := Todo{UserID: ada, PriorityID: 1, Text: "todo"}
+			if err := models.Todo.Insert(ctx, &todo); err != nil {
+				t.Fatal(err)
+			}
+			todos[i+1], inboxID = todo.ID, todo.ListID
+		}
+		other := Todo{UserID: bob, PriorityID: 1, Text: "other"}
+		if err := models.Todo.Insert(ctx, &other); err != nil {
+			t.Fatal(err)
+		}
+		store := sqlStore{db: conn, postgres: driver == driverPostgres}
+		for i, position := range positions {
+			if _, err := conn.Exec(store.rebind("UPDATE todos SET position = ? WHERE id = ?"), position, todos[i+1]); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+		if err := store.rebalancePositions(ctx, tx, inboxID); err != nil {
+			t.Fatalf("rebalancePositions() error = %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+
+		got := map[int]string{}
+		for n, id := range todos {
+			updated, err := models.Todo.Get(ctx, id, ada)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got[n] = updated.Position
+			// The order stays, so the todos aren't edited
+			if updated.Version != 1 {
+				t.Errorf("todo %d at version %d after rebalancing, want 1", n, updated.Version)
+			}
+		}
+		check(t, got)
+
+		if untouched, err := models.Todo.Get(ctx, other.ID, bob); err != nil || untouched.Position != other.Position {
+			t.Errorf("another user's todo = %+v, %v; want it at %q", untouched, err, other.Position)
+		}
+
+		todos2, err := models.Todo.GetAll(ctx, ada, TodoFilter{Sort: SortPosition})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var order []int
+		for _, todo := range todos2 {
+			for n, id := range todos {
+				if id == todo.ID {
+					order = append(order, n)
+				}
+			}
+		}
+		if !slices.Equal(order, wantOrder) {
+			t.Errorf("GetAll(position) order = %v, want %v", order, wantOrder)
+		}
+	})
+}
@@ -2,41 +2,72 @@ package data
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"time"
 )
 
 type Priority struct {
-	ID        int       `json:"id,omitempty"`
+	ID int `json:"id,omitempty"`
+	// UserID is nil for the default priorities every user shares
+	UserID    *int      `json:"user_id,omitempty"`
 	Name      string    `json:"name,omitempty"`
 	Badge     string    `json:"badge,omitempty"`
+	Rank      int       `json:"rank"`
 	CreatedAt time.Time `json:"created_at,omitempty"`
 	UpdatedAt time.Time `json:"updated_at,omitempty"`
 }
 
-func (p *Priority) GetAll() ([]Priority, error) {
-	// Create a new context with a timeout to prevent long-running queries
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
-	defer cancel() // Ensure the context is canceled when the function exits
+// PriorityRepository stores the priority levels todos can be assigned: the
+// shared defaults plus each user's own.
+type PriorityRepository interface {
+	// GetAll returns the defaults and the user's own priorities, by rank
+	GetAll(ctx context.Context, userID int) ([]Priority, error)
+	// Get returns a default priority or one of the user's own
+	Get(ctx context.Context, ID, userID int) (*Priority, error)
+	Insert(ctx context.Context, priority *Priority) error
+	// Update and Delete only touch the user's own priorities
+	Update(ctx context.Context, priority *Priority) error
+	// Delete moves the user's todos to reassignTo first, or to the first
+	// default priority when reassignTo is 0, and returns how many moved
+	Delete(ctx context.Context, ID, userID, reassignTo int) (int, error)
+}
+
+// prioritySelect lists the priorities visible to a user, the defaults and their own
+const prioritySelect = "SELECT id, user_id, name, badge, rank, created_at, updated_at FROM priorities WHERE (user_id IS NULL OR user_id = ?)"
+
+// SQLPriorityRepository is the database-backed PriorityRepository.
+type SQLPriorityRepository struct {
+	sqlStore
+}
 
-		// Define the SQL query to retrieve all priorities from the database
-	query := "SELECT * FROM priorities"
+func scanPriority(row rowScanner, priority *Priority) error {
+	return row.Scan(&priority.ID, &priority.UserID, &priority.Name, &priority.Badge, &priority.Rank, &priority.CreatedAt, &priority.UpdatedAt)
+}
+
+func (r *SQLPriorityRepository) GetAll(ctx context.Context, userID int) (priorities []Priority, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	// Define the SQL query to retrieve the user's priorities from the database
+	query := r.rebind(prioritySelect + " ORDER BY rank, id")
 
 	// Execute the query using the context to ensure it respects the timeout
-	rows, err := db.QueryContext(ctx, query)
+	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, err // Return an error if the query fails
 	}
 	defer rows.Close() // Ensure the result set is closed after function execution
 
-	// Slice to store the list of priorities retrieved from the database
-	var priorities []Priority
-
 	// Iterate over the query results
 	for rows.Next() {
 		var priority Priority
 
 		// Scan the current row into the priority struct fields
-		err := rows.Scan(&priority.ID, &priority.Name, &priority.Badge,  &priority.CreatedAt, &priority.UpdatedAt)
+		err := scanPriority(rows, &priority)
 		if err != nil {
 			return nil, err // Return an error if scanning fails
 		}
@@ -50,6 +81,118 @@ func (p *Priority) GetAll() ([]Priority, error) {
 		return nil, err
 	}
 
-	// Return the list of users and no error
+	// Return the list of priorities and no error
 	return priorities, nil
-}
\ No newline at end of file
+}
+
+func (r *SQLPriorityRepository) Get(ctx context.Context, ID, userID int) (_ *Priority, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	var priority Priority
+	err = scanPriority(r.db.QueryRowContext(ctx, r.rebind(prioritySelect+" AND id = ?"), userID, ID), &priority)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &priority, nil
+}
+
+func (r *SQLPriorityRepository) Insert(ctx context.Context, priority *Priority) (err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	now := timeNow()
+	query := r.rebind("INSERT INTO priorities(user_id, name, badge, rank, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?) RETURNING id")
+	err = r.db.QueryRowContext(ctx, query, priority.UserID, priority.Name, priority.Badge, priority.Rank, now, now).Scan(&priority.ID)
+	if err != nil {
+		return err
+	}
+	priority.CreatedAt, priority.UpdatedAt = now, now
+
+	return nil
+}
+
+func (r *SQLPriorityRepository) Update(ctx context.Context, priority *Priority) (err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	query := r.rebind("UPDATE priorities SET name = ?, badge = ?, rank = ?, updated_at = ? WHERE id = ? AND user_id = ?")
+	result, err := r.db.ExecContext(ctx, query, priority.Name, priority.Badge, priority.Rank, timeNow(), priority.ID, priority.UserID)
+	if err != nil {
+		return err
+	}
+
+	// Nothing matched: the priority doesn't exist or isn't the user's own
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *SQLPriorityRepository) Delete(ctx context.Context, ID, userID, reassignTo int) (reassigned int, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	if reassignTo == 0 {
+		err = tx.QueryRowContext(ctx, "SELECT id FROM priorities WHERE user_id IS NULL ORDER BY rank, id LIMIT 1").Scan(&reassignTo)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var found int
+	err = tx.QueryRowContext(ctx, r.rebind("SELECT 1 FROM priorities WHERE id = ? AND id <> ? AND (user_id IS NULL OR user_id = ?)"), reassignTo, ID, userID).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("reassign priority: %w", ErrNotFound)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// Custom priorities are only ever assigned to their owner's todos
+	moved, err := r.reassignTodos(ctx, tx, userID, "priority_id", ID, reassignTo)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, r.rebind("DELETE FROM priorities WHERE id = ? AND user_id = ?"), ID, userID)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if rowsAffected == 0 {
+		return 0, ErrNotFound
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return moved, nil
+}
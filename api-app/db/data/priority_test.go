@@ -0,0 +1,77 @@
+package data_test
+
+import (
+	"context"
+	"task-app/db/data"
+	"testing"
+)
+
+func TestPriorityRepository(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		ada, bob := newUser(t, models, "ada"), newUser(t, models, "bob")
+
+		defaults, err := models.Priority.GetAll(ctx, ada)
+		if err != nil || len(defaults) == 0 {
+			t.Fatalf("GetAll() = %v, %v; want the defaults", defaults, err)
+		}
+		for _, priority := range defaults {
+			if priority.UserID != nil {
+				t.Errorf("default priority %q has owner %d", priority.Name, *priority.UserID)
+			}
+		}
+
+		urgent := &data.Priority{UserID: &ada, Name: "Urgent", Badge: "is-danger", Rank: 5}
+		if err := models.Priority.Insert(ctx, urgent); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+		if urgent.ID == 0 {
+			t.Fatal("Insert() left the ID unset")
+		}
+
+		// Custom priorities sort among the defaults by rank, and only their
+		// owner sees them
+		all, err := models.Priority.GetAll(ctx, ada)
+		if err != nil || len(all) != len(defaults)+1 || all[0].ID != urgent.ID {
+			t.Fatalf("GetAll() = %+v, %v; want Urgent first", all, err)
+		}
+		if others, err := models.Priority.GetAll(ctx, bob); err != nil || len(others) != len(defaults) {
+			t.Fatalf("GetAll() of another user = %d priorities, %v; want %d", len(others), err, len(defaults))
+		}
+		_, err = models.Priority.Get(ctx, urgent.ID, bob)
+		wantErr(t, "Get() of another user's priority", err, data.ErrNotFound)
+
+		urgent.Name, urgent.Rank = "Critical", 1
+		if err := models.Priority.Update(ctx, urgent); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		got, err := models.Priority.Get(ctx, urgent.ID, ada)
+		if err != nil || got.Name != "Critical" || got.Rank != 1 {
+			t.Fatalf("Get() after Update() = %+v, %v", got, err)
+		}
+
+		// The defaults belong to nobody, so nobody can change them
+		shared := defaults[0]
+		shared.UserID = &ada
+		wantErr(t, "Update() of a default", models.Priority.Update(ctx, &shared), data.ErrNotFound)
+		_, err = models.Priority.Delete(ctx, shared.ID, ada, 0)
+		wantErr(t, "Delete() of a default", err, data.ErrNotFound)
+
+		// Deleting a priority in use moves its todos to the first default
+		todo := &data.Todo{UserID: ada, PriorityID: urgent.ID, Text: "Ship it"}
+		if err := models.Todo.Insert(ctx, todo); err != nil {
+			t.Fatalf("Todo.Insert() error = %v", err)
+		}
+		moved, err := models.Priority.Delete(ctx, urgent.ID, ada, 0)
+		if err != nil || moved != 1 {
+			t.Fatalf("Delete() = %d, %v; want 1 todo moved", moved, err)
+		}
+		todo, err = models.Todo.Get(ctx, todo.ID, ada)
+		if err != nil || todo.PriorityID != defaults[0].ID || todo.Version != 2 {
+			t.Fatalf("todo after Delete() = %+v, %v; want priority %d, version 2", todo, err, defaults[0].ID)
+		}
+		wantReassigned(t, models, todo.ID, ada, "priority_id", urgent.ID, defaults[0].ID)
+		_, err = models.Priority.Get(ctx, urgent.ID, ada)
+		wantErr(t, "Get() after Delete()", err, data.ErrNotFound)
+	})
+}
@@ -0,0 +1,216 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrTokenInvalid is returned for a refresh token that doesn't exist, has
+	// expired or was revoked
+	ErrTokenInvalid = errors.New("the refresh token is invalid or has expired")
+	// ErrTokenReused is returned when a refresh token is presented again after
+	// being exchanged. It has likely been stolen, so its session is revoked.
+	ErrTokenReused = errors.New("the refresh token has already been used, so its session has been signed out")
+)
+
+// RefreshToken is a long-lived opaque token, exchanged once for an access
+// token and its successor. Only a hash of the token itself is stored.
+type RefreshToken struct {
+	ID     int
+	UserID int
+	// SessionID is the login the token descends from, through a chain of
+	// tokens of which only the latest is live
+	SessionID int
+	ExpiresAt time.Time
+	// UsedAt is set once the token has been exchanged
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// RefreshTokenRepository stores refresh tokens.
+type RefreshTokenRepository interface {
+	// Issue creates the first refresh token of a session, valid for ttl, and
+	// returns the token
+	Issue(ctx context.Context, userID, sessionID int, ttl time.Duration) (string, error)
+	// Rotate exchanges a refresh token for the next of its session, valid for
+	// ttl, and returns it with its record. Presenting a token a second time
+	// revokes its session and fails with ErrTokenReused.
+	Rotate(ctx context.Context, token string, ttl time.Duration) (string, *RefreshToken, error)
+	// Revoke revokes the session of one of the user's refresh tokens, as on
+	// logout. Another user's token is ErrTokenInvalid.
+	Revoke(ctx context.Context, token string, userID int) error
+	// DeleteExpired deletes the tokens that expired before the given time,
+	// for all users, and returns how many
+	DeleteExpired(ctx context.Context, before time.Time) (int, error)
+}
+
+// newToken returns a random, URL-safe token with 256 bits of entropy.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is what gets stored of a token. The tokens are random enough
+// that a plain SHA-256 does, unlike passwords.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// SQLRefreshTokenRepository is the database-backed RefreshTokenRepository.
+type SQLRefreshTokenRepository struct {
+	sqlStore
+}
+
+func (r *SQLRefreshTokenRepository) Issue(ctx context.Context, userID, sessionID int, ttl time.Duration) (token string, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	token, _, err = r.insertToken(ctx, r.db, userID, sessionID, ttl)
+	return token, err
+}
+
+// insertToken stores a new token of the session and returns it.
+func (r *SQLRefreshTokenRepository) insertToken(ctx context.Context, db dbtx, userID, sessionID int, ttl time.Duration) (string, *RefreshToken, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := timeNow()
+	stored := &RefreshToken{UserID: userID, SessionID: sessionID, ExpiresAt: now.Add(ttl), CreatedAt: now}
+	query := r.rebind("INSERT INTO refresh_tokens (user_id, session_id, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?, ?) RETURNING id")
+	err = db.QueryRowContext(ctx, query, userID, sessionID, hashToken(token), stored.ExpiresAt, now).Scan(&stored.ID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, stored, nil
+}
+
+func (r *SQLRefreshTokenRepository) Rotate(ctx context.Context, token string, ttl time.Duration) (next string, stored *RefreshToken, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	var current RefreshToken
+	query := r.rebind("SELECT id, user_id, session_id, expires_at, used_at, revoked_at FROM refresh_tokens WHERE token_hash = ?")
+	err = tx.QueryRowContext(ctx, query, hashToken(token)).Scan(&current.ID, &current.UserID, &current.SessionID, &current.ExpiresAt, &current.UsedAt, &current.RevokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil, ErrTokenInvalid
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if current.RevokedAt != nil || !current.ExpiresAt.After(timeNow()) {
+		return "", nil, ErrTokenInvalid
+	}
+
+	// A token that was already used, possibly by a request racing this one,
+	// is being replayed
+	reused := current.UsedAt != nil
+	if !reused {
+		result, err := tx.ExecContext(ctx, r.rebind("UPDATE refresh_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL"), timeNow(), current.ID)
+		if err != nil {
+			return "", nil, err
+		}
+		if err = expectRows(result); err != nil && !errors.Is(err, ErrNotFound) {
+			return "", nil, err
+		}
+		reused = err != nil
+	}
+	if reused {
+		if err = r.endSession(ctx, tx, current.SessionID); err != nil {
+			return "", nil, err
+		}
+		if err = tx.Commit(); err != nil {
+			return "", nil, err
+		}
+		return "", nil, ErrTokenReused
+	}
+
+	if next, stored, err = r.insertToken(ctx, tx, current.UserID, current.SessionID, ttl); err != nil {
+		return "", nil, err
+	}
+
+	// The session lasts as long as its latest refresh token
+	_, err = tx.ExecContext(ctx, r.rebind("UPDATE sessions SET expires_at = ? WHERE id = ?"), stored.ExpiresAt, stored.SessionID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", nil, err
+	}
+
+	return next, stored, nil
+}
+
+func (r *SQLRefreshTokenRepository) Revoke(ctx context.Context, token string, userID int) (err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	var sessionID int
+	query := r.rebind("SELECT session_id FROM refresh_tokens WHERE token_hash = ? AND user_id = ?")
+	err = r.db.QueryRowContext(ctx, query, hashToken(token), userID).Scan(&sessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTokenInvalid
+	}
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	if err = r.endSession(ctx, tx, sessionID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLRefreshTokenRepository) DeleteExpired(ctx context.Context, before time.Time) (deleted int, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	result, err := r.db.ExecContext(ctx, r.rebind("DELETE FROM refresh_tokens WHERE expires_at < ?"), before.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(rowsAffected), nil
+}
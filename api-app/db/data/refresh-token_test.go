@@ -0,0 +1,64 @@
+package data_test
+
+import (
+	"context"
+	"task-app/db/data"
+	"testing"
+	"time"
+)
+
+func TestRefreshTokenRepository(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		ada := newUser(t, models, "ada")
+		session, token := newSession(t, models, ada)
+
+		next, stored, err := models.RefreshToken.Rotate(ctx, token, time.Hour)
+		if err != nil {
+			t.Fatalf("Rotate() error = %v", err)
+		}
+		if next == token || stored.UserID != ada || stored.SessionID != session.ID {
+			t.Fatalf("Rotate() = %q, %+v; want a new token of the session", next, stored)
+		}
+
+		_, _, err = models.RefreshToken.Rotate(ctx, "not-a-token", time.Hour)
+		wantErr(t, "Rotate() of an unknown token", err, data.ErrTokenInvalid)
+
+		// Presenting a used token again signs the session out
+		_, _, err = models.RefreshToken.Rotate(ctx, token, time.Hour)
+		wantErr(t, "Rotate() of a used token", err, data.ErrTokenReused)
+		_, _, err = models.RefreshToken.Rotate(ctx, next, time.Hour)
+		wantErr(t, "Rotate() after a reuse", err, data.ErrTokenInvalid)
+		wantErr(t, "Touch() after a reuse", models.Session.Touch(ctx, session.ID, ada, time.Now()), data.ErrNotFound)
+
+		// Revoke signs out the session of the token, as on logout, but only
+		// for its owner
+		session, token = newSession(t, models, ada)
+		bob := newUser(t, models, "bob")
+		wantErr(t, "Revoke() of another user's token", models.RefreshToken.Revoke(ctx, token, bob), data.ErrTokenInvalid)
+		if err := models.Session.Touch(ctx, session.ID, ada, time.Now()); err != nil {
+			t.Fatalf("Touch() after another user's Revoke() error = %v", err)
+		}
+		if err := models.RefreshToken.Revoke(ctx, token, ada); err != nil {
+			t.Fatalf("Revoke() error = %v", err)
+		}
+		_, _, err = models.RefreshToken.Rotate(ctx, token, time.Hour)
+		wantErr(t, "Rotate() of a revoked token", err, data.ErrTokenInvalid)
+		wantErr(t, "Touch() after Revoke()", models.Session.Touch(ctx, session.ID, ada, time.Now()), data.ErrNotFound)
+
+		// An expired token no longer rotates, and goes with DeleteExpired
+		session = &data.Session{UserID: ada, ExpiresAt: time.Now().Add(time.Hour)}
+		if err := models.Session.Insert(ctx, session); err != nil {
+			t.Fatal(err)
+		}
+		expired, err := models.RefreshToken.Issue(ctx, ada, session.ID, -time.Minute)
+		if err != nil {
+			t.Fatalf("Issue() error = %v", err)
+		}
+		_, _, err = models.RefreshToken.Rotate(ctx, expired, time.Hour)
+		wantErr(t, "Rotate() of an expired token", err, data.ErrTokenInvalid)
+		if deleted, err := models.RefreshToken.DeleteExpired(ctx, time.Now()); err != nil || deleted == 0 {
+			t.Fatalf("DeleteExpired() = %d, %v; want the expired token", deleted, err)
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"task-app/resp"
+	"time"
+)
+
+// redisKeyPrefix namespaces the revoked token IDs in a shared Redis
+const redisKeyPrefix = "revoked_token:"
+
+// RedisRevocationStore is a RevocationStore kept in Redis, or anything
+// speaking its protocol, where entries expire along with their token.
+type RedisRevocationStore struct {
+	client *resp.Client
+}
+
+// NewRedisRevocationStore returns a store using the Redis server at addr
+// (host:port), authenticating with password unless it is empty. Nothing is
+// dialed until the first command.
+func NewRedisRevocationStore(addr, password string) *RedisRevocationStore {
+	return &RedisRevocationStore{client: resp.NewClient(addr, password)}
+}
+
+func (s *RedisRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) (err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	// An expired token is rejected anyway
+	ttl := time.Until(expiresAt).Milliseconds()
+	if ttl <= 0 {
+		return nil
+	}
+
+	_, err = s.client.Do(ctx, "SET", redisKeyPrefix+jti, "1", "PX", strconv.FormatInt(ttl, 10))
+	return err
+}
+
+func (s *RedisRevocationStore) IsRevoked(ctx context.Context, jti string) (revoked bool, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	reply, err := s.client.Do(ctx, "EXISTS", redisKeyPrefix+jti)
+	if err != nil {
+		return false, err
+	}
+
+	n, ok := reply.(int64)
+	if !ok {
+		return false, fmt.Errorf("redis: unexpected EXISTS reply %v", reply)
+	}
+
+	return n > 0, nil
+}
+
+// Prune has nothing to do: Redis expires the entries itself.
+func (s *RedisRevocationStore) Prune(ctx context.Context, before time.Time) (int, error) {
+	return 0, checkContext(ctx)
+}
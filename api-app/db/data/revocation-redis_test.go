@@ -0,0 +1,170 @@
+package data_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"task-app/db/data"
+	"task-app/resp"
+	"task-app/resp/resptest"
+	"testing"
+	"time"
+)
+
+func TestRedisRevocationStore(t *testing.T) {
+	server := resptest.NewServer("secret")
+	defer server.Close()
+
+	store := data.NewRedisRevocationStore(server.Addr, "secret")
+	ctx := context.Background()
+
+	revoked, err := store.IsRevoked(ctx, "jti-1")
+	if err != nil || revoked {
+		t.Fatalf("IsRevoked before Revoke = %v, %v; want false", revoked, err)
+	}
+
+	if err := store.Revoke(ctx, "jti-1", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Revoke error = %v", err)
+	}
+	// Revoking twice is harmless
+	if err := store.Revoke(ctx, "jti-1", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("second Revoke error = %v", err)
+	}
+
+	tests := []struct {
+		jti  string
+		want bool
+	}{
+		{"jti-1", true},
+		{"jti-2", false},
+	}
+	for _, tt := range tests {
+		revoked, err := store.IsRevoked(ctx, tt.jti)
+		if err != nil || revoked != tt.want {
+			t.Errorf("IsRevoked(%q) = %v, %v; want %v", tt.jti, revoked, err, tt.want)
+		}
+	}
+
+	if pruned, err := store.Prune(ctx, time.Now()); err != nil || pruned != 0 {
+		t.Errorf("Prune = %d, %v; want 0, the server expires entries itself", pruned, err)
+	}
+}
+
+func TestRedisRevocationStoreExpiry(t *testing.T) {
+	server := resptest.NewServer("")
+	defer server.Close()
+
+	store := data.NewRedisRevocationStore(server.Addr, "")
+	ctx := context.Background()
+
+	if err := store.Revoke(ctx, "jti", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Revoke error = %v", err)
+	}
+
+	server.FastForward(59 * time.Second)
+	if revoked, err := store.IsRevoked(ctx, "jti"); err != nil || !revoked {
+		t.Fatalf("IsRevoked before the token expires = %v, %v; want true", revoked, err)
+	}
+
+	// The entry goes once the token has expired
+	server.FastForward(2 * time.Second)
+	if revoked, err := store.IsRevoked(ctx, "jti"); err != nil || revoked {
+		t.Fatalf("IsRevoked after the token expired = %v, %v; want false", revoked, err)
+	}
+
+	// An expired token isn't even sent
+	commands := server.Commands()
+	if err := store.Revoke(ctx, "old", time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("Revoke of an expired token error = %v", err)
+	}
+	if got := server.Commands(); got != commands {
+		t.Errorf("Revoke of an expired token sent %d commands, want none", got-commands)
+	}
+}
+
+func TestRedisRevocationStoreAuth(t *testing.T) {
+	server := resptest.NewServer("secret")
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  string
+	}{
+		{"right password", "secret", ""},
+		{"wrong password", "guess", "WRONGPASS"},
+		{"no password", "", "NOAUTH"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := data.NewRedisRevocationStore(server.Addr, tt.password)
+			ctx := context.Background()
+
+			errs := []error{store.Revoke(ctx, "jti", time.Now().Add(time.Minute))}
+			_, err := store.IsRevoked(ctx, "jti")
+			errs = append(errs, err)
+
+			for _, err := range errs {
+				if tt.wantErr == "" {
+					if err != nil {
+						t.Errorf("error = %v", err)
+					}
+					continue
+				}
+
+				var replyErr resp.Error
+				if !errors.As(err, &replyErr) || !strings.HasPrefix(string(replyErr), tt.wantErr) {
+					t.Errorf("error = %v, want a %s error reply", err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestRedisRevocationStoreConnectionErrors(t *testing.T) {
+	server := resptest.NewServer("")
+	store := data.NewRedisRevocationStore(server.Addr, "")
+	ctx := context.Background()
+
+	if err := store.Revoke(ctx, "jti", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Revoke error = %v", err)
+	}
+
+	// A dropped connection fails the command on it, then is dialed again
+	server.CloseClientConnections()
+	if _, err := store.IsRevoked(ctx, "jti"); err == nil {
+		t.Fatal("IsRevoked on a dropped connection succeeded")
+	}
+	if revoked, err := store.IsRevoked(ctx, "jti"); err != nil || !revoked {
+		t.Fatalf("IsRevoked after reconnecting = %v, %v; want true", revoked, err)
+	}
+
+	// Without a server, every command fails rather than reporting the token
+	// as not revoked
+	server.Close()
+	if err := store.Revoke(ctx, "jti", time.Now().Add(time.Minute)); err == nil {
+		t.Error("Revoke without a server succeeded")
+	}
+	if _, err := store.IsRevoked(ctx, "jti"); err == nil {
+		t.Error("IsRevoked without a server succeeded")
+	}
+	var opErr *net.OpError
+	if _, err := store.IsRevoked(ctx, "jti"); !errors.As(err, &opErr) {
+		t.Errorf("IsRevoked without a server error = %v, want a *net.OpError", err)
+	}
+}
+
+func TestRedisRevocationStoreCanceled(t *testing.T) {
+	server := resptest.NewServer("")
+	defer server.Close()
+
+	store := data.NewRedisRevocationStore(server.Addr, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := store.IsRevoked(ctx, "jti"); !errors.Is(err, data.ErrCanceled) {
+		t.Errorf("IsRevoked with a canceled context error = %v, want data.ErrCanceled", err)
+	}
+}
@@ -0,0 +1,67 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// RevocationStore remembers the access tokens revoked before their expiry,
+// by their ID (the jti claim). Entries only need to outlive the token.
+type RevocationStore interface {
+	// Revoke marks a token as revoked until it expires
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	// Prune deletes the entries of tokens expired before the given time and
+	// returns how many. Stores that expire entries on their own return 0.
+	Prune(ctx context.Context, before time.Time) (int, error)
+}
+
+// SQLRevocationStore is the database-backed RevocationStore, shared by every
+// replica using the same database.
+type SQLRevocationStore struct {
+	sqlStore
+}
+
+func (r *SQLRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) (err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	// Revoking a token twice is harmless
+	query := r.rebind("INSERT INTO revoked_tokens (jti, expires_at, created_at) VALUES (?, ?, ?) ON CONFLICT (jti) DO NOTHING")
+	_, err = r.db.ExecContext(ctx, query, jti, expiresAt.UTC(), timeNow())
+
+	return err
+}
+
+func (r *SQLRevocationStore) IsRevoked(ctx context.Context, jti string) (revoked bool, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	query := r.rebind("SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = ?)")
+	err = r.db.QueryRowContext(ctx, query, jti).Scan(&revoked)
+
+	return revoked, err
+}
+
+func (r *SQLRevocationStore) Prune(ctx context.Context, before time.Time) (pruned int, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	result, err := r.db.ExecContext(ctx, r.rebind("DELETE FROM revoked_tokens WHERE expires_at < ?"), before.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(rowsAffected), nil
+}
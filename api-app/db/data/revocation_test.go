@@ -0,0 +1,40 @@
+package data_test
+
+import (
+	"context"
+	"task-app/db/data"
+	"testing"
+	"time"
+)
+
+func TestRevocationStore(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		now := time.Now()
+
+		if err := models.Revocation.Revoke(ctx, "soon", now.Add(time.Minute)); err != nil {
+			t.Fatalf("Revoke() error = %v", err)
+		}
+		if err := models.Revocation.Revoke(ctx, "later", now.Add(time.Hour)); err != nil {
+			t.Fatalf("Revoke() error = %v", err)
+		}
+		// Revoking twice is no error
+		if err := models.Revocation.Revoke(ctx, "later", now.Add(time.Hour)); err != nil {
+			t.Fatalf("second Revoke() error = %v", err)
+		}
+
+		for jti, want := range map[string]bool{"soon": true, "later": true, "other": false} {
+			if revoked, err := models.Revocation.IsRevoked(ctx, jti); err != nil || revoked != want {
+				t.Errorf("IsRevoked(%s) = %v, %v; want %v", jti, revoked, err, want)
+			}
+		}
+
+		// Once a token has expired, its entry is no longer needed
+		if pruned, err := models.Revocation.Prune(ctx, now.Add(2*time.Minute)); err != nil || pruned != 1 {
+			t.Fatalf("Prune() = %d, %v; want 1", pruned, err)
+		}
+		if revoked, err := models.Revocation.IsRevoked(ctx, "later"); err != nil || !revoked {
+			t.Errorf("IsRevoked(later) after Prune() = %v, %v; want true", revoked, err)
+		}
+	})
+}
@@ -0,0 +1,231 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// sessionTouchInterval is how stale last_seen_at may get before a request
+// updates it, sparing a write on every request
+const sessionTouchInterval = time.Minute
+
+// Session is one login of a user, which its access and refresh tokens belong
+// to. Revoking it signs the device out.
+type Session struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"user_id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	// ExpiresAt follows the session's latest refresh token
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"-"`
+	// Current marks the session of the request listing them
+	Current bool `json:"current"`
+}
+
+// SessionRepository stores sessions.
+type SessionRepository interface {
+	Insert(ctx context.Context, session *Session) error
+	// GetAll returns the user's active sessions, most recently seen first
+	GetAll(ctx context.Context, userID int) ([]Session, error)
+	// Touch fails with ErrNotFound unless the session is active, and records
+	// it as seen at now
+	Touch(ctx context.Context, ID, userID int, now time.Time) error
+	// Revoke ends one of the user's sessions, with its refresh tokens
+	Revoke(ctx context.Context, ID, userID int) error
+	// RevokeAll ends the user's sessions but exceptID, which may be 0, and
+	// returns how many
+	RevokeAll(ctx context.Context, userID, exceptID int) (int, error)
+	// DeleteExpired deletes the sessions that expired or were revoked before
+	// the given time, for all users, along with their refresh tokens, and
+	// returns how many
+	DeleteExpired(ctx context.Context, before time.Time) (int, error)
+}
+
+// SQLSessionRepository is the database-backed SessionRepository.
+type SQLSessionRepository struct {
+	sqlStore
+}
+
+func (r *SQLSessionRepository) Insert(ctx context.Context, session *Session) (err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	now := timeNow()
+	session.CreatedAt, session.LastSeenAt = now, now
+	session.ExpiresAt = session.ExpiresAt.UTC()
+
+	query := r.rebind(`
+        INSERT INTO sessions (user_id, user_agent, ip, created_at, last_seen_at, expires_at)
+        VALUES (?, ?, ?, ?, ?, ?) RETURNING id`)
+
+	return r.db.QueryRowContext(ctx, query, session.UserID, session.UserAgent, session.IP, now, now, session.ExpiresAt).Scan(&session.ID)
+}
+
+func (r *SQLSessionRepository) GetAll(ctx context.Context, userID int) (sessions []Session, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	query := r.rebind(`
+        SELECT id, user_id, user_agent, ip, created_at, last_seen_at, expires_at
+        FROM sessions
+        WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ?
+        ORDER BY last_seen_at DESC, id DESC`)
+
+	rows, err := r.db.QueryContext(ctx, query, userID, timeNow())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions = []Session{}
+	for rows.Next() {
+		var session Session
+		err := rows.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+func (r *SQLSessionRepository) Touch(ctx context.Context, ID, userID int, now time.Time) (err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	var lastSeenAt time.Time
+	query := r.rebind("SELECT last_seen_at FROM sessions WHERE id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?")
+	err = r.db.QueryRowContext(ctx, query, ID, userID, now.UTC()).Scan(&lastSeenAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if now.Sub(lastSeenAt) < sessionTouchInterval {
+		return nil
+	}
+	_, err = r.db.ExecContext(ctx, r.rebind("UPDATE sessions SET last_seen_at = ? WHERE id = ?"), now.UTC(), ID)
+
+	return err
+}
+
+func (r *SQLSessionRepository) Revoke(ctx context.Context, ID, userID int) (err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	var found int
+	query := r.rebind("SELECT 1 FROM sessions WHERE id = ? AND user_id = ? AND revoked_at IS NULL")
+	err = tx.QueryRowContext(ctx, query, ID, userID).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = r.endSession(ctx, tx, ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// endSession revokes a session and its refresh tokens.
+func (s sqlStore) endSession(ctx context.Context, tx dbtx, ID int) error {
+	now := timeNow()
+	_, err := tx.ExecContext(ctx, s.rebind("UPDATE sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL"), now, ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, s.rebind("UPDATE refresh_tokens SET revoked_at = ? WHERE session_id = ? AND revoked_at IS NULL"), now, ID)
+
+	return err
+}
+
+func (r *SQLSessionRepository) RevokeAll(ctx context.Context, userID, exceptID int) (revoked int, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	if revoked, err = r.revokeSessions(ctx, tx, userID, exceptID); err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return revoked, nil
+}
+
+// revokeSessions ends the user's active sessions but exceptID, with their
+// refresh tokens, and returns how many.
+func (s sqlStore) revokeSessions(ctx context.Context, tx dbtx, userID, exceptID int) (int, error) {
+	now := timeNow()
+	query := s.rebind("UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND id <> ? AND revoked_at IS NULL AND expires_at > ?")
+	result, err := tx.ExecContext(ctx, query, now, userID, exceptID, now)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	query = s.rebind("UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND session_id <> ? AND revoked_at IS NULL")
+	if _, err = tx.ExecContext(ctx, query, now, userID, exceptID); err != nil {
+		return 0, err
+	}
+
+	return int(rowsAffected), nil
+}
+
+func (r *SQLSessionRepository) DeleteExpired(ctx context.Context, before time.Time) (deleted int, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	before = before.UTC()
+	result, err := r.db.ExecContext(ctx, r.rebind("DELETE FROM sessions WHERE expires_at < ? OR revoked_at < ?"), before, before)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(rowsAffected), nil
+}
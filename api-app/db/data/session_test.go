@@ -0,0 +1,81 @@
+package data_test
+
+import (
+	"context"
+	"task-app/db/data"
+	"testing"
+	"time"
+)
+
+// newSession starts a session of the user and issues its first refresh token.
+func newSession(t *testing.T, models data.Models, userID int) (*data.Session, string) {
+	t.Helper()
+
+	ctx := context.Background()
+	session := &data.Session{UserID: userID, UserAgent: "test", IP: "127.0.0.1", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := models.Session.Insert(ctx, session); err != nil {
+		t.Fatalf("Session.Insert() error = %v", err)
+	}
+	token, err := models.RefreshToken.Issue(ctx, userID, session.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("RefreshToken.Issue() error = %v", err)
+	}
+
+	return session, token
+}
+
+func TestSessionRepository(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		ada, bob := newUser(t, models, "ada"), newUser(t, models, "bob")
+
+		phone, phoneToken := newSession(t, models, ada)
+		laptop, _ := newSession(t, models, ada)
+		tablet, tabletToken := newSession(t, models, ada)
+		newSession(t, models, bob)
+
+		sessions, err := models.Session.GetAll(ctx, ada)
+		if err != nil || len(sessions) != 3 {
+			t.Fatalf("GetAll() = %d sessions, %v; want 3", len(sessions), err)
+		}
+
+		if err := models.Session.Touch(ctx, phone.ID, ada, time.Now()); err != nil {
+			t.Fatalf("Touch() error = %v", err)
+		}
+		wantErr(t, "Touch() of another user's session", models.Session.Touch(ctx, phone.ID, bob, time.Now()), data.ErrNotFound)
+		wantErr(t, "Revoke() of another user's session", models.Session.Revoke(ctx, phone.ID, bob), data.ErrNotFound)
+
+		// A revoked session is over, refresh tokens included
+		if err := models.Session.Revoke(ctx, phone.ID, ada); err != nil {
+			t.Fatalf("Revoke() error = %v", err)
+		}
+		wantErr(t, "Touch() of a revoked session", models.Session.Touch(ctx, phone.ID, ada, time.Now()), data.ErrNotFound)
+		_, _, err = models.RefreshToken.Rotate(ctx, phoneToken, time.Hour)
+		wantErr(t, "Rotate() of a revoked session's token", err, data.ErrTokenInvalid)
+
+		revoked, err := models.Session.RevokeAll(ctx, ada, laptop.ID)
+		if err != nil || revoked != 1 {
+			t.Fatalf("RevokeAll() = %d, %v; want the tablet only", revoked, err)
+		}
+		_, _, err = models.RefreshToken.Rotate(ctx, tabletToken, time.Hour)
+		wantErr(t, "Rotate() after RevokeAll()", err, data.ErrTokenInvalid)
+		if sessions, err := models.Session.GetAll(ctx, ada); err != nil || len(sessions) != 1 || sessions[0].ID != laptop.ID {
+			t.Fatalf("GetAll() after RevokeAll() = %+v, %v; want the laptop only", sessions, err)
+		}
+		// Other users keep theirs
+		if sessions, err := models.Session.GetAll(ctx, bob); err != nil || len(sessions) != 1 {
+			t.Fatalf("GetAll() of another user = %d sessions, %v; want 1", len(sessions), err)
+		}
+
+		// Revoked sessions go once revoked for long enough, expired ones
+		// once expired
+		deleted, err := models.Session.DeleteExpired(ctx, time.Now().Add(time.Minute))
+		if err != nil || deleted != 2 {
+			t.Fatalf("DeleteExpired() = %d, %v; want the 2 revoked sessions", deleted, err)
+		}
+		if deleted, err := models.Session.DeleteExpired(ctx, time.Now().Add(2*time.Hour)); err != nil || deleted != 2 {
+			t.Fatalf("DeleteExpired(later) = %d, %v; want the 2 expired sessions", deleted, err)
+		}
+		wantErr(t, "Touch() of a deleted session", models.Session.Touch(ctx, tablet.ID, ada, time.Now()), data.ErrNotFound)
+	})
+}
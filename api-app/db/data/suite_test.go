@@ -0,0 +1,85 @@
+package data_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"task-app/db/data"
+	"task-app/db/dbtest"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func init() {
+	data.PasswordCost = bcrypt.MinCost
+}
+
+// forEachBackend runs test against the repositories of every backend, each
+// with a store of its own: the in-memory one, then the SQL ones on a migrated
+// database. See dbtest.Run for Postgres.
+func forEachBackend(t *testing.T, test func(t *testing.T, models data.Models)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, data.NewMemory())
+	})
+	dbtest.Run(t, func(t *testing.T, conn *sql.DB, driver string) {
+		dbtest.Migrate(t, conn, driver)
+		test(t, data.New(conn, driver))
+	})
+}
+
+// newUser registers a user with the password "password" and returns their ID.
+func newUser(t *testing.T, models data.Models, name string) int {
+	t.Helper()
+
+	id, err := models.User.Insert(context.Background(), data.User{
+		Name:     name,
+		Email:    fmt.Sprintf("%s@example.com", name),
+		Password: "password",
+	})
+	if err != nil {
+		t.Fatalf("User.Insert(%s) error = %v", name, err)
+	}
+
+	return id
+}
+
+// newTodo inserts a todo with the first default priority into the user's
+// Inbox.
+func newTodo(t *testing.T, models data.Models, userID int, text string) *data.Todo {
+	t.Helper()
+
+	todo := &data.Todo{UserID: userID, PriorityID: 1, Text: text}
+	if err := models.Todo.Insert(context.Background(), todo); err != nil {
+		t.Fatalf("Todo.Insert(%q) error = %v", text, err)
+	}
+
+	return todo
+}
+
+// wantReassigned fails the test unless the last event in the history of the
+// todo records field changing from before to after.
+func wantReassigned(t *testing.T, models data.Models, todoID, userID int, field string, before, after int) {
+	t.Helper()
+
+	events, err := models.Todo.History(context.Background(), todoID, userID)
+	if err != nil || len(events) == 0 {
+		t.Fatalf("History() = %v, %v", events, err)
+	}
+	last := events[len(events)-1]
+	change := last.Changes[field]
+	if last.Action != data.TodoUpdated || len(last.Changes) != 1 || string(change.Before) != strconv.Itoa(before) || string(change.After) != strconv.Itoa(after) {
+		t.Errorf("last event = %s %v, want %s changing %s from %d to %d", last.Action, last.Changes, data.TodoUpdated, field, before, after)
+	}
+}
+
+// wantErr fails the test unless err matches target, nil included.
+func wantErr(t *testing.T, what string, err, target error) {
+	t.Helper()
+
+	if !errors.Is(err, target) {
+		t.Fatalf("%s error = %v, want %v", what, err, target)
+	}
+}
@@ -0,0 +1,165 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Tag is one of a user's free-form todo labels.
+type Tag struct {
+	ID        int       `json:"id,omitempty"`
+	UserID    int       `json:"user_id,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	TodoCount int       `json:"todo_count"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+// TagRepository reads the tags users have put on their todos. Tags are
+// created and removed through TodoRepository as todos are saved.
+type TagRepository interface {
+	// GetAll returns the user's tags that are in use, by name, with how many todos carry each
+	GetAll(ctx context.Context, userID int) ([]Tag, error)
+}
+
+// SQLTagRepository is the database-backed TagRepository.
+type SQLTagRepository struct {
+	sqlStore
+}
+
+func (r *SQLTagRepository) GetAll(ctx context.Context, userID int) (tags []Tag, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	query := r.rebind(`
+        SELECT tg.id, tg.user_id, tg.name, COUNT(tt.todo_id), tg.created_at
+        FROM tags tg
+        JOIN todo_tags tt ON tt.tag_id = tg.id
+        JOIN todos t ON t.id = tt.todo_id AND t.deleted_at IS NULL
+        WHERE tg.user_id = ?
+        GROUP BY tg.id, tg.user_id, tg.name, tg.created_at
+        ORDER BY tg.name`)
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() // Ensure the result set is closed after function execution
+
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.ID, &tag.UserID, &tag.Name, &tag.TodoCount, &tag.CreatedAt); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// NormalizeTags trims and lowercases tag names, dropping blanks and
+// duplicates, and returns them sorted. It never returns nil.
+func NormalizeTags(names []string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		tags = append(tags, name)
+	}
+	sort.Strings(tags)
+
+	return tags
+}
+
+// setTags replaces the tags of a todo, creating the user's tags as needed
+// and dropping the ones no todo uses anymore.
+func (s sqlStore) setTags(ctx context.Context, tx dbtx, todoID, userID int, names []string) error {
+	if _, err := tx.ExecContext(ctx, s.rebind("DELETE FROM todo_tags WHERE todo_id = ?"), todoID); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		_, err := tx.ExecContext(ctx, s.rebind("INSERT INTO tags (user_id, name, created_at) VALUES (?, ?, ?) ON CONFLICT (user_id, name) DO NOTHING"), userID, name, timeNow())
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, s.rebind("INSERT INTO todo_tags (todo_id, tag_id) VALUES (?, (SELECT id FROM tags WHERE user_id = ? AND name = ?))"), todoID, userID, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.ExecContext(ctx, s.rebind("DELETE FROM tags WHERE user_id = ? AND NOT EXISTS (SELECT 1 FROM todo_tags WHERE tag_id = tags.id)"), userID)
+
+	return err
+}
+
+// tagBatchSize keeps the IN lists of loadTags well below the placeholder
+// limits of SQLite and Postgres.
+const tagBatchSize = 500
+
+// loadTags fills in the Tags of todos with one query per batch rather than
+// one per todo.
+func (s sqlStore) loadTags(ctx context.Context, db dbtx, todos []Todo) error {
+	index := make(map[int]int, len(todos))
+	for i := range todos {
+		todos[i].Tags = []string{}
+		index[todos[i].ID] = i
+	}
+
+	for start := 0; start < len(todos); start += tagBatchSize {
+		batch := todos[start:min(start+tagBatchSize, len(todos))]
+
+		args := make([]any, len(batch))
+		for i, todo := range batch {
+			args[i] = todo.ID
+		}
+		query := s.rebind(`
+        SELECT tt.todo_id, tg.name
+        FROM todo_tags tt
+        JOIN tags tg ON tg.id = tt.tag_id
+        WHERE tt.todo_id IN (` + placeholders(len(batch)) + `)
+        ORDER BY tg.name`)
+
+		if err := func() error {
+			rows, err := db.QueryContext(ctx, query, args...)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+				var todoID int
+				var name string
+				if err := rows.Scan(&todoID, &name); err != nil {
+					return err
+				}
+				todo := &todos[index[todoID]]
+				todo.Tags = append(todo.Tags, name)
+			}
+
+			return rows.Err()
+		}(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// placeholders returns n comma-separated "?" placeholders.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
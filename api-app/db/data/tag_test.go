@@ -0,0 +1,89 @@
+package data_test
+
+import (
+	"context"
+	"slices"
+	"task-app/db/data"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  []string
+	}{
+		{nil, []string{}},
+		{[]string{" ", ""}, []string{}},
+		{[]string{"Work", "go", "work "}, []string{"go", "work"}},
+		{[]string{"  Side   Project "}, []string{"side project"}},
+	}
+
+	for _, tt := range tests {
+		if got := data.NormalizeTags(tt.names); !slices.Equal(got, tt.want) || got == nil {
+			t.Errorf("NormalizeTags(%q) = %q, want %q", tt.names, got, tt.want)
+		}
+	}
+}
+
+func TestTagRepository(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		ada, bob := newUser(t, models, "ada"), newUser(t, models, "bob")
+
+		tagged := func(userID int, text string, tags ...string) *data.Todo {
+			t.Helper()
+			todo := &data.Todo{UserID: userID, PriorityID: 1, Text: text, Tags: data.NormalizeTags(tags)}
+			if err := models.Todo.Insert(ctx, todo); err != nil {
+				t.Fatalf("Insert(%q) error = %v", text, err)
+			}
+			return todo
+		}
+		review := tagged(ada, "Review", "Work", "go")
+		tagged(ada, "Deploy", "work")
+		tagged(bob, "Read", "books")
+
+		got, err := models.Todo.Get(ctx, review.ID, ada)
+		if err != nil || !slices.Equal(got.Tags, []string{"go", "work"}) {
+			t.Fatalf("Get() = %+v, %v; want the tags go and work", got, err)
+		}
+
+		counts := func() map[string]int {
+			t.Helper()
+			tags, err := models.Tag.GetAll(ctx, ada)
+			if err != nil {
+				t.Fatalf("Tag.GetAll() error = %v", err)
+			}
+			counts := map[string]int{}
+			for _, tag := range tags {
+				counts[tag.Name] = tag.TodoCount
+			}
+			return counts
+		}
+		if got := counts(); len(got) != 2 || got["work"] != 2 || got["go"] != 1 {
+			t.Errorf("Tag.GetAll() counts = %v, want work 2 and go 1", got)
+		}
+
+		for _, tt := range []struct {
+			filter data.TodoFilter
+			want   int
+		}{
+			{data.TodoFilter{Tags: []string{"go"}}, 1},
+			{data.TodoFilter{Tags: []string{"GO", "work"}}, 2},
+			{data.TodoFilter{Tags: []string{"go", "work"}, TagsMatchAll: true}, 1},
+			{data.TodoFilter{Tags: []string{"books"}}, 0},
+		} {
+			if count, err := models.Todo.Count(ctx, ada, tt.filter); err != nil || count != tt.want {
+				t.Errorf("Count(tags %v, all %v) = %d, %v; want %d", tt.filter.Tags, tt.filter.TagsMatchAll, count, err, tt.want)
+			}
+		}
+
+		// Tags no todo carries anymore go away
+		got.Tags = []string{"work"}
+		if err := models.Todo.Update(ctx, got); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		if got := counts(); len(got) != 1 || got["work"] != 2 {
+			t.Errorf("Tag.GetAll() counts after Update() = %v, want work 2", got)
+		}
+	})
+}
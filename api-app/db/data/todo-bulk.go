@@ -0,0 +1,179 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// bulkOperationTimeout is the time each operation adds to the deadline of a
+// bulk request, on top of dbTimeout
+const bulkOperationTimeout = 100 * time.Millisecond
+
+// Operations of a bulk request
+const (
+	BulkCreate      = "create"
+	BulkUpdate      = "update"
+	BulkDelete      = "delete"
+	BulkComplete    = "complete"
+	BulkSetPriority = "set_priority"
+	BulkMoveToList  = "move_to_list"
+)
+
+// Statuses of the operations of a bulk request
+const (
+	BulkOK     = "ok"
+	BulkFailed = "failed"
+	// BulkRolledBack marks operations that succeeded but were undone by the
+	// failure of a later one in an all-or-nothing request
+	BulkRolledBack = "rolled_back"
+	// BulkSkipped marks operations never attempted because an earlier one
+	// failed in an all-or-nothing request
+	BulkSkipped = "skipped"
+)
+
+// BulkOperation is one change of a bulk request. ID names the todo to change,
+// except for BulkCreate.
+type BulkOperation struct {
+	Op string
+	ID int
+	// Todo is the todo to create, or the new fields of the one to update
+	Todo       Todo
+	PriorityID int
+	ListID     int
+	Completed  bool
+	// Version, unless 0, is the version of the todo to delete; updates use
+	// Todo.Version
+	Version int
+}
+
+// BulkResult reports what happened to one operation of a bulk request.
+type BulkResult struct {
+	Op string `json:"op"`
+	// ID is the changed todo, or the created one
+	ID     int    `json:"id,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// NextID is the next occurrence created by completing a recurring todo
+	NextID int `json:"next_id,omitempty"`
+}
+
+// bulkResults starts every operation off as skipped.
+func bulkResults(ops []BulkOperation) []BulkResult {
+	results := make([]BulkResult, len(ops))
+	for i, op := range ops {
+		results[i] = BulkResult{Op: op.Op, ID: op.ID, Status: BulkSkipped}
+	}
+
+	return results
+}
+
+// rollBack marks the operations that succeeded before a failure in an
+// all-or-nothing request as undone.
+func rollBack(results []BulkResult) {
+	for i := range results {
+		if results[i].Status == BulkOK {
+			results[i].Status, results[i].NextID = BulkRolledBack, 0
+			if results[i].Op == BulkCreate {
+				results[i].ID = 0
+			}
+		}
+	}
+}
+
+// Bulk applies ops to the user's todos in a single transaction. When atomic,
+// the first failure rolls everything back; otherwise each failed operation is
+// rolled back on its own, through a savepoint, and the others are kept.
+// Failures are reported in the results, the error is only for the batch as
+// a whole.
+func (r *SQLTodoRepository) Bulk(ctx context.Context, userID int, ops []BulkOperation, atomic bool) (results []BulkResult, err error) {
+	// The transaction runs every operation, so its deadline grows with them
+	ctx, cancel := context.WithTimeout(ctx, bulkTimeout(len(ops)))
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	results = bulkResults(ops)
+	for i, op := range ops {
+		if !atomic {
+			if _, err = tx.ExecContext(ctx, "SAVEPOINT bulk_operation"); err != nil {
+				return nil, err
+			}
+		}
+
+		id, nextID, opErr := r.bulkOperation(ctx, tx, userID, op)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		if opErr != nil {
+			results[i].Status, results[i].Error = BulkFailed, opErr.Error()
+			if atomic {
+				rollBack(results)
+				return results, nil
+			}
+			if _, err = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT bulk_operation"); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if !atomic {
+			if _, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT bulk_operation"); err != nil {
+				return nil, err
+			}
+		}
+		results[i].ID, results[i].NextID, results[i].Status = id, nextID, BulkOK
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// bulkTimeout is the deadline of a bulk request of n operations.
+func bulkTimeout(n int) time.Duration {
+	return dbTimeout + time.Duration(n)*bulkOperationTimeout
+}
+
+// bulkOperation applies op within tx, returning the ID of the todo it
+// changed or created and, for completions, of the next occurrence.
+func (r *SQLTodoRepository) bulkOperation(ctx context.Context, tx dbtx, userID int, op BulkOperation) (id, nextID int, err error) {
+	switch op.Op {
+	case BulkCreate:
+		todo := op.Todo
+		todo.UserID = userID
+		err = r.createTodo(ctx, tx, &todo)
+		return todo.ID, 0, err
+	case BulkUpdate:
+		todo := op.Todo
+		todo.ID, todo.UserID = op.ID, userID
+		return op.ID, 0, r.updateTodo(ctx, tx, &todo, TodoUpdated)
+	case BulkDelete:
+		return op.ID, 0, r.deleteTodo(ctx, tx, op.ID, userID, op.Version)
+	case BulkComplete:
+		nextID, err = r.setCompleted(ctx, tx, op.ID, userID, op.Completed)
+		return op.ID, nextID, err
+	case BulkSetPriority, BulkMoveToList:
+		// Tags stay nil, which leaves them alone
+		todo, err := r.liveTodo(ctx, tx, op.ID, userID)
+		if err != nil {
+			return op.ID, 0, err
+		}
+		if op.Op == BulkSetPriority {
+			todo.PriorityID = op.PriorityID
+		} else {
+			todo.ListID = op.ListID
+		}
+		return op.ID, 0, r.updateTodo(ctx, tx, &todo, TodoUpdated)
+	}
+
+	return op.ID, 0, fmt.Errorf("unknown operation %q", op.Op)
+}
@@ -0,0 +1,123 @@
+package data_test
+
+import (
+	"context"
+	"slices"
+	"task-app/db/data"
+	"testing"
+)
+
+// statuses lists the status of each result.
+func statuses(results []data.BulkResult) []string {
+	var got []string
+	for _, result := range results {
+		got = append(got, result.Status)
+	}
+
+	return got
+}
+
+func TestTodoRepositoryBulkAtomic(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		ada := newUser(t, models, "ada")
+		todo := newTodo(t, models, ada, "Keep")
+
+		ops := []data.BulkOperation{
+			{Op: data.BulkCreate, Todo: data.Todo{PriorityID: 1, Text: "Undone"}},
+			{Op: data.BulkComplete, ID: todo.ID, Completed: true},
+			{Op: data.BulkDelete, ID: todo.ID + 100},
+			{Op: data.BulkSetPriority, ID: todo.ID, PriorityID: 3},
+		}
+		results, err := models.Todo.Bulk(ctx, ada, ops, true)
+		if err != nil {
+			t.Fatalf("Bulk() error = %v", err)
+		}
+		want := []string{data.BulkRolledBack, data.BulkRolledBack, data.BulkFailed, data.BulkSkipped}
+		if got := statuses(results); !slices.Equal(got, want) {
+			t.Fatalf("Bulk() statuses = %v, want %v", got, want)
+		}
+		if results[0].ID != 0 || results[2].Error == "" {
+			t.Errorf("Bulk() results = %+v, want no ID for the undone create and an error for the failure", results)
+		}
+
+		// Nothing was applied
+		if count, err := models.Todo.Count(ctx, ada, data.TodoFilter{}); err != nil || count != 1 {
+			t.Errorf("Count() = %d, %v; want the 1 todo from before", count, err)
+		}
+		got, err := models.Todo.Get(ctx, todo.ID, ada)
+		if err != nil || got.Completed || got.PriorityID != 1 || got.Version != 1 {
+			t.Errorf("Get() = %+v, %v; want it untouched", got, err)
+		}
+		if got := actions(t, models, todo.ID, ada); !slices.Equal(got, []string{data.TodoCreated}) {
+			t.Errorf("History() = %v, want only its creation", got)
+		}
+
+		// Without a failure, everything is applied
+		ops = ops[:2]
+		results, err = models.Todo.Bulk(ctx, ada, ops, true)
+		if err != nil {
+			t.Fatalf("Bulk() error = %v", err)
+		}
+		if got := statuses(results); !slices.Equal(got, []string{data.BulkOK, data.BulkOK}) {
+			t.Fatalf("Bulk() statuses = %v, want all ok", got)
+		}
+		if _, err := models.Todo.Get(ctx, results[0].ID, ada); err != nil {
+			t.Errorf("Get(created) error = %v", err)
+		}
+		if got, err := models.Todo.Get(ctx, todo.ID, ada); err != nil || !got.Completed {
+			t.Errorf("Get() = %+v, %v; want it completed", got, err)
+		}
+	})
+}
+
+func TestTodoRepositoryBulkBestEffort(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		ada, bob := newUser(t, models, "ada"), newUser(t, models, "bob")
+		first, second := newTodo(t, models, ada, "First"), newTodo(t, models, ada, "Second")
+		theirs := newTodo(t, models, bob, "Theirs")
+
+		ops := []data.BulkOperation{
+			{Op: data.BulkCreate, Todo: data.Todo{PriorityID: 1, Text: "Created"}},
+			{Op: data.BulkUpdate, ID: first.ID, Todo: data.Todo{PriorityID: 2, Text: "Stale", Version: 7}},
+			{Op: data.BulkSetPriority, ID: first.ID, PriorityID: 3},
+			{Op: data.BulkDelete, ID: theirs.ID},
+			{Op: data.BulkMoveToList, ID: second.ID, ListID: theirs.ListID},
+			{Op: data.BulkComplete, ID: second.ID, Completed: true},
+		}
+		results, err := models.Todo.Bulk(ctx, ada, ops, false)
+		if err != nil {
+			t.Fatalf("Bulk() error = %v", err)
+		}
+		want := []string{data.BulkOK, data.BulkFailed, data.BulkOK, data.BulkFailed, data.BulkFailed, data.BulkOK}
+		if got := statuses(results); !slices.Equal(got, want) {
+			t.Fatalf("Bulk() statuses = %+v, want %v", results, want)
+		}
+
+		// The operations that succeeded are kept, around the failed ones
+		created, err := models.Todo.Get(ctx, results[0].ID, ada)
+		if err != nil || created.Text != "Created" {
+			t.Errorf("Get(created) = %+v, %v", created, err)
+		}
+		got, err := models.Todo.Get(ctx, first.ID, ada)
+		if err != nil || got.Text != "First" || got.PriorityID != 3 || got.Version != 2 {
+			t.Errorf("Get(first) = %+v, %v; want only its new priority, at version 2", got, err)
+		}
+		got, err = models.Todo.Get(ctx, second.ID, ada)
+		if err != nil || !got.Completed || got.ListID != second.ListID {
+			t.Errorf("Get(second) = %+v, %v; want it completed in its own list", got, err)
+		}
+		if _, err := models.Todo.Get(ctx, theirs.ID, bob); err != nil {
+			t.Errorf("Get() of bob's todo error = %v, want it left alone", err)
+		}
+
+		// The failed operations left nothing behind
+		if got := actions(t, models, first.ID, ada); !slices.Equal(got, []string{data.TodoCreated, data.TodoUpdated}) {
+			t.Errorf("History(first) = %v, want one update", got)
+		}
+		if got := actions(t, models, second.ID, ada); !slices.Equal(got, []string{data.TodoCreated, data.TodoCompleted}) {
+			t.Errorf("History(second) = %v, want only its completion", got)
+		}
+	})
+}
@@ -0,0 +1,385 @@
+package data
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Sort orders accepted in TodoFilter.Sort
+const (
+	SortDefault   = ""
+	SortCreatedAt = "created_at"
+	SortUpdatedAt = "updated_at"
+	SortPriority  = "priority"
+	SortDueAt     = "due_at"
+	SortPosition  = "position"
+)
+
+// ErrInvalidCursor is returned for cursors that are malformed or were issued for a different sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// TodoFilter narrows, orders and pages the todos returned by GetAll. Zero
+// fields don't filter.
+type TodoFilter struct {
+	// ListID keeps the todos of one list. Without it, todos in archived
+	// lists are left out unless IncludeArchived is set.
+	ListID          int
+	IncludeArchived bool
+	// Trashed keeps only the deleted todos, which are otherwise left out
+	Trashed     bool
+	Completed   *bool
+	PriorityIDs []int
+	// Tags keeps todos carrying any of the tags, or all of them with TagsMatchAll
+	Tags         []string
+	TagsMatchAll bool
+	// Text keeps todos whose text contains it, ignoring case
+	Text string
+	// The *From bounds are inclusive, the *Before bounds exclusive
+	CreatedFrom   *time.Time
+	CreatedBefore *time.Time
+	UpdatedFrom   *time.Time
+	UpdatedBefore *time.Time
+	// Todos without a due date never match when either due bound is set
+	DueFrom   *time.Time
+	DueBefore *time.Time
+	// ReminderFiredAfter keeps todos whose reminder fired after this instant
+	ReminderFiredAfter *time.Time
+
+	Sort       string
+	Descending bool
+	// After continues a previous page: only todos sorting after it are returned
+	After *TodoCursor
+	// Limit caps the number of todos returned, 0 means no limit
+	Limit int
+}
+
+// TodoCursor is the keyset position of a todo within a sort order. It holds
+// the sort value of the last todo on a page plus its ID as a tie-breaker.
+type TodoCursor struct {
+	Sort       string     `json:"s,omitempty"`
+	Descending bool       `json:"d,omitempty"`
+	ID         int        `json:"id"`
+	Time       *time.Time `json:"t,omitempty"`
+	Rank       int        `json:"r,omitempty"`
+	Position   string     `json:"p,omitempty"`
+}
+
+// CursorFor returns the cursor positioned on todo under the filter's sort order.
+func (f TodoFilter) CursorFor(todo Todo) TodoCursor {
+	c := TodoCursor{Sort: f.Sort, Descending: f.Descending, ID: todo.ID}
+
+	switch f.Sort {
+	case SortCreatedAt:
+		c.Time = utc(&todo.CreatedAt)
+	case SortUpdatedAt:
+		c.Time = utc(&todo.UpdatedAt)
+	case SortPriority:
+		c.Rank = todo.Priority.Rank
+	case SortDueAt:
+		c.Time = utc(todo.DueAt)
+	case SortPosition:
+		c.Position = todo.Position
+	}
+
+	return c
+}
+
+// Encode turns the cursor into an opaque URL-safe string.
+func (c TodoCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor parses a cursor produced by Encode and checks it belongs
+// to the filter's sort order.
+func (f TodoFilter) DecodeCursor(s string) (*TodoCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c TodoCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID < 1 {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != f.Sort || c.Descending != f.Descending {
+		return nil, ErrInvalidCursor
+	}
+	if (c.Sort == SortCreatedAt || c.Sort == SortUpdatedAt) && c.Time == nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort == SortPosition && c.Position == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// sqlWhere renders the filter (including the cursor) as conditions on the
+// todos table aliased t, with "?" placeholders.
+func (f TodoFilter) sqlWhere(postgres bool) (string, []any) {
+	var conditions []string
+	var args []any
+
+	add := func(condition string, values ...any) {
+		conditions = append(conditions, condition)
+		args = append(args, values...)
+	}
+
+	if f.Trashed {
+		add("t.deleted_at IS NOT NULL")
+	} else {
+		add("t.deleted_at IS NULL")
+	}
+	if f.ListID != 0 {
+		add("t.list_id = ?", f.ListID)
+	} else if !f.IncludeArchived {
+		add(todoNotArchived)
+	}
+	if f.Completed != nil {
+		add("t.completed = ?", *f.Completed)
+	}
+	if len(f.PriorityIDs) > 0 {
+		values := make([]any, len(f.PriorityIDs))
+		for i, id := range f.PriorityIDs {
+			values[i] = id
+		}
+		add("t.priority_id IN ("+placeholders(len(values))+")", values...)
+	}
+	if tags := NormalizeTags(f.Tags); len(tags) > 0 {
+		values := make([]any, len(tags))
+		for i, tag := range tags {
+			values[i] = tag
+		}
+		tagged := `
+            SELECT COUNT(*) FROM todo_tags tt JOIN tags tg ON tg.id = tt.tag_id
+            WHERE tt.todo_id = t.id AND tg.name IN (` + placeholders(len(values)) + `)`
+		if f.TagsMatchAll {
+			// Names are unique per user, so the count reaches len(tags) only if every tag is there
+			add("("+tagged+") = ?", append(values, len(values))...)
+		} else {
+			add("("+tagged+") > 0", values...)
+		}
+	}
+	if f.Text != "" {
+		// SQLite's LIKE already ignores ASCII case, Postgres needs ILIKE
+		like := "LIKE"
+		if postgres {
+			like = "ILIKE"
+		}
+		add("t.text "+like+" ? ESCAPE '\\'", "%"+escapeLike(f.Text)+"%")
+	}
+
+	bounds := []struct {
+		column string
+		op     string
+		value  *time.Time
+	}{
+		{"t.created_at", ">=", f.CreatedFrom},
+		{"t.created_at", "<", f.CreatedBefore},
+		{"t.updated_at", ">=", f.UpdatedFrom},
+		{"t.updated_at", "<", f.UpdatedBefore},
+		{"t.due_at", ">=", f.DueFrom},
+		{"t.due_at", "<", f.DueBefore},
+		{"t.reminder_fired_at", ">", f.ReminderFiredAfter},
+	}
+	for _, bound := range bounds {
+		if bound.value != nil {
+			add(bound.column+" "+bound.op+" ?", utc(bound.value))
+		}
+	}
+
+	if f.After != nil {
+		condition, values := f.sqlKeyset()
+		add(condition, values...)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// sqlKeyset renders "sorts after the cursor" for the filter's sort order.
+func (f TodoFilter) sqlKeyset() (string, []any) {
+	c := f.After
+	cmp := ">"
+	if f.Descending {
+		cmp = "<"
+	}
+
+	var column string
+	var value any
+	switch f.Sort {
+	case SortCreatedAt:
+		column, value = "t.created_at", utc(c.Time)
+	case SortUpdatedAt:
+		column, value = "t.updated_at", utc(c.Time)
+	case SortPriority:
+		column, value = "p.rank", c.Rank
+	case SortPosition:
+		column, value = "t.position", c.Position
+	case SortDueAt:
+		// NULL due dates sort last in both directions
+		if c.Time == nil {
+			return "(t.due_at IS NULL AND t.id " + cmp + " ?)", []any{c.ID}
+		}
+		return "(t.due_at IS NULL OR t.due_at " + cmp + " ? OR (t.due_at = ? AND t.id " + cmp + " ?))",
+			[]any{utc(c.Time), utc(c.Time), c.ID}
+	default:
+		return "t.id " + cmp + " ?", []any{c.ID}
+	}
+
+	return "(" + column + " " + cmp + " ? OR (" + column + " = ? AND t.id " + cmp + " ?))", []any{value, value, c.ID}
+}
+
+// sqlOrderBy renders the ORDER BY clause matching sqlKeyset.
+func (f TodoFilter) sqlOrderBy() string {
+	dir := " ASC"
+	if f.Descending {
+		dir = " DESC"
+	}
+
+	switch f.Sort {
+	case SortCreatedAt:
+		return " ORDER BY t.created_at" + dir + ", t.id" + dir
+	case SortUpdatedAt:
+		return " ORDER BY t.updated_at" + dir + ", t.id" + dir
+	case SortPriority:
+		return " ORDER BY p.rank" + dir + ", t.id" + dir
+	case SortPosition:
+		return " ORDER BY t.position" + dir + ", t.id" + dir
+	case SortDueAt:
+		// Todos without a due date go last on both SQLite and Postgres
+		return " ORDER BY t.due_at IS NULL, t.due_at" + dir + ", t.id" + dir
+	default:
+		return " ORDER BY t.id" + dir
+	}
+}
+
+// matches applies the filter the same way the SQL WHERE clause does, except
+// for archived lists which the caller checks.
+func (f TodoFilter) matches(todo Todo) bool {
+	if (todo.DeletedAt != nil) != f.Trashed {
+		return false
+	}
+	if f.ListID != 0 && todo.ListID != f.ListID {
+		return false
+	}
+	if f.Completed != nil && todo.Completed != *f.Completed {
+		return false
+	}
+	if len(f.PriorityIDs) > 0 && !containsInt(f.PriorityIDs, todo.PriorityID) {
+		return false
+	}
+	if tags := NormalizeTags(f.Tags); len(tags) > 0 {
+		found := 0
+		for _, tag := range tags {
+			if containsString(todo.Tags, tag) {
+				found++
+			}
+		}
+		if found == 0 || (f.TagsMatchAll && found < len(tags)) {
+			return false
+		}
+	}
+	if f.Text != "" && !strings.Contains(strings.ToLower(todo.Text), strings.ToLower(f.Text)) {
+		return false
+	}
+	if !inRange(&todo.CreatedAt, f.CreatedFrom, f.CreatedBefore) || !inRange(&todo.UpdatedAt, f.UpdatedFrom, f.UpdatedBefore) {
+		return false
+	}
+	if (f.DueFrom != nil || f.DueBefore != nil) && !inRange(todo.DueAt, f.DueFrom, f.DueBefore) {
+		return false
+	}
+	if f.ReminderFiredAfter != nil && (todo.ReminderFiredAt == nil || !todo.ReminderFiredAt.After(*f.ReminderFiredAfter)) {
+		return false
+	}
+	if f.After != nil && !f.less(f.After.todo(), todo) {
+		return false
+	}
+
+	return true
+}
+
+// less reports whether a sorts before b, mirroring sqlOrderBy.
+func (f TodoFilter) less(a, b Todo) bool {
+	// NULL due dates go last regardless of direction
+	if f.Sort == SortDueAt && (a.DueAt == nil) != (b.DueAt == nil) {
+		return b.DueAt == nil
+	}
+
+	var cmp int
+	switch f.Sort {
+	case SortCreatedAt:
+		cmp = a.CreatedAt.Compare(b.CreatedAt)
+	case SortUpdatedAt:
+		cmp = a.UpdatedAt.Compare(b.UpdatedAt)
+	case SortPriority:
+		cmp = a.Priority.Rank - b.Priority.Rank
+	case SortPosition:
+		// Byte-wise, like the "C" collation the column uses
+		cmp = strings.Compare(a.Position, b.Position)
+	case SortDueAt:
+		if a.DueAt != nil {
+			cmp = a.DueAt.Compare(*b.DueAt)
+		}
+	}
+	if cmp == 0 {
+		cmp = a.ID - b.ID
+	}
+
+	if f.Descending {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+// todo builds a stand-in Todo carrying just the cursor's sort values.
+func (c TodoCursor) todo() Todo {
+	todo := Todo{ID: c.ID, Priority: Priority{Rank: c.Rank}, DueAt: c.Time, Position: c.Position}
+	if c.Time != nil {
+		todo.CreatedAt, todo.UpdatedAt = *c.Time, *c.Time
+	}
+
+	return todo
+}
+
+func inRange(t, from, before *time.Time) bool {
+	if t == nil {
+		return from == nil && before == nil
+	}
+	if from != nil && t.Before(*from) {
+		return false
+	}
+	if before != nil && !t.Before(*before) {
+		return false
+	}
+
+	return true
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// escapeLike escapes the LIKE wildcards in s, using backslash as the escape character.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,120 @@
+package data
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	due := time.Date(2030, 1, 2, 9, 0, 0, 0, time.UTC)
+	encoded := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	tests := []struct {
+		name   string
+		filter TodoFilter
+		cursor string
+		want   *TodoCursor
+	}{
+		{"default", TodoFilter{}, TodoCursor{ID: 7}.Encode(), &TodoCursor{ID: 7}},
+		{"descending", TodoFilter{Descending: true}, TodoCursor{Descending: true, ID: 7}.Encode(), &TodoCursor{Descending: true, ID: 7}},
+		{"due date", TodoFilter{Sort: SortDueAt}, TodoCursor{Sort: SortDueAt, ID: 7, Time: &due}.Encode(), &TodoCursor{Sort: SortDueAt, ID: 7, Time: &due}},
+		// A todo without a due date sorts after every todo that has one
+		{"no due date", TodoFilter{Sort: SortDueAt}, TodoCursor{Sort: SortDueAt, ID: 7}.Encode(), &TodoCursor{Sort: SortDueAt, ID: 7}},
+		{"priority", TodoFilter{Sort: SortPriority}, TodoCursor{Sort: SortPriority, ID: 7, Rank: 2}.Encode(), &TodoCursor{Sort: SortPriority, ID: 7, Rank: 2}},
+		{"position", TodoFilter{Sort: SortPosition}, TodoCursor{Sort: SortPosition, ID: 7, Position: "a0"}.Encode(), &TodoCursor{Sort: SortPosition, ID: 7, Position: "a0"}},
+
+		{"not base64", TodoFilter{}, "not a cursor!", nil},
+		{"padded base64", TodoFilter{}, base64.URLEncoding.EncodeToString([]byte(`{"id":7}`)), nil},
+		{"not JSON", TodoFilter{}, encoded("id=7"), nil},
+		{"wrong type", TodoFilter{}, encoded(`{"id":"7"}`), nil},
+		{"no ID", TodoFilter{}, encoded(`{}`), nil},
+		{"negative ID", TodoFilter{}, encoded(`{"id":-1}`), nil},
+		{"other sort", TodoFilter{Sort: SortCreatedAt}, TodoCursor{ID: 7}.Encode(), nil},
+		{"other direction", TodoFilter{}, TodoCursor{Descending: true, ID: 7}.Encode(), nil},
+		{"no time", TodoFilter{Sort: SortCreatedAt}, TodoCursor{Sort: SortCreatedAt, ID: 7}.Encode(), nil},
+		{"no position", TodoFilter{Sort: SortPosition}, TodoCursor{Sort: SortPosition, ID: 7}.Encode(), nil},
+		{"tampered", TodoFilter{}, TodoCursor{ID: 7}.Encode()[1:], nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.filter.DecodeCursor(tt.cursor)
+			if tt.want == nil {
+				if !errors.Is(err, ErrInvalidCursor) {
+					t.Fatalf("DecodeCursor() = %+v, %v; want ErrInvalidCursor", got, err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("DecodeCursor() = %+v, %v; want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTodoFilterSQLKeyset(t *testing.T) {
+	due := time.Date(2030, 1, 2, 9, 0, 0, 0, time.FixedZone("CET", 3600))
+	utcDue := due.UTC()
+
+	tests := []struct {
+		name      string
+		filter    TodoFilter
+		cursor    TodoCursor
+		condition string
+		args      []any
+	}{
+		{"default", TodoFilter{}, TodoCursor{ID: 7}, "t.id > ?", []any{7}},
+		{"default descending", TodoFilter{Descending: true}, TodoCursor{ID: 7}, "t.id < ?", []any{7}},
+		{"created at", TodoFilter{Sort: SortCreatedAt}, TodoCursor{ID: 7, Time: &due},
+			"(t.created_at > ? OR (t.created_at = ? AND t.id > ?))", []any{&utcDue, &utcDue, 7}},
+		{"updated at descending", TodoFilter{Sort: SortUpdatedAt, Descending: true}, TodoCursor{ID: 7, Time: &due},
+			"(t.updated_at < ? OR (t.updated_at = ? AND t.id < ?))", []any{&utcDue, &utcDue, 7}},
+		{"priority", TodoFilter{Sort: SortPriority}, TodoCursor{ID: 7, Rank: 2},
+			"(p.rank > ? OR (p.rank = ? AND t.id > ?))", []any{2, 2, 7}},
+		{"position descending", TodoFilter{Sort: SortPosition, Descending: true}, TodoCursor{ID: 7, Position: "a0"},
+			"(t.position < ? OR (t.position = ? AND t.id < ?))", []any{"a0", "a0", 7}},
+		// The todos without a due date follow those with one either way
+		{"due date", TodoFilter{Sort: SortDueAt}, TodoCursor{ID: 7, Time: &due},
+			"(t.due_at IS NULL OR t.due_at > ? OR (t.due_at = ? AND t.id > ?))", []any{&utcDue, &utcDue, 7}},
+		{"due date descending", TodoFilter{Sort: SortDueAt, Descending: true}, TodoCursor{ID: 7, Time: &due},
+			"(t.due_at IS NULL OR t.due_at < ? OR (t.due_at = ? AND t.id < ?))", []any{&utcDue, &utcDue, 7}},
+		{"no due date", TodoFilter{Sort: SortDueAt}, TodoCursor{ID: 7}, "(t.due_at IS NULL AND t.id > ?)", []any{7}},
+		{"no due date descending", TodoFilter{Sort: SortDueAt, Descending: true}, TodoCursor{ID: 7}, "(t.due_at IS NULL AND t.id < ?)", []any{7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := tt.filter
+			filter.After = &tt.cursor
+			condition, args := filter.sqlKeyset()
+			if condition != tt.condition || !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("sqlKeyset() = %q, %v; want %q, %v", condition, args, tt.condition, tt.args)
+			}
+		})
+	}
+}
+
+func TestTodoFilterSQLOrderBy(t *testing.T) {
+	tests := []struct {
+		filter TodoFilter
+		want   string
+	}{
+		{TodoFilter{}, " ORDER BY t.id ASC"},
+		{TodoFilter{Descending: true}, " ORDER BY t.id DESC"},
+		{TodoFilter{Sort: SortCreatedAt}, " ORDER BY t.created_at ASC, t.id ASC"},
+		{TodoFilter{Sort: SortUpdatedAt, Descending: true}, " ORDER BY t.updated_at DESC, t.id DESC"},
+		{TodoFilter{Sort: SortPriority}, " ORDER BY p.rank ASC, t.id ASC"},
+		{TodoFilter{Sort: SortPosition, Descending: true}, " ORDER BY t.position DESC, t.id DESC"},
+		// FALSE sorts before TRUE, so NULL due dates go last either way
+		{TodoFilter{Sort: SortDueAt}, " ORDER BY t.due_at IS NULL, t.due_at ASC, t.id ASC"},
+		{TodoFilter{Sort: SortDueAt, Descending: true}, " ORDER BY t.due_at IS NULL, t.due_at DESC, t.id DESC"},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.sqlOrderBy(); got != tt.want {
+			t.Errorf("TodoFilter{Sort: %q, Descending: %v}.sqlOrderBy() = %q, want %q", tt.filter.Sort, tt.filter.Descending, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Actions recorded in a todo's history
+const (
+	TodoCreated     = "created"
+	TodoUpdated     = "updated"
+	TodoCompleted   = "completed"
+	TodoUncompleted = "uncompleted"
+	TodoDeleted     = "deleted"
+	TodoMoved       = "moved"
+	TodoRestored    = "restored"
+	TodoPurged      = "purged"
+	TodoUndone      = "undone"
+	TodoRedone      = "redone"
+)
+
+// TodoEvent is one entry of a todo's history. Events are added in the same
+// transaction as the change they record and are only ever updated to mark
+// them undone. They outlive a purged todo, whose last event records the purge.
+type TodoEvent struct {
+	ID     int `json:"id"`
+	TodoID int `json:"todo_id"`
+	// UserID is the user who made the change
+	UserID  int                    `json:"user_id"`
+	Action  string                 `json:"action"`
+	Changes map[string]FieldChange `json:"changes,omitempty"`
+	// CreatedAt is when the change was made
+	CreatedAt time.Time `json:"created_at"`
+	// UndoneAt is set while the change is undone
+	UndoneAt *time.Time `json:"undone_at,omitempty"`
+}
+
+// FieldChange holds the JSON values of a field before and after a change. A
+// created todo's fields have no Before.
+type FieldChange struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// todoFields are the fields whose changes the history records, by their JSON
+// name. Tags are left out when they aren't known, as with an Update that
+// doesn't touch them.
+func todoFields(todo *Todo) map[string]any {
+	fields := map[string]any{
+		"text":        todo.Text,
+		"priority_id": todo.PriorityID,
+		"list_id":     todo.ListID,
+		"due_at":      utc(todo.DueAt),
+		"remind_at":   utc(todo.RemindAt),
+		"recurrence":  todo.Recurrence,
+	}
+	if todo.Tags != nil {
+		fields["tags"] = todo.Tags
+	}
+
+	return fields
+}
+
+// todoChanges compares the fields of a todo before and after a change. A nil
+// before describes a new todo, whose fields that are set are all recorded.
+func todoChanges(before, after *Todo) (map[string]FieldChange, error) {
+	var old map[string]any
+	if before != nil {
+		old = todoFields(before)
+	}
+
+	changes := map[string]FieldChange{}
+	for field, value := range todoFields(after) {
+		afterJSON, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+
+		var beforeJSON json.RawMessage
+		if before == nil {
+			if isEmptyJSON(afterJSON) {
+				continue
+			}
+		} else {
+			oldValue, ok := old[field]
+			if !ok {
+				continue
+			}
+			if beforeJSON, err = json.Marshal(oldValue); err != nil {
+				return nil, err
+			}
+			if bytes.Equal(beforeJSON, afterJSON) {
+				continue
+			}
+		}
+
+		changes[field] = FieldChange{Before: beforeJSON, After: afterJSON}
+	}
+
+	return changes, nil
+}
+
+// fieldChange records a change of a single field.
+func fieldChange(before, after any) (FieldChange, error) {
+	beforeJSON, err := json.Marshal(before)
+	if err != nil {
+		return FieldChange{}, err
+	}
+	afterJSON, err := json.Marshal(after)
+
+	return FieldChange{Before: beforeJSON, After: afterJSON}, err
+}
+
+func isEmptyJSON(value []byte) bool {
+	switch string(value) {
+	case "null", `""`, "0", "[]":
+		return true
+	}
+	return false
+}
+
+// todoEventSelect loads events in the column order scanTodoEvent expects
+const todoEventSelect = "SELECT id, todo_id, user_id, action, changes, created_at, undone_at FROM todo_events"
+
+func scanTodoEvent(row rowScanner, event *TodoEvent) error {
+	var changes string
+	if err := row.Scan(&event.ID, &event.TodoID, &event.UserID, &event.Action, &changes, &event.CreatedAt, &event.UndoneAt); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(changes), &event.Changes); err != nil {
+		return err
+	}
+	if len(event.Changes) == 0 {
+		event.Changes = nil
+	}
+
+	return nil
+}
+
+// recordEvent appends event to its todo's history, within the transaction of
+// the change it records.
+func (s sqlStore) recordEvent(ctx context.Context, tx dbtx, event *TodoEvent) error {
+	changes, err := json.Marshal(event.Changes)
+	if err != nil {
+		return err
+	}
+	if event.Changes == nil {
+		changes = []byte("{}")
+	}
+
+	event.CreatedAt = timeNow()
+	query := s.rebind("INSERT INTO todo_events (todo_id, user_id, action, changes, created_at) VALUES (?, ?, ?, ?, ?) RETURNING id")
+
+	return tx.QueryRowContext(ctx, query, event.TodoID, event.UserID, event.Action, string(changes), event.CreatedAt).Scan(&event.ID)
+}
+
+// reassignTodos moves the user's todos whose column field is from to to, as
+// deleting their priority or list does, recording the change in the history
+// of each. It returns how many todos it moved.
+func (s sqlStore) reassignTodos(ctx context.Context, tx dbtx, userID int, field string, from, to int) (int, error) {
+	rows, err := tx.QueryContext(ctx, s.rebind("SELECT id FROM todos WHERE "+field+" = ? AND user_id = ?"), from, userID)
+	if err != nil {
+		return 0, err
+	}
+	var IDs []int
+	for rows.Next() {
+		var ID int
+		if err = rows.Scan(&ID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		IDs = append(IDs, ID)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	query := s.rebind("UPDATE todos SET " + field + " = ?, updated_at = ?, version = version + 1 WHERE " + field + " = ? AND user_id = ?")
+	if _, err = tx.ExecContext(ctx, query, to, timeNow(), from, userID); err != nil {
+		return 0, err
+	}
+
+	change, err := fieldChange(from, to)
+	if err != nil {
+		return 0, err
+	}
+	for _, ID := range IDs {
+		event := &TodoEvent{TodoID: ID, UserID: userID, Action: TodoUpdated, Changes: map[string]FieldChange{field: change}}
+		if err = s.recordEvent(ctx, tx, event); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(IDs), nil
+}
+
+// History returns the events of one of the user's todos, oldest first. Todos
+// in the trash and purged ones keep their history.
+func (r *SQLTodoRepository) History(ctx context.Context, ID, userID int) (events []TodoEvent, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	query := r.rebind(todoEventSelect + " WHERE todo_id = ? AND user_id = ? ORDER BY id")
+	rows, err := r.db.QueryContext(ctx, query, ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events = []TodoEvent{}
+	for rows.Next() {
+		var event TodoEvent
+		if err := scanTodoEvent(rows, &event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(events) > 0 {
+		return events, nil
+	}
+
+	// Todos from before the history was kept have none
+	var found int
+	err = r.db.QueryRowContext(ctx, r.rebind("SELECT 1 FROM todos WHERE id = ? AND user_id = ?"), ID, userID).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,40 @@
+package data
+
+import "task-app/recurrence"
+
+// nextOccurrence builds the todo following a recurring one: the same text,
+// priority, list, tags and rule, due at the rule's next occurrence, with the
+// reminder as long before it as before. ok is false when todo doesn't recur
+// or its series is over.
+func nextOccurrence(todo Todo) (next Todo, ok bool, err error) {
+	if todo.Recurrence == "" || todo.DueAt == nil {
+		return next, false, nil
+	}
+
+	rule, err := recurrence.Parse(todo.Recurrence)
+	if err != nil {
+		return next, false, err
+	}
+
+	occurrences := rule.Occurrences(*todo.DueAt, todo.RecurrenceIndex, 1)
+	if len(occurrences) == 0 {
+		return next, false, nil
+	}
+
+	next = Todo{
+		UserID:          todo.UserID,
+		PriorityID:      todo.PriorityID,
+		ListID:          todo.ListID,
+		Text:            todo.Text,
+		DueAt:           utc(&occurrences[0]),
+		Recurrence:      todo.Recurrence,
+		RecurrenceIndex: todo.RecurrenceIndex + 1,
+		Tags:            todo.Tags,
+	}
+	if todo.RemindAt != nil {
+		remindAt := next.DueAt.Add(todo.RemindAt.Sub(*todo.DueAt))
+		next.RemindAt = &remindAt
+	}
+
+	return next, true, nil
+}
@@ -0,0 +1,248 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// ErrEmptySearch is returned when a search query has no words to look for
+var ErrEmptySearch = errors.New("search query has no searchable words")
+
+// Markers wrapped around matches by the database, swapped for <mark> tags once
+// the snippet has been HTML-escaped
+const (
+	matchStart = "\x02"
+	matchEnd   = "\x03"
+)
+
+// TodoSearchResult is a todo matching a search, best matches first.
+type TodoSearchResult struct {
+	Todo
+	// Snippet is the HTML-escaped part of the text around the matches, each
+	// match wrapped in <mark></mark>
+	Snippet string `json:"snippet"`
+	// Rank orders the results, higher is more relevant
+	Rank float64 `json:"rank"`
+}
+
+// Search finds the user's todos outside archived lists containing every word
+// of query, where the last letters of each word may be missing ("gro"
+// matches "groceries").
+func (r *SQLTodoRepository) Search(ctx context.Context, userID int, query string, limit int) (results []TodoSearchResult, err error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, ErrEmptySearch
+	}
+
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	indexed := r.postgres
+	if !r.postgres {
+		if indexed, err = r.hasSearchIndex(ctx, r.db); err != nil {
+			return nil, err
+		}
+	}
+
+	var sqlQuery string
+	var args []any
+	switch {
+	case r.postgres:
+		sqlQuery = `
+        SELECT` + todoColumns + `,
+            ts_headline('simple', t.text, q, ?), ts_rank(t.search_vector, q)
+        FROM todos t
+        CROSS JOIN to_tsquery('simple', ?) q
+        LEFT JOIN priorities p ON t.priority_id = p.id
+        WHERE t.search_vector @@ q AND t.user_id = ? AND t.deleted_at IS NULL AND ` + todoNotArchived + `
+        ORDER BY ts_rank(t.search_vector, q) DESC, t.id`
+		args = []any{"StartSel=" + matchStart + ", StopSel=" + matchEnd + ", MaxWords=20, MinWords=5", postgresTSQuery(terms), userID}
+	case indexed:
+		// bm25 scores better matches lower, hence the negation
+		sqlQuery = `
+        SELECT` + todoColumns + `,
+            snippet(todos_fts, 0, '` + matchStart + `', '` + matchEnd + `', '…', 16), -bm25(todos_fts)
+        FROM todos_fts
+        JOIN todos t ON t.id = todos_fts.rowid
+        LEFT JOIN priorities p ON t.priority_id = p.id
+        WHERE todos_fts MATCH ? AND t.user_id = ? AND t.deleted_at IS NULL AND ` + todoNotArchived + `
+        ORDER BY bm25(todos_fts), t.id`
+		args = []any{ftsQuery(terms), userID}
+	default:
+		// Without the FTS5 index every term has to appear in the text, even
+		// inside a word, and the whole text makes the snippet
+		conditions := make([]string, len(terms))
+		for i, term := range terms {
+			conditions[i] = "lower(t.text) LIKE ?"
+			args = append(args, "%"+term+"%")
+		}
+		sqlQuery = `
+        SELECT` + todoColumns + `, t.text, 0
+        FROM todos t
+        LEFT JOIN priorities p ON t.priority_id = p.id
+        WHERE ` + strings.Join(conditions, " AND ") + ` AND t.user_id = ? AND t.deleted_at IS NULL AND ` + todoNotArchived + `
+        ORDER BY t.id`
+		args = append(args, userID)
+	}
+	if limit > 0 {
+		sqlQuery += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, r.rebind(sqlQuery), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() // Ensure rows are closed when function exits
+
+	for rows.Next() {
+		var result TodoSearchResult
+		if err := scanTodo(rows, &result.Todo, &result.Snippet, &result.Rank); err != nil {
+			return nil, err
+		}
+		if !indexed {
+			result.Snippet = markTerms(result.Snippet, terms)
+		}
+		result.Snippet = highlight(result.Snippet)
+		results = append(results, result)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close() // Free the connection before loading the tags and checklists
+
+	todos := make([]Todo, len(results))
+	for i := range results {
+		todos[i] = results[i].Todo
+	}
+	if err = r.loadTags(ctx, r.db, todos); err != nil {
+		return nil, err
+	}
+	if err = r.loadChecklists(ctx, r.db, todos); err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Tags = todos[i].Tags
+		results[i].Checklist, results[i].Progress = todos[i].Checklist, todos[i].Progress
+	}
+
+	return results, nil
+}
+
+// hasSearchIndex reports whether the todos_fts index can be used: it only
+// exists once the SQLite library had FTS5, and a library without FTS5 can't
+// read it even then.
+func (r *SQLTodoRepository) hasSearchIndex(ctx context.Context, q dbtx) (bool, error) {
+	if r.postgres {
+		return false, nil
+	}
+
+	var indexed bool
+	err := q.QueryRowContext(ctx, `
+        SELECT sqlite_compileoption_used('ENABLE_FTS5')
+            AND EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'todos_fts')`).Scan(&indexed)
+
+	return indexed, err
+}
+
+// indexTodos adds the text of the todos matching where to the search index.
+// The schema has no triggers doing it, since they would break every write to
+// todos under a SQLite library without FTS5; Postgres keeps search_vector
+// up to date on its own.
+func (r *SQLTodoRepository) indexTodos(ctx context.Context, tx dbtx, where string, args ...any) error {
+	return r.updateSearchIndex(ctx, tx, "INSERT INTO todos_fts (rowid, text) SELECT id, text FROM todos WHERE "+where, args)
+}
+
+// unindexTodos takes the text of the todos matching where out of the search
+// index, before it changes or the todos are deleted. The external-content
+// index can only drop the text it was given.
+func (r *SQLTodoRepository) unindexTodos(ctx context.Context, tx dbtx, where string, args ...any) error {
+	return r.updateSearchIndex(ctx, tx, "INSERT INTO todos_fts (todos_fts, rowid, text) SELECT 'delete', id, text FROM todos WHERE "+where, args)
+}
+
+// updateSearchIndex runs query when there is a search index to keep up to date.
+func (r *SQLTodoRepository) updateSearchIndex(ctx context.Context, tx dbtx, query string, args []any) error {
+	indexed, err := r.hasSearchIndex(ctx, tx)
+	if err != nil || !indexed {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, r.rebind(query), args...)
+	return err
+}
+
+// markTerms wraps the occurrences of terms in text with the match markers,
+// as snippet() does. Text whose lowercase form differs in length is left
+// unmarked, since the positions wouldn't line up.
+func markTerms(text string, terms []string) string {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		return text
+	}
+
+	marked := make([]bool, len(text))
+	for _, term := range terms {
+		for offset := 0; ; {
+			i := strings.Index(lower[offset:], term)
+			if i < 0 {
+				break
+			}
+			for j := offset + i; j < offset+i+len(term); j++ {
+				marked[j] = true
+			}
+			offset += i + len(term)
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if marked[i] && (i == 0 || !marked[i-1]) {
+			b.WriteString(matchStart)
+		}
+		b.WriteByte(text[i])
+		if marked[i] && (i == len(text)-1 || !marked[i+1]) {
+			b.WriteString(matchEnd)
+		}
+	}
+
+	return b.String()
+}
+
+// searchTerms splits a user's query into lowercase words. Punctuation is
+// dropped, so nothing of the FTS5 or tsquery syntax gets through.
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// ftsQuery builds an FTS5 query requiring every term, each as a prefix.
+func ftsQuery(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + term + `"*`
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// postgresTSQuery is the to_tsquery equivalent of ftsQuery.
+func postgresTSQuery(terms []string) string {
+	prefixed := make([]string, len(terms))
+	for i, term := range terms {
+		prefixed[i] = term + ":*"
+	}
+
+	return strings.Join(prefixed, " & ")
+}
+
+// highlight HTML-escapes a snippet and turns the match markers into <mark> tags.
+func highlight(snippet string) string {
+	return strings.NewReplacer(matchStart, "<mark>", matchEnd, "</mark>").Replace(html.EscapeString(snippet))
+}
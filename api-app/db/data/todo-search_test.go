@@ -0,0 +1,229 @@
+package data_test
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"task-app/db"
+	"task-app/db/data"
+	"task-app/db/dbtest"
+	"testing"
+)
+
+func TestTodoRepositorySearch(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		ada, bob := newUser(t, models, "ada"), newUser(t, models, "bob")
+
+		groceries := newTodo(t, models, ada, "Buy groceries")
+		newTodo(t, models, ada, "Ask about the groceries budget")
+		walk := newTodo(t, models, ada, "Walk the dog")
+		newTodo(t, models, bob, "Buy groceries too")
+
+		search := func(query string) []data.TodoSearchResult {
+			t.Helper()
+			results, err := models.Todo.Search(ctx, ada, query, 0)
+			if err != nil {
+				t.Fatalf("Search(%q) error = %v", query, err)
+			}
+			return results
+		}
+
+		// The last letters of a word may be missing, and only the user's
+		// own todos match
+		if results := search("gro"); len(results) != 2 {
+			t.Fatalf("Search(gro) = %d results, want 2", len(results))
+		}
+		results := search("BUY gro")
+		if len(results) != 1 || results[0].ID != groceries.ID {
+			t.Fatalf("Search(BUY gro) = %+v, want the groceries todo", results)
+		}
+		if !strings.Contains(results[0].Snippet, "<mark>") {
+			t.Errorf("snippet %q highlights no match", results[0].Snippet)
+		}
+		if results, err := models.Todo.Search(ctx, ada, "gro", 1); err != nil || len(results) != 1 {
+			t.Errorf("Search(gro, limit 1) = %d results, %v; want 1", len(results), err)
+		}
+
+		// Punctuation is no query syntax
+		_, err := models.Todo.Search(ctx, ada, `"*" -`, 0)
+		wantErr(t, "Search() without words", err, data.ErrEmptySearch)
+
+		// The index follows edits and deletions
+		walk.Text = "Walk the cat"
+		if err := models.Todo.Update(ctx, walk); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		if results := search("dog"); len(results) != 0 {
+			t.Errorf("Search(dog) after the edit = %+v, want nothing", results)
+		}
+		if results := search("cat"); len(results) != 1 {
+			t.Errorf("Search(cat) after the edit = %d results, want 1", len(results))
+		}
+		if err := models.Todo.Delete(ctx, groceries.ID, ada, 0); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if results := search("buy"); len(results) != 0 {
+			t.Errorf("Search(buy) after the delete = %+v, want nothing", results)
+		}
+	})
+}
+
+// searchIDs returns the IDs of the todos the search index matches query
+// with, read from todos_fts itself.
+func searchIDs(t *testing.T, conn *sql.DB, query string) []int {
+	t.Helper()
+
+	rows, err := conn.Query("SELECT rowid FROM todos_fts WHERE todos_fts MATCH ? ORDER BY rowid", query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return ids
+}
+
+func TestTodoRepositorySearchPaths(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, conn *sql.DB, driver string) {
+		migrator := dbtest.Migrate(t, conn, driver)
+		models := data.New(conn, driver)
+		ctx := context.Background()
+		ada := newUser(t, models, "ada")
+		groceries := newTodo(t, models, ada, "Buy groceries")
+		newTodo(t, models, ada, "Groceries, groceries and more groceries")
+
+		search := func(query string) []data.TodoSearchResult {
+			t.Helper()
+			results, err := models.Todo.Search(ctx, ada, query, 0)
+			if err != nil {
+				t.Fatalf("Search(%q) error = %v", query, err)
+			}
+			return results
+		}
+
+		if driver == db.DriverPostgres {
+			// The tsvector matches whole words and prefixes, ranking the
+			// todo naming the groceries the most first
+			results := search("grocer")
+			if len(results) != 2 || results[0].ID == groceries.ID || results[0].Rank <= results[1].Rank {
+				t.Errorf("Search(grocer) = %+v, want both todos, the repeated match first", results)
+			}
+			if !strings.Contains(results[1].Snippet, "<mark>groceries</mark>") {
+				t.Errorf("snippet = %q, want the match highlighted", results[1].Snippet)
+			}
+			if results := search("rocer"); len(results) != 0 {
+				t.Errorf("Search(rocer) = %+v, want no match inside words", results)
+			}
+			return
+		}
+
+		var fts5 bool
+		if err := conn.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5); err != nil {
+			t.Fatal(err)
+		}
+
+		t.Run("fts5", func(t *testing.T) {
+			if !fts5 {
+				t.Skip("the SQLite library lacks FTS5, build with -tags sqlite_fts5")
+			}
+
+			results := search("grocer")
+			if len(results) != 2 || results[0].ID == groceries.ID || results[0].Rank <= results[1].Rank {
+				t.Errorf("Search(grocer) = %+v, want both todos, the repeated match first", results)
+			}
+			if results := search("rocer"); len(results) != 0 {
+				t.Errorf("Search(rocer) = %+v, want no match inside words", results)
+			}
+
+			// No trigger maintains the index, the repository does
+			var triggers int
+			if err := conn.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND tbl_name = 'todos'").Scan(&triggers); err != nil {
+				t.Fatal(err)
+			}
+			if triggers != 0 {
+				t.Errorf("todos has %d triggers, want none", triggers)
+			}
+
+			groceries.Text = "Buy bread"
+			if err := models.Todo.Update(ctx, groceries); err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+			if ids := searchIDs(t, conn, "bread"); !slices.Equal(ids, []int{groceries.ID}) {
+				t.Errorf("index matches bread with %v, want %d", ids, groceries.ID)
+			}
+			if ids := searchIDs(t, conn, "buy"); !slices.Equal(ids, []int{groceries.ID}) {
+				t.Errorf("index matches buy with %v, want %d", ids, groceries.ID)
+			}
+			if err := models.Todo.Delete(ctx, groceries.ID, ada, 0); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if err := models.Todo.Purge(ctx, groceries.ID, ada); err != nil {
+				t.Fatalf("Purge() error = %v", err)
+			}
+			if ids := searchIDs(t, conn, "bread"); len(ids) != 0 {
+				t.Errorf("index matches bread with %v after the purge, want nothing", ids)
+			}
+
+			// Writes made without FTS5 are caught up with at startup
+			if _, err := conn.Exec("INSERT INTO todos_fts (todos_fts) VALUES ('delete-all')"); err != nil {
+				t.Fatal(err)
+			}
+			if results := search("grocer"); len(results) != 0 {
+				t.Fatalf("Search(grocer) on the emptied index = %+v", results)
+			}
+			if err := migrator.RebuildSearchIndex(); err != nil {
+				t.Fatalf("RebuildSearchIndex() error = %v", err)
+			}
+			if results := search("grocer"); len(results) != 1 {
+				t.Errorf("Search(grocer) after the rebuild = %d results, want 1", len(results))
+			}
+		})
+
+		t.Run("like", func(t *testing.T) {
+			// Without the index, as under a SQLite library lacking FTS5
+			if _, err := conn.Exec("DROP TABLE IF EXISTS todos_fts"); err != nil {
+				t.Fatal(err)
+			}
+
+			todo := newTodo(t, models, ada, "Pick up the dry cleaning")
+			results := search("lean")
+			if len(results) != 1 || results[0].ID != todo.ID || results[0].Rank != 0 {
+				t.Fatalf("Search(lean) = %+v, want the todo matched inside a word", results)
+			}
+			if want := "Pick up the dry c<mark>lean</mark>ing"; results[0].Snippet != want {
+				t.Errorf("snippet = %q, want %q", results[0].Snippet, want)
+			}
+			if results := search("groceries more"); len(results) != 1 {
+				t.Errorf("Search(groceries more) = %d results, want 1", len(results))
+			}
+
+			// Writes go on without the index
+			todo.Text = "Pick up the laundry"
+			if err := models.Todo.Update(ctx, todo); err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+			if err := models.Todo.Delete(ctx, todo.ID, ada, 0); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if err := models.Todo.Purge(ctx, todo.ID, ada); err != nil {
+				t.Fatalf("Purge() error = %v", err)
+			}
+			if err := migrator.RebuildSearchIndex(); err != nil {
+				t.Errorf("RebuildSearchIndex() without the index error = %v", err)
+			}
+		})
+	})
+}
@@ -0,0 +1,151 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// Restore takes one of the user's todos out of the trash.
+func (r *SQLTodoRepository) Restore(ctx context.Context, ID, userID int) (err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	if err = r.setDeleted(ctx, tx, ID, userID, false); err != nil {
+		return err
+	}
+
+	if err = r.recordEvent(ctx, tx, &TodoEvent{TodoID: ID, UserID: userID, Action: TodoRestored}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// setDeleted moves one of the user's todos to the trash or takes it out,
+// failing with ErrNotFound when it isn't the user's or is already there.
+func (r *SQLTodoRepository) setDeleted(ctx context.Context, tx dbtx, ID, userID int, deleted bool) error {
+	now := timeNow()
+	query := "UPDATE todos SET deleted_at = ?, updated_at = ?, version = version + 1 WHERE id = ? AND user_id = ? AND deleted_at IS NULL"
+	args := []any{now, now, ID, userID}
+	if !deleted {
+		query = "UPDATE todos SET deleted_at = NULL, updated_at = ?, version = version + 1 WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL"
+		args = args[1:]
+	}
+
+	result, err := tx.ExecContext(ctx, r.rebind(query), args...)
+	if err != nil {
+		return err
+	}
+
+	return expectRows(result)
+}
+
+// Purge deletes one of the user's todos for good. Only todos in the trash
+// can be purged; their tags and checklist items go with them, while their
+// history is kept and records the purge.
+func (r *SQLTodoRepository) Purge(ctx context.Context, ID, userID int) (err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	where := "id = ? AND user_id = ? AND deleted_at IS NOT NULL"
+	if err = r.unindexTodos(ctx, tx, where, ID, userID); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, r.rebind("DELETE FROM todos WHERE "+where), ID, userID)
+	if err != nil {
+		return err
+	}
+	if err = expectRows(result); err != nil {
+		return err
+	}
+
+	if err = r.recordEvent(ctx, tx, &TodoEvent{TodoID: ID, UserID: userID, Action: TodoPurged}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLTodoRepository) EmptyTrash(ctx context.Context, userID int) (purged int, err error) {
+	return r.purge(ctx, "user_id = ? AND deleted_at IS NOT NULL", userID)
+}
+
+func (r *SQLTodoRepository) PurgeDeleted(ctx context.Context, before time.Time) (purged int, err error) {
+	return r.purge(ctx, "deleted_at < ?", before.UTC())
+}
+
+// purge deletes the todos matching where for good, recording the purge in
+// the history of each, and returns how many there were.
+func (r *SQLTodoRepository) purge(ctx context.Context, where string, args ...any) (purged int, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	rows, err := tx.QueryContext(ctx, r.rebind("SELECT id, user_id FROM todos WHERE "+where), args...)
+	if err != nil {
+		return 0, err
+	}
+	var events []TodoEvent
+	for rows.Next() {
+		event := TodoEvent{Action: TodoPurged}
+		if err = rows.Scan(&event.TodoID, &event.UserID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, event)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for i := range events {
+		if err = r.recordEvent(ctx, tx, &events[i]); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = r.unindexTodos(ctx, tx, where, args...); err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, r.rebind("DELETE FROM todos WHERE "+where), args...)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return int(rowsAffected), nil
+}
@@ -0,0 +1,255 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrNothingToUndo is returned by Undo when the user made no change
+	// recently enough, or undid them all
+	ErrNothingToUndo = errors.New("there is nothing to undo")
+	// ErrNothingToRedo is returned by Redo when nothing was undone recently,
+	// or a change was made since
+	ErrNothingToRedo = errors.New("there is nothing to redo")
+	// ErrUndoConflict is returned when the todo changed in a way the undo or
+	// redo would overwrite
+	ErrUndoConflict = errors.New("the todo has changed since, so the change can't be undone or redone")
+)
+
+// undoable reports whether Undo can revert events with this action. Undoing
+// and redoing record their own events, which aren't undoable.
+func undoable(action string) bool {
+	return action == TodoCreated || action == TodoUpdated || action == TodoDeleted || action == TodoMoved
+}
+
+// trashedBy reports whether reverting a creation or deletion moves the todo
+// to the trash: undoing a creation or redoing a deletion does.
+func trashedBy(action string, undo bool) bool {
+	return (action == TodoCreated) == undo
+}
+
+// revertedTodo returns current with the fields changed by an update event
+// set back to their values before it, or forward again when redoing. It
+// fails with ErrUndoConflict when current no longer has the values the event
+// left, or found. The returned todo has nil tags unless the event changed them.
+func revertedTodo(current Todo, changes map[string]FieldChange, undo bool) (Todo, error) {
+	fields := todoFields(&current)
+
+	target := current
+	target.Tags = nil
+	pointers := map[string]any{
+		"text":        &target.Text,
+		"priority_id": &target.PriorityID,
+		"list_id":     &target.ListID,
+		"due_at":      &target.DueAt,
+		"remind_at":   &target.RemindAt,
+		"recurrence":  &target.Recurrence,
+		"tags":        &target.Tags,
+	}
+
+	for field, change := range changes {
+		from, to := change.After, change.Before
+		if !undo {
+			from, to = change.Before, change.After
+		}
+
+		value, err := json.Marshal(fields[field])
+		if err != nil {
+			return target, err
+		}
+		if string(value) != string(from) {
+			return target, fmt.Errorf("%w: %s was changed", ErrUndoConflict, field)
+		}
+
+		pointer, ok := pointers[field]
+		if !ok {
+			return target, fmt.Errorf("unknown field %q in the history", field)
+		}
+		if err := json.Unmarshal(to, pointer); err != nil {
+			return target, err
+		}
+	}
+
+	return target, nil
+}
+
+// revertedPosition returns the position a move event took the todo from, or
+// to when redoing. It fails with ErrUndoConflict when the todo was moved
+// since, or its position rebalanced.
+func revertedPosition(current string, changes map[string]FieldChange, undo bool) (string, error) {
+	change := changes["position"]
+	from, to := change.After, change.Before
+	if !undo {
+		from, to = change.Before, change.After
+	}
+
+	var fromKey, toKey string
+	if err := json.Unmarshal(from, &fromKey); err != nil {
+		return "", err
+	}
+	if err := json.Unmarshal(to, &toKey); err != nil {
+		return "", err
+	}
+	if current != fromKey {
+		return "", fmt.Errorf("%w: position was changed", ErrUndoConflict)
+	}
+
+	return toKey, nil
+}
+
+// eventOfLiveTodo matches the todo_events rows whose todo wasn't purged
+const eventOfLiveTodo = "EXISTS (SELECT 1 FROM todos WHERE todos.id = todo_events.todo_id)"
+
+// Undo reverts the user's latest undoable change made within window.
+func (r *SQLTodoRepository) Undo(ctx context.Context, userID int, window time.Duration) (*TodoEvent, error) {
+	return r.undo(ctx, userID, window, true)
+}
+
+// Redo reapplies the change the user undid first among those undone since
+// their latest change, if that was within window.
+func (r *SQLTodoRepository) Redo(ctx context.Context, userID int, window time.Duration) (*TodoEvent, error) {
+	return r.undo(ctx, userID, window, false)
+}
+
+func (r *SQLTodoRepository) undo(ctx context.Context, userID int, window time.Duration, undo bool) (_ *TodoEvent, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	// The undo stack is the user's undoable events not undone yet, latest on
+	// top; the redo stack the undone ones after them, which a new change
+	// therefore empties. Purged todos' events are left out of both.
+	query := todoEventSelect + " WHERE user_id = ? AND action IN (?, ?, ?, ?) AND " + eventOfLiveTodo + " AND undone_at IS NULL ORDER BY id DESC LIMIT 1"
+	args := []any{userID, TodoCreated, TodoUpdated, TodoDeleted, TodoMoved}
+	nothing := ErrNothingToUndo
+	if !undo {
+		query = todoEventSelect + " WHERE user_id = ? AND action IN (?, ?, ?, ?) AND " + eventOfLiveTodo + ` AND undone_at IS NOT NULL
+            AND id > COALESCE((SELECT MAX(id) FROM todo_events WHERE user_id = ? AND action IN (?, ?, ?, ?) AND ` + eventOfLiveTodo + ` AND undone_at IS NULL), 0)
+            ORDER BY id LIMIT 1`
+		args = append(args, args...)
+		nothing = ErrNothingToRedo
+	}
+
+	var event TodoEvent
+	err = scanTodoEvent(tx.QueryRowContext(ctx, r.rebind(query), args...), &event)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nothing
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := timeNow()
+	since := event.CreatedAt
+	if !undo {
+		since = *event.UndoneAt
+	}
+	if since.Before(now.Add(-window)) {
+		return nil, nothing
+	}
+
+	if err = r.revert(ctx, tx, event, undo); err != nil {
+		return nil, err
+	}
+
+	event.UndoneAt = nil
+	if undo {
+		event.UndoneAt = &now
+	}
+	if _, err = tx.ExecContext(ctx, r.rebind("UPDATE todo_events SET undone_at = ? WHERE id = ?"), event.UndoneAt, event.ID); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// revert applies the inverse of event, or event itself again when redoing,
+// and records that in the todo's history.
+func (r *SQLTodoRepository) revert(ctx context.Context, tx *sql.Tx, event TodoEvent, undo bool) error {
+	action := TodoRedone
+	if undo {
+		action = TodoUndone
+	}
+
+	if event.Action == TodoUpdated {
+		current, err := r.liveTodo(ctx, tx, event.TodoID, event.UserID)
+		if errors.Is(err, ErrNotFound) {
+			return ErrUndoConflict
+		}
+		if err != nil {
+			return err
+		}
+
+		todos := []Todo{current}
+		if err = r.loadTags(ctx, tx, todos); err != nil {
+			return err
+		}
+
+		target, err := revertedTodo(todos[0], event.Changes, undo)
+		if err != nil {
+			return err
+		}
+		// A priority or list that has gone since can't be restored
+		if err = r.updateTodo(ctx, tx, &target, action); errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("%w: %v", ErrUndoConflict, err)
+		}
+		return err
+	}
+
+	if event.Action == TodoMoved {
+		current, err := r.liveTodo(ctx, tx, event.TodoID, event.UserID)
+		if errors.Is(err, ErrNotFound) {
+			return ErrUndoConflict
+		}
+		if err != nil {
+			return err
+		}
+
+		key, err := revertedPosition(current.Position, event.Changes, undo)
+		if err != nil {
+			return err
+		}
+		query := r.rebind("UPDATE todos SET position = ?, updated_at = ?, version = version + 1 WHERE id = ? AND user_id = ?")
+		if _, err = tx.ExecContext(ctx, query, key, timeNow(), event.TodoID, event.UserID); err != nil {
+			return err
+		}
+
+		change, err := fieldChange(current.Position, key)
+		if err != nil {
+			return err
+		}
+		return r.recordEvent(ctx, tx, &TodoEvent{TodoID: event.TodoID, UserID: event.UserID, Action: action, Changes: map[string]FieldChange{"position": change}})
+	}
+
+	deleted := trashedBy(event.Action, undo)
+	err := r.setDeleted(ctx, tx, event.TodoID, event.UserID, deleted)
+	if errors.Is(err, ErrNotFound) {
+		return ErrUndoConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	change, err := fieldChange(!deleted, deleted)
+	if err != nil {
+		return err
+	}
+
+	return r.recordEvent(ctx, tx, &TodoEvent{TodoID: event.TodoID, UserID: event.UserID, Action: action, Changes: map[string]FieldChange{"deleted": change}})
+}
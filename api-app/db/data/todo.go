@@ -2,102 +2,387 @@ package data
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 )
 
 type Todo struct {
-	ID         int       `json:"id,omitempty"`
-	UserID     int       `json:"user_id,omitempty"`
-	PriorityID int       `json:"priority_id,omitempty"`
-	Text       string    `json:"text,omitempty"`
-	CreatedAt  time.Time `json:"created_at,omitempty"`
-	UpdatedAt  time.Time `json:"updated_at,omitempty"`
-	Priority   Priority  `json:"priority,omitempty"`
+	ID         int `json:"id,omitempty"`
+	UserID     int `json:"user_id,omitempty"`
+	PriorityID int `json:"priority_id,omitempty"`
+	// ListID is the list the todo belongs to, 0 on insert means the Inbox
+	ListID      int        `json:"list_id,omitempty"`
+	Text        string     `json:"text,omitempty"`
+	Completed   bool       `json:"completed"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	// DueAt and RemindAt are stored in UTC; clients send them with an offset
+	DueAt           *time.Time `json:"due_at,omitempty"`
+	RemindAt        *time.Time `json:"remind_at,omitempty"`
+	ReminderFiredAt *time.Time `json:"reminder_fired_at,omitempty"`
+	// Recurrence is an RRULE (see package recurrence) for todos that repeat;
+	// completing one creates the next occurrence of its series, numbered by
+	// RecurrenceIndex from 0
+	Recurrence      string `json:"recurrence,omitempty"`
+	RecurrenceIndex int    `json:"recurrence_index,omitempty"`
+	// Tags are normalized by NormalizeTags; Update leaves them alone when nil
+	Tags []string `json:"tags"`
+	// Position orders the todos of its list; new ones, and ones moved from
+	// another list, go last and Move changes it
+	Position string `json:"position"`
+	// Checklist is loaded with the todo and changed through ChecklistRepository;
+	// Progress is the percentage of its items that are done
+	Checklist []ChecklistItem `json:"checklist"`
+	Progress  int             `json:"progress"`
+	// DeletedAt is set while the todo is in the trash
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Version goes up with every change, for optimistic concurrency control
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	Priority  Priority  `json:"priority,omitempty"`
 }
 
-func (t *Todo) Insert() error {
-	// Create a new context with a timeout to prevent long-running queries
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
-	defer cancel() // Ensure the context is canceled when the function exits
+// TodoRepository stores each user's todos.
+type TodoRepository interface {
+	Insert(ctx context.Context, todo *Todo) error
+	Update(ctx context.Context, todo *Todo) error
+	Get(ctx context.Context, ID, userID int) (*Todo, error)
+	GetAll(ctx context.Context, userID int, filter TodoFilter) ([]Todo, error)
+	Count(ctx context.Context, userID int, filter TodoFilter) (int, error)
+	Search(ctx context.Context, userID int, query string, limit int) ([]TodoSearchResult, error)
+	// SetCompleted returns the ID of the next occurrence it created when
+	// completing a recurring todo, 0 otherwise
+	SetCompleted(ctx context.Context, ID, userID int, completed bool) (int, error)
+	FireReminders(ctx context.Context, now time.Time) (int, error)
+	// Move places a todo after afterID and before beforeID, either of which
+	// may be 0
+	Move(ctx context.Context, ID, userID, afterID, beforeID int) error
+	// Delete moves a todo to the trash, which the other methods leave out.
+	// A non-zero version must match the todo's.
+	Delete(ctx context.Context, ID, userID, version int) error
+	Restore(ctx context.Context, ID, userID int) error
+	// Purge deletes a todo from the trash for good
+	Purge(ctx context.Context, ID, userID int) error
+	// EmptyTrash purges all of the user's deleted todos and returns how many
+	EmptyTrash(ctx context.Context, userID int) (int, error)
+	// PurgeDeleted purges every todo deleted before the given time, for all
+	// users, and returns how many
+	PurgeDeleted(ctx context.Context, before time.Time) (int, error)
+	// History returns the changes made to a todo, oldest first, which are
+	// kept after it is purged
+	History(ctx context.Context, ID, userID int) ([]TodoEvent, error)
+	// Undo reverts the user's latest create, update or delete made within
+	// window, and Redo reapplies what Undo reverted; both return the event
+	Undo(ctx context.Context, userID int, window time.Duration) (*TodoEvent, error)
+	Redo(ctx context.Context, userID int, window time.Duration) (*TodoEvent, error)
+	// Bulk applies ops in one transaction, all or nothing when atomic
+	Bulk(ctx context.Context, userID int, ops []BulkOperation, atomic bool) ([]BulkResult, error)
+}
+
+// todoColumns lists the todo and joined priority columns in the order scanTodo expects
+const todoColumns = `
+            t.id, t.user_id, t.priority_id, t.list_id, t.text, t.completed, t.completed_at,
+            t.due_at, t.remind_at, t.reminder_fired_at, t.recurrence, t.recurrence_index, t.position, t.deleted_at, t.version, t.created_at, t.updated_at,
+            p.id AS priority_id, p.user_id AS priority_user_id, p.name AS priority_name, p.badge AS priority_badge, p.rank AS priority_rank, p.created_at AS priority_created_at, p.updated_at AS priority_updated_at`
+
+// todoSelect loads todos joined with their priority
+const todoSelect = `
+        SELECT` + todoColumns + `
+        FROM todos t
+        LEFT JOIN priorities p ON t.priority_id = p.id`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanTodo scans a row produced by todoSelect into todo, including Priority.
+// Columns selected after todoColumns are scanned into extra.
+func scanTodo(row rowScanner, todo *Todo, extra ...any) error {
+	dest := []any{
+		&todo.ID,
+		&todo.UserID,
+		&todo.PriorityID,
+		&todo.ListID,
+		&todo.Text,
+		&todo.Completed,
+		&todo.CompletedAt,
+		&todo.DueAt,
+		&todo.RemindAt,
+		&todo.ReminderFiredAt,
+		&todo.Recurrence,
+		&todo.RecurrenceIndex,
+		&todo.Position,
+		&todo.DeletedAt,
+		&todo.Version,
+		&todo.CreatedAt,
+		&todo.UpdatedAt,
+		&todo.Priority.ID,
+		&todo.Priority.UserID,
+		&todo.Priority.Name,
+		&todo.Priority.Badge,
+		&todo.Priority.Rank,
+		&todo.Priority.CreatedAt,
+		&todo.Priority.UpdatedAt,
+	}
+
+	return row.Scan(append(dest, extra...)...)
+}
+
+// SQLTodoRepository is the database-backed TodoRepository.
+type SQLTodoRepository struct {
+	sqlStore
+}
 
-	query := "INSERT INTO todos(user_id, priority_id, text, created_at, updated_at) VALUES (?, ?, ?, ?, ?)"
-	stmt, err := db.PrepareContext(ctx, query)
+func (r *SQLTodoRepository) Insert(ctx context.Context, todo *Todo) (err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback() // No-op once committed
+
+	if err = r.createTodo(ctx, tx, todo); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
 
-	defer stmt.Close() // Ensure the result set is closed after function execution
+// createTodo checks the priority and list of a new todo, putting it in the
+// Inbox when it has no list, and inserts it.
+func (r *SQLTodoRepository) createTodo(ctx context.Context, tx dbtx, todo *Todo) (err error) {
+	if err = r.checkPriority(ctx, tx, todo.PriorityID, todo.UserID); err != nil {
+		return err
+	}
 
-	result, err := stmt.ExecContext(ctx, t.UserID, t.PriorityID, t.Text, time.Now(), time.Now())
+	if todo.ListID == 0 {
+		todo.ListID, err = r.inboxID(ctx, tx, todo.UserID)
+	} else {
+		err = r.checkList(ctx, tx, todo.ListID, todo.UserID)
+	}
 	if err != nil {
 		return err
 	}
 
-	_, err = result.LastInsertId() // Not interested yet since I reload in the frontend, but I might return the newly inserted record in the future
+	return r.insertTodo(ctx, tx, todo)
+}
 
-	return err
+// insertTodo adds todo, with its tags, after the other todos of its list, and
+// records its creation. The caller has checked its priority and list.
+func (r *SQLTodoRepository) insertTodo(ctx context.Context, tx dbtx, todo *Todo) (err error) {
+	if todo.Position, err = r.lastPosition(ctx, tx, todo.ListID); err != nil {
+		return err
+	}
+
+	// RETURNING works on both SQLite and Postgres, unlike LastInsertId
+	query := r.rebind(`
+        INSERT INTO todos(user_id, priority_id, list_id, text, due_at, remind_at, recurrence, recurrence_index, position, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        RETURNING id`)
+	now := timeNow()
+	err = tx.QueryRowContext(ctx, query, todo.UserID, todo.PriorityID, todo.ListID, todo.Text, utc(todo.DueAt), utc(todo.RemindAt),
+		todo.Recurrence, todo.RecurrenceIndex, todo.Position, now, now).Scan(&todo.ID)
+	if err != nil {
+		return err
+	}
+	todo.Version = 1
+
+	if err = r.indexTodos(ctx, tx, "id = ?", todo.ID); err != nil {
+		return err
+	}
+
+	todo.Tags = NormalizeTags(todo.Tags)
+	if err = r.setTags(ctx, tx, todo.ID, todo.UserID, todo.Tags); err != nil {
+		return err
+	}
+
+	changes, err := todoChanges(nil, todo)
+	if err != nil {
+		return err
+	}
+	return r.recordEvent(ctx, tx, &TodoEvent{TodoID: todo.ID, UserID: todo.UserID, Action: TodoCreated, Changes: changes})
 }
 
-func (t *Todo) Update() error {
-	// Create a new context with a timeout to prevent long-running queries
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
-	defer cancel() // Ensure the context is canceled when the function exits
+func (r *SQLTodoRepository) Update(ctx context.Context, todo *Todo) (err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
 
-	query := "UPDATE todos SET priority_id = ?, text = ?, updated_at = ? WHERE id = ?"
-	stmt, err := db.PrepareContext(ctx, query)
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback() // No-op once committed
 
-	defer stmt.Close() // Ensure the result set is closed after function execution
+	if err = r.updateTodo(ctx, tx, todo, TodoUpdated); err != nil {
+		return err
+	}
 
-	_, err = stmt.ExecContext(ctx, t.PriorityID, t.Text, time.Now(), t.ID)
+	return tx.Commit()
+}
 
-	return err
+// updateTodo saves the fields of todo, and its tags unless they're nil, and
+// records what changed in the history under action.
+func (r *SQLTodoRepository) updateTodo(ctx context.Context, tx dbtx, todo *Todo, action string) (err error) {
+	if err = r.checkPriority(ctx, tx, todo.PriorityID, todo.UserID); err != nil {
+		return err
+	}
+
+	// The todo as it was, for the history
+	existing, err := r.liveTodo(ctx, tx, todo.ID, todo.UserID)
+	if err != nil {
+		return err
+	}
+	if todo.Version != 0 && todo.Version != existing.Version {
+		return ErrVersionConflict
+	}
+
+	// A todo can stay in an archived list, but not be moved into one. It goes
+	// last in a new list.
+	todo.Position = existing.Position
+	if todo.ListID == 0 {
+		todo.ListID = existing.ListID
+	} else if todo.ListID != existing.ListID {
+		if err = r.checkList(ctx, tx, todo.ListID, todo.UserID); err != nil {
+			return err
+		}
+		if todo.Position, err = r.lastPosition(ctx, tx, todo.ListID); err != nil {
+			return err
+		}
+	}
+
+	// Moving remind_at re-arms the reminder so the scheduler fires it again,
+	// and a new recurrence rule starts a new series. The version check
+	// catches a concurrent change since existing was read.
+	query := r.rebind(`
+        UPDATE todos
+        SET priority_id = ?, list_id = ?, text = ?, due_at = ?, remind_at = ?,
+            reminder_fired_at = CASE WHEN remind_at = ? THEN reminder_fired_at ELSE NULL END,
+            recurrence = ?, recurrence_index = CASE WHEN recurrence = ? THEN recurrence_index ELSE 0 END,
+            position = ?, updated_at = ?, version = version + 1
+        WHERE id = ? AND user_id = ? AND deleted_at IS NULL AND version = ?`)
+
+	reindex := todo.Text != existing.Text
+	if reindex {
+		if err = r.unindexTodos(ctx, tx, "id = ?", todo.ID); err != nil {
+			return err
+		}
+	}
+
+	remindAt := utc(todo.RemindAt)
+	result, err := tx.ExecContext(ctx, query, todo.PriorityID, todo.ListID, todo.Text, utc(todo.DueAt), remindAt, remindAt,
+		todo.Recurrence, todo.Recurrence, todo.Position, timeNow(), todo.ID, todo.UserID, existing.Version)
+	if err != nil {
+		return err
+	}
+	if err = expectRows(result); errors.Is(err, ErrNotFound) {
+		return ErrVersionConflict
+	} else if err != nil {
+		return err
+	}
+	todo.Version = existing.Version + 1
+
+	if reindex {
+		if err = r.indexTodos(ctx, tx, "id = ?", todo.ID); err != nil {
+			return err
+		}
+	}
+
+	if todo.Tags != nil {
+		todos := []Todo{existing}
+		if err = r.loadTags(ctx, tx, todos); err != nil {
+			return err
+		}
+		existing.Tags = todos[0].Tags
+
+		todo.Tags = NormalizeTags(todo.Tags)
+		if err = r.setTags(ctx, tx, todo.ID, todo.UserID, todo.Tags); err != nil {
+			return err
+		}
+	}
+
+	changes, err := todoChanges(&existing, todo)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	return r.recordEvent(ctx, tx, &TodoEvent{TodoID: todo.ID, UserID: todo.UserID, Action: action, Changes: changes})
 }
 
-func (t *Todo) GetAll(userID int) ([]Todo, error) {
-	// Create a new context with a timeout to prevent long-running queries
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
-	defer cancel() // Ensure the context is canceled when the function exits
+func (r *SQLTodoRepository) Get(ctx context.Context, ID, userID int) (_ *Todo, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	query := r.rebind(todoSelect + " WHERE t.id = ? AND t.user_id = ? AND t.deleted_at IS NULL")
+
+	var todo Todo
+	err = scanTodo(r.db.QueryRowContext(ctx, query, ID, userID), &todo)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	todos := []Todo{todo}
+	if err = r.loadTags(ctx, r.db, todos); err != nil {
+		return nil, err
+	}
+	if err = r.loadChecklists(ctx, r.db, todos); err != nil {
+		return nil, err
+	}
+
+	return &todos[0], nil
+}
+
+func (r *SQLTodoRepository) GetAll(ctx context.Context, userID int, filter TodoFilter) (todos []Todo, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
 
 	// SQL query with LEFT JOIN on priority table to get complete priority info
-	query := `
-        SELECT 
-            t.id, t.user_id, t.priority_id, t.text, t.created_at, t.updated_at,
-            p.id AS priority_id, p.name AS priority_name, p.badge AS priority_badge, p.created_at AS priority_created_at, p.updated_at AS priority_updated_at
-        FROM todos t
-        LEFT JOIN priorities p ON t.priority_id = p.id
-        WHERE t.user_id = ?`
+	query := todoSelect + " WHERE t.user_id = ?"
+	args := []any{userID}
+
+	if where, whereArgs := filter.sqlWhere(r.postgres); where != "" {
+		query += " AND " + where
+		args = append(args, whereArgs...)
+	}
+
+	query += filter.sqlOrderBy()
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+	query = r.rebind(query)
 
 	// Execute the query
-	rows, err := db.QueryContext(ctx, query, userID)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close() // Ensure rows are closed when function exits
 
-	var todos []Todo
-
 	// Iterate over the query results
 	for rows.Next() {
 		var todo Todo
 
 		// Scan the current row into the Todo struct, including Priority
-		err := rows.Scan(
-			&todo.ID,
-			&todo.UserID,
-			&todo.PriorityID,
-			&todo.Text,
-			&todo.CreatedAt,
-			&todo.UpdatedAt,
-			&todo.Priority.ID,
-			&todo.Priority.Name,
-			&todo.Priority.Badge,
-			&todo.Priority.CreatedAt,
-			&todo.Priority.UpdatedAt,
-		)
+		err := scanTodo(rows, &todo)
 		if err != nil {
 			return nil, err // Return an error if scanning fails
 		}
@@ -110,41 +395,262 @@ func (t *Todo) GetAll(userID int) ([]Todo, error) {
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
+	rows.Close() // Free the connection before loading the tags and checklists
+
+	// One query for the tags of the whole page instead of one per todo
+	if err = r.loadTags(ctx, r.db, todos); err != nil {
+		return nil, err
+	}
+	if err = r.loadChecklists(ctx, r.db, todos); err != nil {
+		return nil, err
+	}
 
 	return todos, nil
 }
 
-func (t *Todo) Delete(ID, userID int) error {
-	// Create a new context with a timeout to prevent long-running queries
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
-	defer cancel() // Ensure the context is canceled when the function exits
+// Count returns how many todos match the filter, ignoring its cursor and limit.
+func (r *SQLTodoRepository) Count(ctx context.Context, userID int, filter TodoFilter) (count int, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	filter.After, filter.Limit = nil, 0
+
+	query := "SELECT COUNT(*) FROM todos t WHERE t.user_id = ?"
+	args := []any{userID}
+
+	if where, whereArgs := filter.sqlWhere(r.postgres); where != "" {
+		query += " AND " + where
+		args = append(args, whereArgs...)
+	}
+
+	err = r.db.QueryRowContext(ctx, r.rebind(query), args...).Scan(&count)
+
+	return count, err
+}
+
+// SetCompleted marks a todo as done, stamping completed_at, or reopens it.
+func (r *SQLTodoRepository) SetCompleted(ctx context.Context, ID, userID int, completed bool) (nextID int, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
 
-	// Define the query
-	query := "DELETE FROM todos WHERE id = ? AND user_id = ?"
-	// Prepare the statement
-	stmt, err := db.PrepareContext(ctx, query)
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	defer tx.Rollback() // No-op once committed
+
+	if nextID, err = r.setCompleted(ctx, tx, ID, userID, completed); err != nil {
+		return 0, err
 	}
-	defer stmt.Close() // Ensure the result set is closed after function execution
 
-	// Execute the statement
-	result, err := stmt.ExecContext(ctx, ID, userID)
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return nextID, nil
+}
+
+// setCompleted does the work of SetCompleted within tx.
+func (r *SQLTodoRepository) setCompleted(ctx context.Context, tx dbtx, ID, userID int, completed bool) (nextID int, err error) {
+	now := timeNow()
+	var completedAt *time.Time
+	if completed {
+		completedAt = &now
+	}
+
+	var wasCompleted bool
+	err = tx.QueryRowContext(ctx, r.rebind("SELECT completed FROM todos WHERE id = ? AND user_id = ? AND deleted_at IS NULL"), ID, userID).Scan(&wasCompleted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	// Completing a completed todo, or reopening an open one, changes nothing
+	if completed == wasCompleted {
+		return 0, nil
+	}
+
+	query := r.rebind(`
+        UPDATE todos
+        SET completed = ?, completed_at = ?, updated_at = ?, version = version + 1
+        WHERE id = ? AND user_id = ? AND deleted_at IS NULL`)
+
+	result, err := tx.ExecContext(ctx, query, completed, completedAt, now, ID, userID)
+	if err != nil {
+		return 0, err
+	}
+	if err = expectRows(result); err != nil {
+		return 0, err
+	}
+
+	action := TodoUncompleted
+	if completed {
+		action = TodoCompleted
+	}
+	change, err := fieldChange(wasCompleted, completed)
+	if err != nil {
+		return 0, err
+	}
+	event := &TodoEvent{TodoID: ID, UserID: userID, Action: action, Changes: map[string]FieldChange{"completed": change}}
+	if err = r.recordEvent(ctx, tx, event); err != nil {
+		return 0, err
+	}
+
+	if completed {
+		return r.continueSeries(ctx, tx, ID, userID)
+	}
+
+	return 0, nil
+}
+
+// continueSeries creates the next occurrence of a completed recurring todo
+// and returns its ID, or 0 when the todo doesn't recur or its series is over.
+// The rule moves to the new todo, so completing the old one again doesn't
+// create another.
+func (r *SQLTodoRepository) continueSeries(ctx context.Context, tx dbtx, ID, userID int) (int, error) {
+	var todo Todo
+	if err := scanTodo(tx.QueryRowContext(ctx, r.rebind(todoSelect+" WHERE t.id = ? AND t.user_id = ?"), ID, userID), &todo); err != nil {
+		return 0, err
+	}
+
+	next, ok, err := nextOccurrence(todo)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	todos := []Todo{todo}
+	if err = r.loadTags(ctx, tx, todos); err != nil {
+		return 0, err
+	}
+	next.Tags = todos[0].Tags
+
+	if _, err = tx.ExecContext(ctx, r.rebind("UPDATE todos SET recurrence = '' WHERE id = ?"), ID); err != nil {
+		return 0, err
+	}
+	if err = r.insertTodo(ctx, tx, &next); err != nil {
+		return 0, err
+	}
+
+	// The checklist starts over, with nothing done
+	_, err = tx.ExecContext(ctx, r.rebind(`
+        INSERT INTO checklist_items (todo_id, text, done, position, created_at, updated_at)
+        SELECT t.id, ci.text, FALSE, ci.position, t.created_at, t.created_at
+        FROM checklist_items ci JOIN todos t ON t.id = ?
+        WHERE ci.todo_id = ?`), next.ID, ID)
+	if err != nil {
+		return 0, err
+	}
+
+	return next.ID, nil
+}
+
+// FireReminders stamps reminder_fired_at on every open todo whose reminder is
+// due, and returns how many reminders fired. Firing isn't an edit, so the
+// version stays and writes conditional on it still apply.
+func (r *SQLTodoRepository) FireReminders(ctx context.Context, now time.Time) (fired int, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	now = now.UTC()
+	query := r.rebind(`
+        UPDATE todos
+        SET reminder_fired_at = ?
+        WHERE remind_at <= ? AND reminder_fired_at IS NULL AND completed = ? AND deleted_at IS NULL
+            AND NOT EXISTS (SELECT 1 FROM lists l WHERE l.id = todos.list_id AND l.archived_at IS NOT NULL)`)
+
+	result, err := r.db.ExecContext(ctx, query, now, now, false)
+	if err != nil {
+		return 0, err
 	}
 
-	// Check the number of affected rows
 	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(rowsAffected), nil
+}
+
+// Delete moves a todo to the trash, from which Restore brings it back and
+// Purge removes it for good. Unless version is 0, it fails with
+// ErrVersionConflict when the todo has changed since that version.
+func (r *SQLTodoRepository) Delete(ctx context.Context, ID, userID, version int) (err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback() // No-op once committed
 
-	// If no rows were affected, the deletion was not successful
-	if rowsAffected == 0 {
-		return fmt.Errorf("no rows deleted")
+	if err = r.deleteTodo(ctx, tx, ID, userID, version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// deleteTodo moves a todo to the trash and records it in the history.
+func (r *SQLTodoRepository) deleteTodo(ctx context.Context, tx dbtx, ID, userID, version int) error {
+	if version != 0 {
+		todo, err := r.liveTodo(ctx, tx, ID, userID)
+		if err != nil {
+			return err
+		}
+		if todo.Version != version {
+			return ErrVersionConflict
+		}
+	}
+
+	if err := r.setDeleted(ctx, tx, ID, userID, true); err != nil {
+		return err
+	}
+
+	return r.recordEvent(ctx, tx, &TodoEvent{TodoID: ID, UserID: userID, Action: TodoDeleted})
+}
+
+// liveTodo loads one of the user's todos that isn't in the trash, without
+// its tags and checklist.
+func (r *SQLTodoRepository) liveTodo(ctx context.Context, db dbtx, ID, userID int) (Todo, error) {
+	var todo Todo
+	err := scanTodo(db.QueryRowContext(ctx, r.rebind(todoSelect+" WHERE t.id = ? AND t.user_id = ? AND t.deleted_at IS NULL"), ID, userID), &todo)
+	if errors.Is(err, sql.ErrNoRows) {
+		return todo, ErrNotFound
+	}
+
+	return todo, err
+}
+
+// checkPriority makes sure a todo only gets a default priority or one of its
+// owner's own.
+func (r *SQLTodoRepository) checkPriority(ctx context.Context, db dbtx, priorityID, userID int) error {
+	var found int
+	err := db.QueryRowContext(ctx, r.rebind("SELECT 1 FROM priorities WHERE id = ? AND (user_id IS NULL OR user_id = ?)"), priorityID, userID).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("todos.priority_id: %w", ErrNotFound)
 	}
 
-	// If rows were affected, the deletion was successful
 	return err
 }
+
+// utc normalises an optional timestamp to UTC so stored values compare
+// correctly, SQLite compares them as text.
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	u := t.UTC()
+	return &u
+}
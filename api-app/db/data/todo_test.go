@@ -0,0 +1,628 @@
+package data_test
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"task-app/db/data"
+	"testing"
+	"time"
+)
+
+// actions returns the actions of a todo's history, oldest first.
+func actions(t *testing.T, models data.Models, todoID, userID int) []string {
+	t.Helper()
+
+	events, err := models.Todo.History(context.Background(), todoID, userID)
+	if err != nil {
+		t.Fatalf("History(%d) error = %v", todoID, err)
+	}
+
+	var actions []string
+	for _, event := range events {
+		actions = append(actions, event.Action)
+	}
+
+	return actions
+}
+
+func TestTodoRepositoryCRUD(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		ada, bob := newUser(t, models, "ada"), newUser(t, models, "bob")
+
+		todo := &data.Todo{UserID: ada, PriorityID: 1, Text: "Write tests"}
+		if err := models.Todo.Insert(ctx, todo); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+		if todo.ID == 0 || todo.ListID == 0 || todo.Version != 1 {
+			t.Fatalf("Insert() = %+v; want an ID, the Inbox and version 1", todo)
+		}
+		second := newTodo(t, models, ada, "Run them")
+		if todo.Position == "" || second.Position <= todo.Position {
+			t.Errorf("positions %q then %q, want the second todo after the first", todo.Position, second.Position)
+		}
+
+		got, err := models.Todo.Get(ctx, todo.ID, ada)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if got.Text != "Write tests" || got.Priority.ID != 1 {
+			t.Errorf("Get() = %+v; want the inserted todo", got)
+		}
+		_, err = models.Todo.Get(ctx, todo.ID, bob)
+		wantErr(t, "Get() of another user's todo", err, data.ErrNotFound)
+
+		if err := models.Todo.Insert(ctx, &data.Todo{UserID: ada, PriorityID: 999, Text: "Nope"}); err == nil {
+			t.Error("Insert() with an unknown priority succeeded")
+		}
+
+		err = models.Todo.Update(ctx, &data.Todo{ID: todo.ID, UserID: bob, PriorityID: 1, Text: "Mine"})
+		wantErr(t, "Update() of another user's todo", err, data.ErrNotFound)
+		todo.PriorityID, todo.Text = 3, "Write more tests"
+		if err := models.Todo.Update(ctx, todo); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		if todo.Version != 2 {
+			t.Errorf("version after Update() = %d, want 2", todo.Version)
+		}
+
+		// A write based on an older version is refused
+		stale := *todo
+		stale.Version, stale.Text = 1, "Lost"
+		wantErr(t, "Update() of a stale version", models.Todo.Update(ctx, &stale), data.ErrVersionConflict)
+		wantErr(t, "Delete() of a stale version", models.Todo.Delete(ctx, todo.ID, ada, 1), data.ErrVersionConflict)
+
+		todos, err := models.Todo.GetAll(ctx, ada, data.TodoFilter{})
+		if err != nil || len(todos) != 2 {
+			t.Fatalf("GetAll() = %d todos, %v; want 2", len(todos), err)
+		}
+		if got := todos[0]; got.ID != todo.ID || got.Text != "Write more tests" || got.Priority.ID != 3 || got.Priority.Name == "" || got.Version != 2 {
+			t.Errorf("GetAll()[0] = %+v; want the updated todo with its priority", got)
+		}
+		if others, err := models.Todo.GetAll(ctx, bob, data.TodoFilter{}); err != nil || len(others) != 0 {
+			t.Fatalf("GetAll() of another user = %d todos, %v; want 0", len(others), err)
+		}
+		if count, err := models.Todo.Count(ctx, ada, data.TodoFilter{Text: "MORE"}); err != nil || count != 1 {
+			t.Errorf("Count(text MORE) = %d, %v; want 1", count, err)
+		}
+		if count, err := models.Todo.Count(ctx, bob, data.TodoFilter{}); err != nil || count != 0 {
+			t.Errorf("Count() of another user = %d, %v; want 0", count, err)
+		}
+
+		// Only their owner can delete todos
+		wantErr(t, "Delete() of another user's todo", models.Todo.Delete(ctx, todo.ID, bob, 0), data.ErrNotFound)
+		if err := models.Todo.Delete(ctx, todo.ID, ada, 2); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		_, err = models.Todo.Get(ctx, todo.ID, ada)
+		wantErr(t, "Get() of a deleted todo", err, data.ErrNotFound)
+
+		wantEvents := []string{data.TodoCreated, data.TodoUpdated, data.TodoDeleted}
+		if got := actions(t, models, todo.ID, ada); !slices.Equal(got, wantEvents) {
+			t.Errorf("History() = %v, want %v", got, wantEvents)
+		}
+		_, err = models.Todo.History(ctx, todo.ID, bob)
+		wantErr(t, "History() of another user's todo", err, data.ErrNotFound)
+	})
+}
+
+func TestTodoRepositoryCompletion(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		ada, bob := newUser(t, models, "ada"), newUser(t, models, "bob")
+		todo := newTodo(t, models, ada, "Finish")
+		newTodo(t, models, ada, "Start")
+
+		if _, err := models.Todo.SetCompleted(ctx, todo.ID, ada, true); err != nil {
+			t.Fatalf("SetCompleted(true) error = %v", err)
+		}
+		got, err := models.Todo.Get(ctx, todo.ID, ada)
+		if err != nil || !got.Completed || got.CompletedAt == nil {
+			t.Fatalf("Get() after SetCompleted(true) = %+v, %v; want it completed", got, err)
+		}
+		completedAt := *got.CompletedAt
+
+		// Completing it again keeps the original completion time
+		if _, err := models.Todo.SetCompleted(ctx, todo.ID, ada, true); err != nil {
+			t.Fatalf("second SetCompleted(true) error = %v", err)
+		}
+		if got, err := models.Todo.Get(ctx, todo.ID, ada); err != nil || got.CompletedAt == nil || !got.CompletedAt.Equal(completedAt) || got.Version != 2 {
+			t.Errorf("completed at %v, version %d after completing again; want %v, version 2", got.CompletedAt, got.Version, completedAt)
+		}
+
+		for _, completed := range []bool{true, false} {
+			todos, err := models.Todo.GetAll(ctx, ada, data.TodoFilter{Completed: &completed})
+			if err != nil || len(todos) != 1 || (todos[0].ID == todo.ID) != completed {
+				t.Errorf("GetAll(completed %v) = %+v, %v; want one todo", completed, todos, err)
+			}
+		}
+
+		if _, err := models.Todo.SetCompleted(ctx, todo.ID, ada, false); err != nil {
+			t.Fatalf("SetCompleted(false) error = %v", err)
+		}
+		if got, err := models.Todo.Get(ctx, todo.ID, ada); err != nil || got.Completed || got.CompletedAt != nil || got.Version != 3 {
+			t.Errorf("Get() after SetCompleted(false) = %+v, %v; want it open at version 3", got, err)
+		}
+
+		want := []string{data.TodoCreated, data.TodoCompleted, data.TodoUncompleted}
+		if got := actions(t, models, todo.ID, ada); !slices.Equal(got, want) {
+			t.Errorf("History() = %v, want %v", got, want)
+		}
+
+		_, err = models.Todo.SetCompleted(ctx, todo.ID, bob, true)
+		wantErr(t, "SetCompleted() of another user's todo", err, data.ErrNotFound)
+
+		// Completing a recurring todo creates its next occurrence, once
+		daily := &data.Todo{UserID: ada, PriorityID: 1, Text: "Stand-up", Recurrence: "FREQ=DAILY;COUNT=2"}
+		due := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+		daily.DueAt = &due
+		if err := models.Todo.Insert(ctx, daily); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+		nextID, err := models.Todo.SetCompleted(ctx, daily.ID, ada, true)
+		if err != nil || nextID == 0 {
+			t.Fatalf("SetCompleted(recurring) = %d, %v; want the next occurrence", nextID, err)
+		}
+		next, err := models.Todo.Get(ctx, nextID, ada)
+		if err != nil || next.DueAt == nil || !next.DueAt.Equal(due.AddDate(0, 0, 1)) || next.RecurrenceIndex != 1 || next.Recurrence != daily.Recurrence || next.Completed {
+			t.Fatalf("next occurrence = %+v, %v; want it open and due a day later", next, err)
+		}
+		if again, err := models.Todo.SetCompleted(ctx, daily.ID, ada, true); err != nil || again != 0 {
+			t.Errorf("SetCompleted(recurring) again = %d, %v; want no new occurrence", again, err)
+		}
+
+		// The rule's COUNT ends the series
+		if last, err := models.Todo.SetCompleted(ctx, nextID, ada, true); err != nil || last != 0 {
+			t.Errorf("SetCompleted(last occurrence) = %d, %v; want 0", last, err)
+		}
+	})
+}
+
+func TestTodoRepositoryDueDates(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		ada := newUser(t, models, "ada")
+
+		now := time.Now().UTC().Truncate(time.Second)
+		tomorrow, nextWeek := now.AddDate(0, 0, 1), now.AddDate(0, 0, 7)
+		undated := newTodo(t, models, ada, "Someday")
+		late := &data.Todo{UserID: ada, PriorityID: 1, Text: "Next week", DueAt: &nextWeek}
+		soon := &data.Todo{UserID: ada, PriorityID: 1, Text: "Tomorrow", DueAt: &tomorrow}
+		for _, todo := range []*data.Todo{late, soon} {
+			if err := models.Todo.Insert(ctx, todo); err != nil {
+				t.Fatalf("Insert() error = %v", err)
+			}
+		}
+
+		// Sorting by due date puts the undated todos last
+		todos, err := models.Todo.GetAll(ctx, ada, data.TodoFilter{Sort: data.SortDueAt})
+		if err != nil || len(todos) != 3 {
+			t.Fatalf("GetAll(due_at) = %d todos, %v; want 3", len(todos), err)
+		}
+		for i, want := range []int{soon.ID, late.ID, undated.ID} {
+			if todos[i].ID != want {
+				t.Errorf("GetAll(due_at)[%d] = %d, want %d", i, todos[i].ID, want)
+			}
+		}
+		if !todos[0].DueAt.Equal(tomorrow) {
+			t.Errorf("due at %v, want %v", todos[0].DueAt, tomorrow)
+		}
+
+		from, before := now, now.AddDate(0, 0, 2)
+		todos, err = models.Todo.GetAll(ctx, ada, data.TodoFilter{DueFrom: &from, DueBefore: &before})
+		if err != nil || len(todos) != 1 || todos[0].ID != soon.ID {
+			t.Errorf("GetAll(due in two days) = %+v, %v; want only tomorrow's todo", todos, err)
+		}
+	})
+}
+
+func TestTodoRepositoryPagination(t *testing.T) {
+	// The pages of every backend, by case, to compare the SQL ones with the
+	// in-memory one, which runs first
+	memoryPages := map[string][][]string{}
+
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		ada := newUser(t, models, "ada")
+		priorities, err := models.Priority.GetAll(ctx, ada)
+		if err != nil || len(priorities) < 3 {
+			t.Fatalf("Priority.GetAll() = %+v, %v; want 3 defaults", priorities, err)
+		}
+
+		// Todos sharing their priority or due date tie on the sort key,
+		// leaving the ID to order them
+		day := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+		due := func(days int) *time.Time {
+			t := day.AddDate(0, 0, days)
+			return &t
+		}
+		for _, todo := range []data.Todo{
+			{Text: "a", PriorityID: priorities[0].ID, DueAt: due(2)},
+			{Text: "b", PriorityID: priorities[1].ID},
+			{Text: "c", PriorityID: priorities[0].ID, DueAt: due(1)},
+			{Text: "d", PriorityID: priorities[2].ID, DueAt: due(2)},
+			{Text: "e", PriorityID: priorities[1].ID},
+			{Text: "f", PriorityID: priorities[0].ID, DueAt: due(1)},
+			{Text: "g", PriorityID: priorities[2].ID, DueAt: due(3)},
+		} {
+			todo.UserID = ada
+			if err := models.Todo.Insert(ctx, &todo); err != nil {
+				t.Fatalf("Insert(%s) error = %v", todo.Text, err)
+			}
+		}
+
+		tests := []struct {
+			name   string
+			filter data.TodoFilter
+			want   string
+		}{
+			{"default", data.TodoFilter{}, "abcdefg"},
+			{"default descending", data.TodoFilter{Descending: true}, "gfedcba"},
+			{"created at", data.TodoFilter{Sort: data.SortCreatedAt}, "abcdefg"},
+			{"position descending", data.TodoFilter{Sort: data.SortPosition, Descending: true}, "gfedcba"},
+			{"priority", data.TodoFilter{Sort: data.SortPriority}, "acfbedg"},
+			{"priority descending", data.TodoFilter{Sort: data.SortPriority, Descending: true}, "gdebfca"},
+			{"due date", data.TodoFilter{Sort: data.SortDueAt}, "cfadgbe"},
+			{"due date descending", data.TodoFilter{Sort: data.SortDueAt, Descending: true}, "gdafceb"},
+		}
+		for _, tt := range tests {
+			// Pages of two, the cursor going through its encoded form like
+			// a client's would
+			var pages [][]string
+			var seen string
+			filter := tt.filter
+			filter.Limit = 2
+			for len(pages) <= 7 {
+				todos, err := models.Todo.GetAll(ctx, ada, filter)
+				if err != nil {
+					t.Fatalf("%s: GetAll() error = %v", tt.name, err)
+				}
+				if len(todos) == 0 {
+					break
+				}
+				var page []string
+				for _, todo := range todos {
+					page = append(page, todo.Text)
+					seen += todo.Text
+				}
+				pages = append(pages, page)
+
+				filter.After, err = filter.DecodeCursor(filter.CursorFor(todos[len(todos)-1]).Encode())
+				if err != nil {
+					t.Fatalf("%s: DecodeCursor() error = %v", tt.name, err)
+				}
+			}
+
+			if seen != tt.want {
+				t.Errorf("%s: pages = %v, want the todos in the order %s", tt.name, pages, tt.want)
+			}
+			if want, ok := memoryPages[tt.name]; !ok {
+				memoryPages[tt.name] = pages
+			} else if !reflect.DeepEqual(pages, want) {
+				t.Errorf("%s: pages = %v, want the in-memory pages %v", tt.name, pages, want)
+			}
+		}
+	})
+}
+
+func TestTodoRepositoryReminders(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		ada := newUser(t, models, "ada")
+
+		now := time.Now().UTC()
+		due, later := now.Add(-time.Minute), now.Add(time.Hour)
+		dueTodo := &data.Todo{UserID: ada, PriorityID: 1, Text: "Due", RemindAt: &due}
+		laterTodo := &data.Todo{UserID: ada, PriorityID: 1, Text: "Later", RemindAt: &later}
+		for _, todo := range []*data.Todo{dueTodo, laterTodo} {
+			if err := models.Todo.Insert(ctx, todo); err != nil {
+				t.Fatalf("Insert() error = %v", err)
+			}
+		}
+
+		if fired, err := models.Todo.FireReminders(ctx, now); err != nil || fired != 1 {
+			t.Fatalf("FireReminders() = %d, %v; want 1", fired, err)
+		}
+		// A reminder fires once
+		if fired, err := models.Todo.FireReminders(ctx, now); err != nil || fired != 0 {
+			t.Fatalf("second FireReminders() = %d, %v; want 0", fired, err)
+		}
+
+		got, err := models.Todo.Get(ctx, dueTodo.ID, ada)
+		if err != nil || got.ReminderFiredAt == nil {
+			t.Fatalf("todo after FireReminders() = %+v, %v; want it fired", got, err)
+		}
+
+		// Moving the reminder arms it again
+		got.RemindAt = &later
+		if err := models.Todo.Update(ctx, got); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		if got, err := models.Todo.Get(ctx, dueTodo.ID, ada); err != nil || got.ReminderFiredAt != nil {
+			t.Errorf("todo after moving its reminder = %+v, %v; want it armed", got, err)
+		}
+	})
+}
+
+func TestTodoRepositoryMove(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		ada, bob := newUser(t, models, "ada"), newUser(t, models, "bob")
+		first, second, third := newTodo(t, models, ada, "First"), newTodo(t, models, ada, "Second"), newTodo(t, models, ada, "Third")
+
+		order := func() []int {
+			t.Helper()
+			todos, err := models.Todo.GetAll(ctx, ada, data.TodoFilter{Sort: "position"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int
+			for _, todo := range todos {
+				ids = append(ids, todo.ID)
+			}
+			return ids
+		}
+
+		moves := []struct {
+			id, afterID, beforeID int
+			want                  []int
+		}{
+			{third.ID, 0, first.ID, []int{third.ID, first.ID, second.ID}},
+			{third.ID, first.ID, second.ID, []int{first.ID, third.ID, second.ID}},
+			{first.ID, second.ID, 0, []int{third.ID, second.ID, first.ID}},
+		}
+		for _, move := range moves {
+			if err := models.Todo.Move(ctx, move.id, ada, move.afterID, move.beforeID); err != nil {
+				t.Fatalf("Move(%d, after %d, before %d) error = %v", move.id, move.afterID, move.beforeID, err)
+			}
+			if got := order(); !slices.Equal(got, move.want) {
+				t.Fatalf("order after Move(%d, after %d, before %d) = %v, want %v", move.id, move.afterID, move.beforeID, got, move.want)
+			}
+		}
+
+		// Moving into the same gap over and over keeps finding room
+		for range 50 {
+			if err := models.Todo.Move(ctx, first.ID, ada, third.ID, second.ID); err != nil {
+				t.Fatalf("Move() between the same neighbours error = %v", err)
+			}
+			if err := models.Todo.Move(ctx, first.ID, ada, second.ID, 0); err != nil {
+				t.Fatalf("Move() to the end error = %v", err)
+			}
+		}
+		if got := order(); !slices.Equal(got, []int{third.ID, second.ID, first.ID}) {
+			t.Fatalf("order after the repeated moves = %v", got)
+		}
+
+		for _, move := range [][2]int{{first.ID, 0}, {0, first.ID}, {second.ID, third.ID}} {
+			err := models.Todo.Move(ctx, first.ID, ada, move[0], move[1])
+			wantErr(t, "Move() to an impossible place", err, data.ErrInvalidMove)
+		}
+		wantErr(t, "Move() of another user's todo", models.Todo.Move(ctx, first.ID, bob, 0, second.ID), data.ErrNotFound)
+	})
+}
+
+func TestTodoRepositoryListPositions(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		ada := newUser(t, models, "ada")
+		first, second := newTodo(t, models, ada, "First"), newTodo(t, models, ada, "Second")
+
+		work := &data.List{UserID: ada, Name: "Work"}
+		if err := models.List.Insert(ctx, work); err != nil {
+			t.Fatal(err)
+		}
+		report := &data.Todo{UserID: ada, PriorityID: 1, ListID: work.ID, Text: "Report"}
+		if err := models.Todo.Insert(ctx, report); err != nil {
+			t.Fatal(err)
+		}
+
+		// Each list has its own order, so a new list starts over
+		if report.Position != first.Position {
+			t.Errorf("first todo of a list at %q, want %q like the first of the Inbox", report.Position, first.Position)
+		}
+		for _, move := range [][2]int{{report.ID, 0}, {0, report.ID}, {first.ID, report.ID}} {
+			err := models.Todo.Move(ctx, second.ID, ada, move[0], move[1])
+			wantErr(t, "Move() next to a todo of another list", err, data.ErrInvalidMove)
+		}
+
+		// A todo moved to another list goes last there
+		report.ListID = first.ListID
+		if err := models.Todo.Update(ctx, report); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		todos, err := models.Todo.GetAll(ctx, ada, data.TodoFilter{ListID: first.ListID, Sort: data.SortPosition})
+		if err != nil || len(todos) != 3 || todos[0].ID != first.ID || todos[1].ID != second.ID || todos[2].ID != report.ID {
+			t.Fatalf("Inbox after Update() = %+v, %v; want the moved todo last", todos, err)
+		}
+		if report.Position != todos[2].Position {
+			t.Errorf("Update() left the todo at %q, want %q", report.Position, todos[2].Position)
+		}
+		if err := models.Todo.Move(ctx, report.ID, ada, 0, first.ID); err != nil {
+			t.Errorf("Move() within the new list error = %v", err)
+		}
+	})
+}
+
+func TestTodoRepositoryTrash(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		ada := newUser(t, models, "ada")
+		kept, purged, emptied := newTodo(t, models, ada, "Kept"), newTodo(t, models, ada, "Purged"), newTodo(t, models, ada, "Emptied")
+
+		for _, todo := range []*data.Todo{kept, purged, emptied} {
+			if err := models.Todo.Delete(ctx, todo.ID, ada, 0); err != nil {
+				t.Fatalf("Delete(%d) error = %v", todo.ID, err)
+			}
+		}
+		trash, err := models.Todo.GetAll(ctx, ada, data.TodoFilter{Trashed: true})
+		if err != nil || len(trash) != 3 {
+			t.Fatalf("GetAll(Trashed) = %d todos, %v; want 3", len(trash), err)
+		}
+
+		if err := models.Todo.Restore(ctx, kept.ID, ada); err != nil {
+			t.Fatalf("Restore() error = %v", err)
+		}
+		wantErr(t, "Restore() of a todo not in the trash", models.Todo.Restore(ctx, kept.ID, ada), data.ErrNotFound)
+		wantErr(t, "Purge() of a todo not in the trash", models.Todo.Purge(ctx, kept.ID, ada), data.ErrNotFound)
+
+		if err := models.Todo.Purge(ctx, purged.ID, ada); err != nil {
+			t.Fatalf("Purge() error = %v", err)
+		}
+		if n, err := models.Todo.EmptyTrash(ctx, ada); err != nil || n != 1 {
+			t.Fatalf("EmptyTrash() = %d, %v; want 1", n, err)
+		}
+
+		todos, err := models.Todo.GetAll(ctx, ada, data.TodoFilter{})
+		if err != nil || len(todos) != 1 || todos[0].ID != kept.ID {
+			t.Fatalf("GetAll() = %+v, %v; want only the restored todo", todos, err)
+		}
+
+		// Purged todos keep their history, which ends with the purge
+		want := []string{data.TodoCreated, data.TodoDeleted, data.TodoPurged}
+		for _, todo := range []*data.Todo{purged, emptied} {
+			if got := actions(t, models, todo.ID, ada); !slices.Equal(got, want) {
+				t.Errorf("History(%d) = %v, want %v", todo.ID, got, want)
+			}
+		}
+
+		// PurgeDeleted only takes what was deleted before the given time
+		old := newTodo(t, models, ada, "Old")
+		if err := models.Todo.Delete(ctx, old.ID, ada, 0); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if n, err := models.Todo.PurgeDeleted(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+			t.Fatalf("PurgeDeleted(an hour ago) = %d, %v; want 0", n, err)
+		}
+		if n, err := models.Todo.PurgeDeleted(ctx, time.Now().Add(time.Second)); err != nil || n != 1 {
+			t.Fatalf("PurgeDeleted(now) = %d, %v; want 1", n, err)
+		}
+
+		// Todos in the trash don't hold on to the end of the list
+		trashed := newTodo(t, models, ada, "Trashed")
+		if err := models.Todo.Delete(ctx, trashed.ID, ada, 0); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if next := newTodo(t, models, ada, "Next"); next.Position > trashed.Position {
+			t.Errorf("new todo at %q after one trashed at %q, want it placed as if the trash were empty", next.Position, trashed.Position)
+		}
+	})
+}
+
+func TestTodoRepositoryUndo(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		ada, bob := newUser(t, models, "ada"), newUser(t, models, "bob")
+		todo := newTodo(t, models, ada, "Draft")
+		newTodo(t, models, bob, "Not ada's")
+
+		text := func() string {
+			t.Helper()
+			got, err := models.Todo.Get(ctx, todo.ID, ada)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return got.Text
+		}
+
+		todo.Text = "Final"
+		if err := models.Todo.Update(ctx, todo); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+
+		event, err := models.Todo.Undo(ctx, ada, time.Minute)
+		if err != nil || event.Action != data.TodoUpdated || event.TodoID != todo.ID {
+			t.Fatalf("Undo() = %+v, %v; want the update undone", event, err)
+		}
+		if got := text(); got != "Draft" {
+			t.Fatalf("text after Undo() = %q, want Draft", got)
+		}
+		if _, err := models.Todo.Redo(ctx, ada, time.Minute); err != nil {
+			t.Fatalf("Redo() error = %v", err)
+		}
+		if got := text(); got != "Final" {
+			t.Fatalf("text after Redo() = %q, want Final", got)
+		}
+		_, err = models.Todo.Redo(ctx, ada, time.Minute)
+		wantErr(t, "Redo() with nothing undone", err, data.ErrNothingToRedo)
+
+		// Changes older than the window stay
+		_, err = models.Todo.Undo(ctx, ada, -time.Minute)
+		wantErr(t, "Undo() outside the window", err, data.ErrNothingToUndo)
+
+		// Undoing the update again, then the creation, which trashes the
+		// todo, leaves nothing to undo
+		for range 2 {
+			if _, err := models.Todo.Undo(ctx, ada, time.Minute); err != nil {
+				t.Fatalf("Undo() error = %v", err)
+			}
+		}
+		_, err = models.Todo.Undo(ctx, ada, time.Minute)
+		wantErr(t, "Undo() with nothing left", err, data.ErrNothingToUndo)
+		_, err = models.Todo.Get(ctx, todo.ID, ada)
+		wantErr(t, "Get() after undoing the creation", err, data.ErrNotFound)
+
+		// Another user's changes are theirs to undo
+		if event, err := models.Todo.Undo(ctx, bob, time.Minute); err != nil || event.Action != data.TodoCreated {
+			t.Errorf("Undo() of bob = %+v, %v; want bob's creation undone", event, err)
+		}
+	})
+}
+
+func TestTodoRepositoryMoveAndUndo(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		ada := newUser(t, models, "ada")
+		first, second := newTodo(t, models, ada, "First"), newTodo(t, models, ada, "Second")
+
+		order := func() []int {
+			t.Helper()
+			todos, err := models.Todo.GetAll(ctx, ada, data.TodoFilter{Sort: "position"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int
+			for _, todo := range todos {
+				ids = append(ids, todo.ID)
+			}
+			return ids
+		}
+
+		wantErr(t, "Move() next to itself", models.Todo.Move(ctx, first.ID, ada, first.ID, 0), data.ErrInvalidMove)
+		if err := models.Todo.Move(ctx, first.ID, ada, second.ID, 0); err != nil {
+			t.Fatalf("Move() error = %v", err)
+		}
+		if got := order(); !slices.Equal(got, []int{second.ID, first.ID}) {
+			t.Fatalf("order after Move() = %v", got)
+		}
+
+		event, err := models.Todo.Undo(ctx, ada, time.Minute)
+		if err != nil || event.Action != data.TodoMoved {
+			t.Fatalf("Undo() = %+v, %v; want the move undone", event, err)
+		}
+		if got := order(); !slices.Equal(got, []int{first.ID, second.ID}) {
+			t.Fatalf("order after Undo() = %v", got)
+		}
+		if _, err := models.Todo.Redo(ctx, ada, time.Minute); err != nil {
+			t.Fatalf("Redo() error = %v", err)
+		}
+		if got := order(); !slices.Equal(got, []int{second.ID, first.ID}) {
+			t.Fatalf("order after Redo() = %v", got)
+		}
+		_, err = models.Todo.Redo(ctx, ada, time.Minute)
+		wantErr(t, "Redo() with nothing undone", err, data.ErrNothingToRedo)
+
+		// Undoing the move again, then the creations, which trash the todos,
+		// leaves nothing to undo
+		for range 3 {
+			if _, err := models.Todo.Undo(ctx, ada, time.Minute); err != nil {
+				t.Fatalf("Undo() error = %v", err)
+			}
+		}
+		_, err = models.Todo.Undo(ctx, ada, time.Minute)
+		wantErr(t, "Undo() with nothing left", err, data.ErrNothingToUndo)
+		if got := order(); len(got) != 0 {
+			t.Errorf("todos after undoing their creation = %v, want none", got)
+		}
+	})
+}
@@ -19,30 +19,43 @@ type User struct {
 	UpdatedAt       time.Time `json:"updated_at,omitempty"`
 }
 
-func (u *User) GetAll() ([]User, error) {
-	// Create a new context with a timeout to prevent long-running queries
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
-	defer cancel() // Ensure the context is canceled when the function exits
+// UserRepository stores user accounts.
+type UserRepository interface {
+	GetAll(ctx context.Context) ([]User, error)
+	// Insert adds a user along with their Inbox
+	Insert(ctx context.Context, user User) (int, error)
+	EmailExists(ctx context.Context, email string) (bool, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	GetByID(ctx context.Context, ID int) (*User, error)
+}
+
+// SQLUserRepository is the database-backed UserRepository.
+type SQLUserRepository struct {
+	sqlStore
+}
+
+func (r *SQLUserRepository) GetAll(ctx context.Context) (users []User, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
 
 	// Define the SQL query to retrieve all users from the database
-	query := "SELECT * FROM users"
+	query := "SELECT id, name, email, password, created_at, updated_at FROM users"
 
 	// Execute the query using the context to ensure it respects the timeout
-	rows, err := db.QueryContext(ctx, query)
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err // Return an error if the query fails
 	}
 	defer rows.Close() // Ensure the result set is closed after function execution
 
-	// Slice to store the list of users retrieved from the database
-	var users []User
-
 	// Iterate over the query results
 	for rows.Next() {
 		var user User
 
 		// Scan the current row into the user struct fields
-		err := rows.Scan(&user.ID, &user.Email, &user.Name, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+		err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt)
 		if err != nil {
 			return nil, err // Return an error if scanning fails
 		}
@@ -60,75 +73,110 @@ func (u *User) GetAll() ([]User, error) {
 	return users, nil
 }
 
-func (u *User) Insert(user User) (int, error) {
-	// Create a new context with a timeout to prevent long-running queries
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
-	defer cancel() // Ensure the context is canceled when the function exits
-
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), 14)
+func (r *SQLUserRepository) Insert(ctx context.Context, user User) (userID int, err error) {
+	// Hash before starting the clock, bcrypt alone takes a good part of dbTimeout
+	hashedPassword, err := hashPassword(user.Password)
 	if err != nil {
 		return 0, err
 	}
 
-	query := "INSERT INTO users(name, email, password, created_at, updated_at) VALUES (?, ?, ?, ?, ?)"
-	stmt, err := db.PrepareContext(ctx, query)
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
 	}
+	defer tx.Rollback() // No-op once committed
 
-	defer stmt.Close() // Ensure the result set is closed after function execution
-
-	result, err := stmt.Exec(user.Name, user.Email, hashedPassword, time.Now(), time.Now())
+	// RETURNING works on both SQLite and Postgres, unlike LastInsertId
+	query := r.rebind("INSERT INTO users(name, email, password, created_at, updated_at) VALUES (?, ?, ?, ?, ?) RETURNING id")
+	err = tx.QueryRowContext(ctx, query, user.Name, user.Email, hashedPassword, timeNow(), timeNow()).Scan(&userID)
 	if err != nil {
-		return 0, nil
+		return 0, err
 	}
 
-	userID, err := result.LastInsertId()
-	u.ID = int(userID)
+	// Every user starts with an Inbox, so no user is left without one
+	if _, err = r.inboxID(ctx, tx, userID); err != nil {
+		return 0, err
+	}
 
-	return int(userID), err
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return userID, nil
 }
 
-func (u *User) EmailExists(email string) (bool, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
-    defer cancel() // Ensure the context is canceled when the function exits
-
-    // Query to check if the email exists
-    query := "SELECT email FROM users WHERE email = ? LIMIT 1"
-    row := db.QueryRowContext(ctx, query, email)
-
-    var retrievedEmail string
-    if err := row.Scan(&retrievedEmail); err != nil {
-        if err == sql.ErrNoRows {
-            // No rows found, so the email doesn't exist
-            return false, nil
-        }
-        // Return the error if there's an issue with the query or scanning
-        return false, err
-    }
-
-    // If retrievedEmail is not empty, the email exists
-    return retrievedEmail != "", nil
+func (r *SQLUserRepository) EmailExists(ctx context.Context, email string) (exists bool, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	// Query to check if the email exists
+	query := r.rebind("SELECT email FROM users WHERE email = ? LIMIT 1")
+	row := r.db.QueryRowContext(ctx, query, email)
+
+	var retrievedEmail string
+	if err = row.Scan(&retrievedEmail); err != nil {
+		if err == sql.ErrNoRows {
+			// No rows found, so the email doesn't exist
+			return false, nil
+		}
+		// Return the error if there's an issue with the query or scanning
+		return false, err
+	}
+
+	// If retrievedEmail is not empty, the email exists
+	return retrievedEmail != "", nil
 }
 
-func (u *User) GetByEmail(email string) (*User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
-    defer cancel() // Ensure the context is canceled when the function exits
+func (r *SQLUserRepository) GetByEmail(ctx context.Context, email string) (_ *User, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
 
 	// Query to get user by email
-    query := "SELECT id, name, email, password, created_at, updated_at FROM users WHERE email = ? LIMIT 1"
+	query := r.rebind("SELECT id, name, email, password, created_at, updated_at FROM users WHERE email = ? LIMIT 1")
 
 	var user User
-    row := db.QueryRowContext(ctx, query, email)
+	row := r.db.QueryRowContext(ctx, query, email)
 
-	err := row.Scan(
-		&user.ID, 
+	err = row.Scan(
+		&user.ID,
 		&user.Name,
 		&user.Email,
 		&user.Password,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *SQLUserRepository) GetByID(ctx context.Context, ID int) (_ *User, err error) {
+	// Layer the per-query timeout on top of the caller's context
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()              // Ensure the context is canceled when the function exits
+	defer contextErr(ctx, &err) // Report cancellation and timeouts distinctly
+
+	query := r.rebind("SELECT id, name, email, password, created_at, updated_at FROM users WHERE id = ?")
+
+	var user User
+	err = r.db.QueryRowContext(ctx, query, ID).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -149,3 +197,16 @@ func (u *User) PasswordMatches(password string) (bool, error) {
 
 	return true, nil
 }
+
+// PasswordCost is the bcrypt cost of stored passwords. Tests lower it to
+// bcrypt.MinCost, as hashing at full cost would make up most of their time.
+var PasswordCost = 14
+
+func hashPassword(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), PasswordCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hashedPassword), nil
+}
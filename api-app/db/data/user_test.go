@@ -0,0 +1,63 @@
+package data_test
+
+import (
+	"context"
+	"task-app/db/data"
+	"testing"
+)
+
+func TestUserRepository(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		id := newUser(t, models, "ada")
+
+		exists, err := models.User.EmailExists(ctx, "ada@example.com")
+		if err != nil || !exists {
+			t.Fatalf("EmailExists(taken) = %v, %v; want true", exists, err)
+		}
+		exists, err = models.User.EmailExists(ctx, "bob@example.com")
+		if err != nil || exists {
+			t.Fatalf("EmailExists(free) = %v, %v; want false", exists, err)
+		}
+
+		byEmail, err := models.User.GetByEmail(ctx, "ada@example.com")
+		if err != nil {
+			t.Fatalf("GetByEmail() error = %v", err)
+		}
+		byID, err := models.User.GetByID(ctx, id)
+		if err != nil {
+			t.Fatalf("GetByID() error = %v", err)
+		}
+		for _, user := range []*data.User{byEmail, byID} {
+			if user.ID != id || user.Name != "ada" || user.Email != "ada@example.com" {
+				t.Errorf("user = %+v, want ada with ID %d", user, id)
+			}
+		}
+
+		// Only a hash of the password is stored
+		if byID.Password == "password" {
+			t.Error("the password is stored in the clear")
+		}
+		for password, want := range map[string]bool{"password": true, "Password": false} {
+			if ok, err := byID.PasswordMatches(password); err != nil || ok != want {
+				t.Errorf("PasswordMatches(%q) = %v, %v; want %v", password, ok, err, want)
+			}
+		}
+
+		_, err = models.User.GetByEmail(ctx, "bob@example.com")
+		wantErr(t, "GetByEmail(unknown)", err, data.ErrNotFound)
+		_, err = models.User.GetByID(ctx, id+1)
+		wantErr(t, "GetByID(unknown)", err, data.ErrNotFound)
+
+		// Emails are unique
+		if _, err := models.User.Insert(ctx, data.User{Name: "ada2", Email: "ada@example.com", Password: "x"}); err == nil {
+			t.Error("Insert() with a taken email succeeded")
+		}
+
+		newUser(t, models, "bob")
+		users, err := models.User.GetAll(ctx)
+		if err != nil || len(users) != 2 {
+			t.Fatalf("GetAll() = %d users, %v; want 2", len(users), err)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+// Package dbtest provides the databases the tests of the db and data
+// packages run against. Each test gets an empty database of its own: a SQLite
+// file always, and a scratch schema of the Postgres database named by the
+// TEST_POSTGRES_DSN environment variable when it is set.
+package dbtest
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"task-app/db"
+	"testing"
+)
+
+// PostgresEnv names the environment variable holding the DSN of a Postgres
+// database the tests may create schemas in.
+const PostgresEnv = "TEST_POSTGRES_DSN"
+
+// Run runs test once per backend, as the subtests "sqlite" and "postgres",
+// each with an empty database. The Postgres one is skipped unless
+// TEST_POSTGRES_DSN is set.
+func Run(t *testing.T, test func(t *testing.T, conn *sql.DB, driver string)) {
+	t.Helper()
+
+	t.Run("sqlite", func(t *testing.T) {
+		test(t, OpenSQLite(t), db.DriverSQLite)
+	})
+	t.Run("postgres", func(t *testing.T) {
+		dsn := os.Getenv(PostgresEnv)
+		if dsn == "" {
+			t.Skipf("%s is not set", PostgresEnv)
+		}
+		test(t, OpenPostgres(t, dsn), db.DriverPostgres)
+	})
+}
+
+// OpenSQLite opens a new SQLite database in a temporary directory, removed
+// with it when the test ends.
+func OpenSQLite(t testing.TB) *sql.DB {
+	t.Helper()
+
+	driver, source := db.ParseDSN(filepath.Join(t.TempDir(), "test.db"))
+	conn, err := sql.Open(driver, source)
+	if err != nil {
+		t.Fatalf("opening SQLite: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// OpenPostgres creates a schema in the database at dsn and returns a pool
+// working in it. The schema is dropped when the test ends.
+func OpenPostgres(t testing.TB, dsn string) *sql.DB {
+	t.Helper()
+
+	admin, err := sql.Open(db.DriverPostgres, dsn)
+	if err != nil {
+		t.Fatalf("opening Postgres: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatal(err)
+	}
+	schema := "test_" + hex.EncodeToString(suffix)
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("creating schema %s: %v", schema, err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("dropping schema %s: %v", schema, err)
+		}
+	})
+
+	conn, err := sql.Open(db.DriverPostgres, withSearchPath(dsn, schema))
+	if err != nil {
+		t.Fatalf("opening Postgres: %v", err)
+	}
+	// Registered after the schema cleanup, so it runs before it
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// withSearchPath sets the search_path runtime parameter of dsn, either a URL
+// or a key/value connection string.
+func withSearchPath(dsn, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		if u, err := url.Parse(dsn); err == nil {
+			query := u.Query()
+			query.Set("search_path", schema)
+			u.RawQuery = query.Encode()
+			return u.String()
+		}
+	}
+
+	return dsn + " search_path=" + schema
+}
+
+// Migrate applies every migration to conn, failing the test otherwise.
+func Migrate(t testing.TB, conn *sql.DB, driver string) *db.Migrator {
+	t.Helper()
+
+	migrator, err := db.NewMigrator(conn, driver)
+	if err != nil {
+		t.Fatalf("loading migrations: %v", err)
+	}
+	if err := migrator.Up(); err != nil {
+		t.Fatalf("migrating up: %v", err)
+	}
+
+	return migrator
+}
@@ -3,93 +3,106 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
+	_ "github.com/jackc/pgx/v5/stdlib"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// Driver names registered with database/sql.
+const (
+	DriverSQLite   = "sqlite3"
+	DriverPostgres = "pgx"
+)
+
+// DefaultDSN is used when no DSN is configured.
+const DefaultDSN = "api.db"
+
 var DB *sql.DB
 
-func InitDB() {
+// Driver is the database/sql driver name selected by OpenDB.
+var Driver string
+
+// ParseDSN works out which driver a DSN belongs to and returns the data
+// source name in the form that driver expects. Postgres is recognised by a
+// postgres:// URL or a key/value connection string (host=... dbname=...);
+// anything else is treated as a SQLite file path.
+func ParseDSN(dsn string) (driver, source string) {
+	dsn = strings.TrimSpace(dsn)
+	if dsn == "" {
+		dsn = DefaultDSN
+	}
+
+	if isPostgresDSN(dsn) {
+		return DriverPostgres, dsn
+	}
+
+	source = strings.TrimPrefix(dsn, "sqlite://")
+	source = strings.TrimPrefix(source, "sqlite3://")
+	if !strings.Contains(source, "_foreign_keys=") && !strings.Contains(source, "_fk=") {
+		separator := "?"
+		if strings.Contains(source, "?") {
+			separator = "&"
+		}
+		source += separator + "_foreign_keys=1"
+	}
+
+	return DriverSQLite, source
+}
+
+func isPostgresDSN(dsn string) bool {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return true
+	}
+
+	// Key/value connection strings, e.g. "host=localhost port=5432 dbname=vueapi"
+	for _, field := range strings.Fields(dsn) {
+		key, _, ok := strings.Cut(field, "=")
+		if ok && (key == "host" || key == "dbname" || key == "user") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// OpenDB opens the connection pool for dsn without touching the schema.
+func OpenDB(dsn string) {
+	driver, source := ParseDSN(dsn)
+
 	var err error
-	DB, err = sql.Open("sqlite3", "api.db?_foreign_keys=1")
+	DB, err = sql.Open(driver, source)
 	if err != nil {
 		panic("Could not connect to the database")
 	}
+	Driver = driver
 
 	DB.SetMaxOpenConns(100)
 	DB.SetMaxIdleConns(50)
 	DB.SetConnMaxLifetime(time.Minute * 5)
-
-	createTable()
 }
 
-func createTable() {
-	createUsersTable := `
-	CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		email TEXT NOT NULL UNIQUE,
-		password TEXT NOT NULL,
-		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
-	)`
-
-	_, err := DB.Exec(createUsersTable)
-	if err != nil {
-		fmt.Println(err)
-		panic("Could not create users table.")
-	}
-
-	createPriorityTable := `
-	CREATE TABLE IF NOT EXISTS priorities (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL UNIQUE,
-		badge TEXT NOT NULL,
-		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
-	)`
+// InitDB opens the connection pool for dsn, applies any pending migrations
+// and rebuilds the search index.
+func InitDB(dsn string) {
+	OpenDB(dsn)
 
-	_, err = DB.Exec(createPriorityTable)
+	migrator, err := NewMigrator(DB, Driver)
 	if err != nil {
 		fmt.Println(err)
-		panic("Could not create priorities table.")
+		panic("Could not load database migrations.")
 	}
 
-	// Insert default priorities if they don’t exist
-	insertPriorities := `
-	INSERT INTO priorities (name, badge, created_at, updated_at)
-	SELECT 'Low', 'is-info', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
-	WHERE NOT EXISTS (SELECT 1 FROM priorities WHERE name = 'Low')
-	UNION ALL
-	SELECT 'Medium', 'is-warning', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
-	WHERE NOT EXISTS (SELECT 1 FROM priorities WHERE name = 'Medium')
-	UNION ALL
-	SELECT 'High', 'is-danger', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
-	WHERE NOT EXISTS (SELECT 1 FROM priorities WHERE name = 'High')
-	`
-
-	_, err = DB.Exec(insertPriorities)
+	err = migrator.Up()
 	if err != nil {
 		fmt.Println(err)
-		panic("Could not insert default priorities.")
+		panic("Could not apply database migrations.")
 	}
 
-	createTodoTable := `
-	CREATE TABLE IF NOT EXISTS todos (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		user_id INTEGER NOT NULL,
-		priority_id INTEGER NOT NULL,
-		text TEXT NOT NULL,
-		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
-		FOREIGN KEY (priority_id) REFERENCES priorities(id) ON DELETE SET NULL
-	)`
-
-	_, err = DB.Exec(createTodoTable)
+	err = migrator.RebuildSearchIndex()
 	if err != nil {
 		fmt.Println(err)
-		panic("Could not create todos table.")
+		panic("Could not rebuild the search index.")
 	}
 }
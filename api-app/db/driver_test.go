@@ -0,0 +1,32 @@
+package db_test
+
+import (
+	"task-app/db"
+	"testing"
+)
+
+func TestParseDSN(t *testing.T) {
+	tests := []struct {
+		dsn        string
+		wantDriver string
+		wantSource string
+	}{
+		{"", db.DriverSQLite, "api.db?_foreign_keys=1"},
+		{"  ", db.DriverSQLite, "api.db?_foreign_keys=1"},
+		{"data/todos.db", db.DriverSQLite, "data/todos.db?_foreign_keys=1"},
+		{"sqlite://todos.db", db.DriverSQLite, "todos.db?_foreign_keys=1"},
+		{"sqlite3://todos.db?cache=shared", db.DriverSQLite, "todos.db?cache=shared&_foreign_keys=1"},
+		{"todos.db?_foreign_keys=0", db.DriverSQLite, "todos.db?_foreign_keys=0"},
+		{"todos.db?_fk=1", db.DriverSQLite, "todos.db?_fk=1"},
+		{"postgres://user:pw@localhost/vueapi", db.DriverPostgres, "postgres://user:pw@localhost/vueapi"},
+		{"postgresql://localhost/vueapi", db.DriverPostgres, "postgresql://localhost/vueapi"},
+		{"host=localhost port=5432 dbname=vueapi", db.DriverPostgres, "host=localhost port=5432 dbname=vueapi"},
+	}
+
+	for _, tt := range tests {
+		driver, source := db.ParseDSN(tt.dsn)
+		if driver != tt.wantDriver || source != tt.wantSource {
+			t.Errorf("ParseDSN(%q) = %q, %q; want %q, %q", tt.dsn, driver, source, tt.wantDriver, tt.wantSource)
+		}
+	}
+}
@@ -0,0 +1,478 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+const migrationTimeout = time.Minute
+
+// disableForeignKeys, on a line of its own in a SQLite migration, runs it with
+// foreign key enforcement off as SQLite's table rebuild procedure requires.
+// The constraints are checked once the migration has run.
+const disableForeignKeys = "-- migrate:disable-foreign-keys"
+
+// requiresFTS5, on a line of its own in a SQLite migration, leaves it pending
+// while the SQLite library lacks FTS5, which go-sqlite3 only includes when
+// built with -tags sqlite_fts5. A later build with FTS5 applies it then.
+const requiresFTS5 = "-- migrate:requires-fts5"
+
+// fts5Begin and fts5End, on lines of their own in a SQLite migration, enclose
+// statements skipped while the SQLite library lacks FTS5, such as the
+// triggers that keep the search index in step with todos.
+const (
+	fts5Begin = "-- migrate:fts5-begin"
+	fts5End   = "-- migrate:fts5-end"
+)
+
+// Migration is a single numbered schema change with its up and down SQL.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus describes whether a migration has been applied and when.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies the embedded migrations to a database and records them
+// in the schema_migrations table.
+type Migrator struct {
+	db         *sql.DB
+	driver     string
+	migrations []Migration
+}
+
+// NewMigrator loads the embedded migrations written for driver.
+func NewMigrator(db *sql.DB, driver string) (*Migrator, error) {
+	var dir string
+	switch driver {
+	case DriverSQLite:
+		dir = "migrations/sqlite"
+	case DriverPostgres:
+		dir = "migrations/postgres"
+	default:
+		return nil, fmt.Errorf("no migrations for driver %q", driver)
+	}
+
+	migrations, err := loadMigrations(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, driver: driver, migrations: migrations}, nil
+}
+
+// loadMigrations reads files named <version>_<name>.up.sql and
+// <version>_<name>.down.sql from dir and returns them sorted by version.
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		fileName := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(fileName, ".sql") {
+			continue
+		}
+
+		base := strings.TrimSuffix(fileName, ".sql")
+		direction := path.Ext(base)
+		if direction != ".up" && direction != ".down" {
+			return nil, fmt.Errorf("migration %s: expected .up.sql or .down.sql suffix", fileName)
+		}
+		base = strings.TrimSuffix(base, direction)
+
+		versionPart, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: expected <version>_<name>", fileName)
+		}
+		version, err := strconv.Atoi(versionPart)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: invalid version %q", fileName, versionPart)
+		}
+
+		contents, err := fs.ReadFile(fsys, path.Join(dir, fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, exists := byVersion[version]
+		if !exists {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, migration.Name, name)
+		}
+
+		if direction == ".up" {
+			migration.Up = string(contents)
+		} else {
+			migration.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s is missing its up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Latest returns the highest known migration version.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up() error {
+	return m.To(m.Latest())
+}
+
+// Down reverts the most recently applied migration.
+func (m *Migrator) Down() error {
+	current, err := m.Current()
+	if err != nil {
+		return err
+	}
+	if current == 0 {
+		return nil
+	}
+
+	target := 0
+	for _, migration := range m.migrations {
+		if migration.Version < current {
+			target = migration.Version
+		}
+	}
+
+	return m.To(target)
+}
+
+// Current returns the highest applied migration version, or 0 if none.
+func (m *Migrator) Current() (int, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	current := 0
+	for version := range applied {
+		if version > current {
+			current = version
+		}
+	}
+
+	return current, nil
+}
+
+// To migrates up or down until exactly the migrations up to and including
+// version are applied. Each migration runs in its own transaction.
+func (m *Migrator) To(version int) error {
+	if version < 0 || version > m.Latest() {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+
+	// Revert newest first so later migrations never outlive their dependencies
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if migration.Version <= version {
+			break
+		}
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if err := m.apply(migration, false); err != nil {
+			return err
+		}
+	}
+
+	for _, migration := range m.migrations {
+		if migration.Version > version {
+			break
+		}
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		supported, err := m.supported(migration)
+		if err != nil {
+			return err
+		}
+		if !supported {
+			continue
+		}
+		if err := m.apply(migration, true); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Status reports every known migration along with whether it has been applied.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses = append(statuses, MigrationStatus{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+
+	return statuses, nil
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`
+
+	_, err := m.db.ExecContext(ctx, query)
+	return err
+}
+
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+	defer cancel()
+
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// apply runs a migration in one direction and updates the bookkeeping table
+// inside the same transaction, so a failed migration leaves no trace.
+func (m *Migrator) apply(migration Migration, up bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+	defer cancel()
+
+	statements := migration.Up
+	if !up {
+		statements = migration.Down
+		if strings.TrimSpace(statements) == "" {
+			return fmt.Errorf("migration %d_%s cannot be reverted: no down file", migration.Version, migration.Name)
+		}
+	}
+
+	if m.driver == DriverSQLite && hasDirective(statements, fts5Begin) {
+		fts5, err := m.hasFTS5()
+		if err != nil {
+			return err
+		}
+		if !fts5 {
+			statements = withoutFTS5(statements)
+		}
+	}
+
+	// The foreign_keys pragma is a no-op inside a transaction and applies per
+	// connection, so pin one connection for the whole migration
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	withoutForeignKeys := m.driver == DriverSQLite && hasDirective(statements, disableForeignKeys)
+	if withoutForeignKeys {
+		if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+			return err
+		}
+		defer conn.ExecContext(context.Background(), "PRAGMA foreign_keys = ON")
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, statements); err != nil {
+		return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	if withoutForeignKeys {
+		if err := checkForeignKeys(ctx, tx); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	if up {
+		query := "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"
+		if m.driver == DriverPostgres {
+			query = "INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)"
+		}
+		_, err = tx.ExecContext(ctx, query, migration.Version, migration.Name, time.Now().UTC())
+	} else {
+		query := "DELETE FROM schema_migrations WHERE version = ?"
+		if m.driver == DriverPostgres {
+			query = "DELETE FROM schema_migrations WHERE version = $1"
+		}
+		_, err = tx.ExecContext(ctx, query, migration.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// supported reports whether the database has the features migration needs.
+func (m *Migrator) supported(migration Migration) (bool, error) {
+	if m.driver != DriverSQLite || !hasDirective(migration.Up, requiresFTS5) {
+		return true, nil
+	}
+
+	return m.hasFTS5()
+}
+
+// RebuildSearchIndex rebuilds the SQLite search index from todos, when there
+// is one and the SQLite library can read it. The application keeps the index
+// in step with todos, but not while running without FTS5, so it is rebuilt
+// at startup.
+func (m *Migrator) RebuildSearchIndex() error {
+	if m.driver != DriverSQLite {
+		return nil
+	}
+
+	fts5, err := m.hasFTS5()
+	if err != nil || !fts5 {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+	defer cancel()
+
+	var exists bool
+	err = m.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'todos_fts')").Scan(&exists)
+	if err != nil || !exists {
+		return err
+	}
+
+	_, err = m.db.ExecContext(ctx, "INSERT INTO todos_fts (todos_fts) VALUES ('rebuild')")
+	return err
+}
+
+// hasFTS5 reports whether the SQLite library was built with FTS5.
+func (m *Migrator) hasFTS5() (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+	defer cancel()
+
+	var fts5 bool
+	err := m.db.QueryRowContext(ctx, "SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5)
+
+	return fts5, err
+}
+
+// withoutFTS5 drops the lines between fts5Begin and fts5End from statements.
+func withoutFTS5(statements string) string {
+	var kept []string
+	skipping := false
+	for _, line := range strings.Split(statements, "\n") {
+		switch strings.TrimSpace(line) {
+		case fts5Begin:
+			skipping = true
+		case fts5End:
+			skipping = false
+		default:
+			if !skipping {
+				kept = append(kept, line)
+			}
+		}
+	}
+
+	return strings.Join(kept, "\n")
+}
+
+// hasDirective reports whether one of the lines of statements is directive.
+func hasDirective(statements, directive string) bool {
+	for _, line := range strings.Split(statements, "\n") {
+		if strings.TrimSpace(line) == directive {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkForeignKeys fails if any row violates a foreign key, which SQLite
+// doesn't notice on its own while enforcement is off.
+func checkForeignKeys(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, "PRAGMA foreign_key_check")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		var table, parent string
+		var rowID sql.NullInt64
+		var fkID int
+		if err := rows.Scan(&table, &rowID, &parent, &fkID); err != nil {
+			return err
+		}
+		return fmt.Errorf("row %d of %s references a missing %s row", rowID.Int64, table, parent)
+	}
+
+	return rows.Err()
+}
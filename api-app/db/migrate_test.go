@@ -0,0 +1,199 @@
+package db_test
+
+import (
+	"database/sql"
+	"task-app/db"
+	"task-app/db/dbtest"
+	"testing"
+)
+
+// pendingAfterUp returns the migrations Up leaves pending on this database:
+// those needing an FTS5 the SQLite library lacks.
+func pendingAfterUp(t *testing.T, conn *sql.DB, driver string) map[int]bool {
+	t.Helper()
+
+	if driver != db.DriverSQLite {
+		return nil
+	}
+
+	var fts5 bool
+	if err := conn.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5); err != nil {
+		t.Fatal(err)
+	}
+	if fts5 {
+		return nil
+	}
+
+	return map[int]bool{7: true}
+}
+
+// checkVersion fails the test unless the database is at version, with the
+// migrations up to it applied but for those in pending.
+func checkVersion(t *testing.T, migrator *db.Migrator, version int, pending map[int]bool) {
+	t.Helper()
+
+	current, err := migrator.Current()
+	if err != nil {
+		t.Fatalf("Current() error = %v", err)
+	}
+	if current != version {
+		t.Fatalf("Current() = %d, want %d", current, version)
+	}
+
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if len(statuses) != migrator.Latest() {
+		t.Fatalf("Status() returned %d migrations, want %d", len(statuses), migrator.Latest())
+	}
+	for i, status := range statuses {
+		if status.Version != i+1 {
+			t.Fatalf("Status()[%d].Version = %d, want %d", i, status.Version, i+1)
+		}
+		if status.Name == "" {
+			t.Errorf("migration %d has no name", status.Version)
+		}
+
+		want := status.Version <= version && !pending[status.Version]
+		if status.Applied != want {
+			t.Errorf("migration %d_%s applied = %v, want %v", status.Version, status.Name, status.Applied, want)
+		}
+		if status.Applied == status.AppliedAt.IsZero() {
+			t.Errorf("migration %d_%s applied = %v but applied at %v", status.Version, status.Name, status.Applied, status.AppliedAt)
+		}
+	}
+}
+
+// tables returns the tables of the database but the migrator's own.
+func tables(t *testing.T, conn *sql.DB, driver string) []string {
+	t.Helper()
+
+	query := "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT IN ('schema_migrations', 'sqlite_sequence') ORDER BY name"
+	if driver == db.DriverPostgres {
+		query = "SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema() AND table_name <> 'schema_migrations' ORDER BY table_name"
+	}
+
+	rows, err := conn.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return names
+}
+
+func TestMigrator(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, conn *sql.DB, driver string) {
+		migrator, err := db.NewMigrator(conn, driver)
+		if err != nil {
+			t.Fatalf("NewMigrator() error = %v", err)
+		}
+		latest := migrator.Latest()
+		pending := pendingAfterUp(t, conn, driver)
+
+		checkVersion(t, migrator, 0, nil)
+
+		if err := migrator.Up(); err != nil {
+			t.Fatalf("Up() error = %v", err)
+		}
+		checkVersion(t, migrator, latest, pending)
+
+		// Nothing is left to apply
+		if err := migrator.Up(); err != nil {
+			t.Fatalf("second Up() error = %v", err)
+		}
+		checkVersion(t, migrator, latest, pending)
+
+		if err := migrator.Down(); err != nil {
+			t.Fatalf("Down() error = %v", err)
+		}
+		checkVersion(t, migrator, latest-1, pending)
+
+		middle := latest / 2
+		if err := migrator.To(middle); err != nil {
+			t.Fatalf("To(%d) error = %v", middle, err)
+		}
+		checkVersion(t, migrator, middle, pending)
+
+		// Every down migration runs, leaving nothing behind
+		if err := migrator.To(0); err != nil {
+			t.Fatalf("To(0) error = %v", err)
+		}
+		checkVersion(t, migrator, 0, nil)
+		if names := tables(t, conn, driver); len(names) > 0 {
+			t.Errorf("tables left after To(0): %v", names)
+		}
+		if err := migrator.Down(); err != nil {
+			t.Fatalf("Down() with nothing applied error = %v", err)
+		}
+
+		if err := migrator.To(latest); err != nil {
+			t.Fatalf("To(%d) error = %v", latest, err)
+		}
+		checkVersion(t, migrator, latest, pending)
+
+		for _, version := range []int{-1, latest + 1} {
+			if err := migrator.To(version); err == nil {
+				t.Errorf("To(%d) succeeded, want an error", version)
+			}
+		}
+	})
+}
+
+func TestMigratorKeepsData(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, conn *sql.DB, driver string) {
+		migrator, err := db.NewMigrator(conn, driver)
+		if err != nil {
+			t.Fatalf("NewMigrator() error = %v", err)
+		}
+		if err := migrator.To(4); err != nil {
+			t.Fatalf("To(4) error = %v", err)
+		}
+
+		// Rows written by the first schema survive the later migrations,
+		// table rebuilds included
+		statements := []string{
+			"INSERT INTO users (name, email, password) VALUES ('Ada', 'ada@example.com', 'x')",
+			"INSERT INTO todos (user_id, priority_id, text) VALUES (1, 2, 'Write tests')",
+		}
+		for _, statement := range statements {
+			if _, err := conn.Exec(statement); err != nil {
+				t.Fatalf("%s: %v", statement, err)
+			}
+		}
+
+		if err := migrator.Up(); err != nil {
+			t.Fatalf("Up() error = %v", err)
+		}
+
+		var text string
+		var priorityID int
+		err = conn.QueryRow("SELECT text, priority_id FROM todos WHERE id = 1").Scan(&text, &priorityID)
+		if err != nil {
+			t.Fatalf("reading the todo: %v", err)
+		}
+		if text != "Write tests" || priorityID != 2 {
+			t.Errorf("todo after migrating = %q, priority %d", text, priorityID)
+		}
+
+		// The todo was moved into the Inbox the lists migration created
+		var inbox bool
+		err = conn.QueryRow("SELECT l.is_inbox FROM todos t JOIN lists l ON l.id = t.list_id WHERE t.id = 1 AND l.user_id = 1").Scan(&inbox)
+		if err != nil || !inbox {
+			t.Errorf("todo list is the Inbox = %v, %v; want true", inbox, err)
+		}
+	})
+}
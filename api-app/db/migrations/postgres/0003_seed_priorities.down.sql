@@ -0,0 +1 @@
+DELETE FROM priorities WHERE name IN ('Low', 'Medium', 'High');
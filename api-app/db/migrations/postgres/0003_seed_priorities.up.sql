@@ -0,0 +1,7 @@
+-- Insert default priorities if they don't exist
+INSERT INTO priorities (name, badge, created_at, updated_at)
+VALUES
+	('Low', 'is-info', NOW(), NOW()),
+	('Medium', 'is-warning', NOW(), NOW()),
+	('High', 'is-danger', NOW(), NOW())
+ON CONFLICT (name) DO NOTHING;
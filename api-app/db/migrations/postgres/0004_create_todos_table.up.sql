@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS todos (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	priority_id BIGINT NOT NULL REFERENCES priorities(id) ON DELETE SET NULL,
+	text TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
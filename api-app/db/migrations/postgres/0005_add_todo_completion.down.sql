@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS todos_user_completed_idx;
+
+ALTER TABLE todos DROP COLUMN completed_at;
+ALTER TABLE todos DROP COLUMN completed;
@@ -0,0 +1,5 @@
+-- The 'simple' configuration doesn't stem, matching the unicode61 tokenizer used on SQLite
+ALTER TABLE todos ADD COLUMN search_vector TSVECTOR
+	GENERATED ALWAYS AS (to_tsvector('simple', text)) STORED;
+
+CREATE INDEX IF NOT EXISTS todos_search_idx ON todos USING GIN (search_vector);
@@ -0,0 +1,17 @@
+-- Custom priorities go away, their todos fall back to the first default one
+UPDATE todos
+SET priority_id = (SELECT id FROM priorities WHERE user_id IS NULL ORDER BY rank, id LIMIT 1)
+WHERE priority_id IN (SELECT id FROM priorities WHERE user_id IS NOT NULL);
+
+DELETE FROM priorities WHERE user_id IS NOT NULL;
+
+DROP INDEX IF EXISTS todos_priority_idx;
+ALTER TABLE todos DROP CONSTRAINT IF EXISTS todos_priority_id_fkey;
+ALTER TABLE todos ADD CONSTRAINT todos_priority_id_fkey FOREIGN KEY (priority_id) REFERENCES priorities(id) ON DELETE SET NULL;
+
+DROP INDEX IF EXISTS priorities_user_name_idx;
+DROP INDEX IF EXISTS priorities_default_name_idx;
+ALTER TABLE priorities ADD CONSTRAINT priorities_name_key UNIQUE (name);
+
+ALTER TABLE priorities DROP COLUMN rank;
+ALTER TABLE priorities DROP COLUMN user_id;
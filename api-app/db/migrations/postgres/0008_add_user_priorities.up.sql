@@ -0,0 +1,16 @@
+ALTER TABLE priorities ADD COLUMN user_id BIGINT REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE priorities ADD COLUMN rank INTEGER NOT NULL DEFAULT 0;
+
+-- Leave gaps between the defaults so custom priorities can slot in
+UPDATE priorities SET rank = id * 10;
+
+-- Default priorities (no owner) have unique names, and so do each user's own
+ALTER TABLE priorities DROP CONSTRAINT IF EXISTS priorities_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS priorities_default_name_idx ON priorities (name) WHERE user_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS priorities_user_name_idx ON priorities (user_id, name) WHERE user_id IS NOT NULL;
+
+-- Deleting a priority in use now fails instead of nulling a NOT NULL column;
+-- the application reassigns the todos first
+ALTER TABLE todos DROP CONSTRAINT IF EXISTS todos_priority_id_fkey;
+ALTER TABLE todos ADD CONSTRAINT todos_priority_id_fkey FOREIGN KEY (priority_id) REFERENCES priorities(id);
+CREATE INDEX IF NOT EXISTS todos_priority_idx ON todos (priority_id);
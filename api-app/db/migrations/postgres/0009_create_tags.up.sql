@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS tags (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	UNIQUE (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS todo_tags (
+	todo_id BIGINT NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+	tag_id BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+	PRIMARY KEY (todo_id, tag_id)
+);
+
+-- The primary key covers lookups by todo, this one the tag filters and counts
+CREATE INDEX IF NOT EXISTS todo_tags_tag_idx ON todo_tags (tag_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS todos_list_idx;
+ALTER TABLE todos DROP COLUMN list_id;
+
+DROP TABLE IF EXISTS lists;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS lists (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	position INTEGER NOT NULL DEFAULT 0,
+	is_inbox BOOLEAN NOT NULL DEFAULT FALSE,
+	archived_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS lists_user_position_idx ON lists (user_id, position);
+-- Each user has exactly one Inbox, where todos go when no list is given
+CREATE UNIQUE INDEX IF NOT EXISTS lists_user_inbox_idx ON lists (user_id) WHERE is_inbox;
+
+INSERT INTO lists (user_id, name, position, is_inbox)
+SELECT id, 'Inbox', 0, TRUE FROM users;
+
+ALTER TABLE todos ADD COLUMN list_id BIGINT REFERENCES lists(id) ON DELETE CASCADE;
+
+UPDATE todos SET list_id = (SELECT l.id FROM lists l WHERE l.user_id = todos.user_id AND l.is_inbox);
+
+ALTER TABLE todos ALTER COLUMN list_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS todos_list_idx ON todos (list_id);
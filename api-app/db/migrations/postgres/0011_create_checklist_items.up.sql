@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS checklist_items (
+	id BIGSERIAL PRIMARY KEY,
+	todo_id BIGINT NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+	text TEXT NOT NULL,
+	done BOOLEAN NOT NULL DEFAULT FALSE,
+	position INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS checklist_items_todo_position_idx ON checklist_items (todo_id, position);
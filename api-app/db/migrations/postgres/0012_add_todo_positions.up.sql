@@ -0,0 +1,11 @@
+-- Fractional keys ordering each user's todos; see positionBetween. They are
+-- compared byte by byte, hence the "C" collation.
+ALTER TABLE todos ADD COLUMN position TEXT COLLATE "C" NOT NULL DEFAULT '';
+
+-- Existing todos keep their creation order. The keys are fixed width, and
+-- the trailing "V" keeps them from ending in the lowest digit.
+UPDATE todos SET position = lpad(id::text, 12, '0') || 'V';
+
+ALTER TABLE todos ALTER COLUMN position DROP DEFAULT;
+
+CREATE INDEX IF NOT EXISTS todos_user_position_idx ON todos (user_id, position);
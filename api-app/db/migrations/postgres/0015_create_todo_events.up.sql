@@ -0,0 +1,12 @@
+-- Append-only history of todo changes. changes holds a JSON object mapping
+-- each changed field to its before and after values.
+CREATE TABLE IF NOT EXISTS todo_events (
+	id BIGSERIAL PRIMARY KEY,
+	todo_id BIGINT NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+	user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	action TEXT NOT NULL,
+	changes TEXT NOT NULL DEFAULT '{}',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS todo_events_todo_idx ON todo_events (todo_id, id);
@@ -0,0 +1,2 @@
+-- Bumped by every change to a todo, for optimistic concurrency control
+ALTER TABLE todos ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
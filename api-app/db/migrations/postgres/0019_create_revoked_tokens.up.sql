@@ -0,0 +1,9 @@
+-- IDs (jti) of access tokens revoked before their expiry, such as on
+-- logout. Entries are pruned once the token would have expired anyway.
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+	jti TEXT PRIMARY KEY,
+	expires_at TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);
@@ -0,0 +1,24 @@
+-- One session per login, listed to the user so they can sign out devices.
+-- expires_at follows the session's latest refresh token.
+CREATE TABLE IF NOT EXISTS sessions (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	user_agent TEXT NOT NULL DEFAULT '',
+	ip TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	expires_at TIMESTAMPTZ NOT NULL,
+	revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_idx ON sessions (user_id);
+
+-- Refresh tokens now belong to a session, which replaces their family.
+-- Those issued before sessions existed have none, so they are dropped:
+-- their users log in again.
+DELETE FROM refresh_tokens;
+DROP INDEX IF EXISTS refresh_tokens_family_idx;
+ALTER TABLE refresh_tokens DROP COLUMN family;
+ALTER TABLE refresh_tokens ADD COLUMN session_id BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_session_idx ON refresh_tokens (session_id);
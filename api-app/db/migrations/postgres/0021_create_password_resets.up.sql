@@ -0,0 +1,13 @@
+-- Single-use password reset tokens, stored as SHA-256 hashes. A user has at
+-- most one pending at a time.
+CREATE TABLE IF NOT EXISTS password_resets (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	token_hash TEXT NOT NULL UNIQUE,
+	expires_at TIMESTAMPTZ NOT NULL,
+	used_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS password_resets_user_idx ON password_resets (user_id);
+CREATE INDEX IF NOT EXISTS password_resets_expires_at_idx ON password_resets (expires_at);
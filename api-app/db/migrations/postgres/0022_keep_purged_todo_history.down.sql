@@ -0,0 +1,4 @@
+-- The history of purged todos can't reference them, so it goes
+DELETE FROM todo_events WHERE todo_id NOT IN (SELECT id FROM todos);
+
+ALTER TABLE todo_events ADD CONSTRAINT todo_events_todo_id_fkey FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE;
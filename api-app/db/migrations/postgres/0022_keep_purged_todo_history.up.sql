@@ -0,0 +1,2 @@
+-- The history of a purged todo outlives it, keeping todo_id as plain data
+ALTER TABLE todo_events DROP CONSTRAINT IF EXISTS todo_events_todo_id_fkey;
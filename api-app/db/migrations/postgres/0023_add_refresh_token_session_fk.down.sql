@@ -0,0 +1,2 @@
+ALTER TABLE refresh_tokens DROP CONSTRAINT IF EXISTS refresh_tokens_session_id_fkey;
+ALTER TABLE refresh_tokens ALTER COLUMN session_id SET DEFAULT 0;
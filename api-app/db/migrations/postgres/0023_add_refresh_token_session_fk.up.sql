@@ -0,0 +1,6 @@
+-- Refresh tokens go with their session. Those of sessions that are gone are
+-- dropped first.
+DELETE FROM refresh_tokens WHERE session_id NOT IN (SELECT id FROM sessions);
+
+ALTER TABLE refresh_tokens ALTER COLUMN session_id DROP DEFAULT;
+ALTER TABLE refresh_tokens ADD CONSTRAINT refresh_tokens_session_id_fkey FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE;
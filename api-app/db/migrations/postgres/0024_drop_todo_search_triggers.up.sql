@@ -0,0 +1,3 @@
+-- search_vector is a generated column, so Postgres never had triggers to
+-- drop. This keeps the versions in step with SQLite.
+SELECT 1;
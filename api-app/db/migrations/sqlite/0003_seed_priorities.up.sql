@@ -0,0 +1,10 @@
+-- Insert default priorities if they don't exist
+INSERT INTO priorities (name, badge, created_at, updated_at)
+SELECT 'Low', 'is-info', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+WHERE NOT EXISTS (SELECT 1 FROM priorities WHERE name = 'Low')
+UNION ALL
+SELECT 'Medium', 'is-warning', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+WHERE NOT EXISTS (SELECT 1 FROM priorities WHERE name = 'Medium')
+UNION ALL
+SELECT 'High', 'is-danger', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+WHERE NOT EXISTS (SELECT 1 FROM priorities WHERE name = 'High');
@@ -0,0 +1,4 @@
+ALTER TABLE todos ADD COLUMN completed BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE todos ADD COLUMN completed_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS todos_user_completed_idx ON todos (user_id, completed);
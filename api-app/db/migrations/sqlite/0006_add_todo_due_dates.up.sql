@@ -0,0 +1,6 @@
+ALTER TABLE todos ADD COLUMN due_at DATETIME;
+ALTER TABLE todos ADD COLUMN remind_at DATETIME;
+ALTER TABLE todos ADD COLUMN reminder_fired_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS todos_user_due_at_idx ON todos (user_id, due_at);
+CREATE INDEX IF NOT EXISTS todos_pending_reminders_idx ON todos (remind_at) WHERE reminder_fired_at IS NULL;
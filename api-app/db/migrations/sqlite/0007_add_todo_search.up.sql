@@ -0,0 +1,15 @@
+-- Without FTS5 (go-sqlite3 built without -tags sqlite_fts5) this stays
+-- pending and search falls back to matching with LIKE
+-- migrate:requires-fts5
+CREATE VIRTUAL TABLE IF NOT EXISTS todos_fts USING fts5(
+	text,
+	content = 'todos',
+	content_rowid = 'id',
+	tokenize = 'unicode61 remove_diacritics 2'
+);
+
+-- The application keeps the external-content index in step with todos,
+-- see 0024_drop_todo_search_triggers
+
+-- Index the todos that already exist
+INSERT INTO todos_fts (todos_fts) VALUES ('rebuild');
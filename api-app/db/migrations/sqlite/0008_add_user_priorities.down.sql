@@ -0,0 +1,61 @@
+-- migrate:disable-foreign-keys
+-- Custom priorities go away, their todos fall back to the first default one
+
+UPDATE todos
+SET priority_id = (SELECT id FROM priorities WHERE user_id IS NULL ORDER BY rank, id LIMIT 1)
+WHERE priority_id IN (SELECT id FROM priorities WHERE user_id IS NOT NULL);
+
+CREATE TABLE priorities_old (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	badge TEXT NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO priorities_old (id, name, badge, created_at, updated_at)
+SELECT id, name, badge, created_at, updated_at FROM priorities WHERE user_id IS NULL;
+
+DROP TABLE priorities;
+ALTER TABLE priorities_old RENAME TO priorities;
+
+CREATE TABLE todos_old (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	priority_id INTEGER NOT NULL,
+	text TEXT NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	completed BOOLEAN NOT NULL DEFAULT 0,
+	completed_at DATETIME,
+	due_at DATETIME,
+	remind_at DATETIME,
+	reminder_fired_at DATETIME,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY (priority_id) REFERENCES priorities(id) ON DELETE SET NULL
+);
+
+INSERT INTO todos_old (id, user_id, priority_id, text, created_at, updated_at, completed, completed_at, due_at, remind_at, reminder_fired_at)
+SELECT id, user_id, priority_id, text, created_at, updated_at, completed, completed_at, due_at, remind_at, reminder_fired_at FROM todos;
+
+DROP TABLE todos;
+ALTER TABLE todos_old RENAME TO todos;
+
+CREATE INDEX IF NOT EXISTS todos_user_completed_idx ON todos (user_id, completed);
+CREATE INDEX IF NOT EXISTS todos_user_due_at_idx ON todos (user_id, due_at);
+CREATE INDEX IF NOT EXISTS todos_pending_reminders_idx ON todos (remind_at) WHERE reminder_fired_at IS NULL;
+
+-- migrate:fts5-begin
+CREATE TRIGGER IF NOT EXISTS todos_fts_insert AFTER INSERT ON todos BEGIN
+	INSERT INTO todos_fts (rowid, text) VALUES (new.id, new.text);
+END;
+
+CREATE TRIGGER IF NOT EXISTS todos_fts_delete AFTER DELETE ON todos BEGIN
+	INSERT INTO todos_fts (todos_fts, rowid, text) VALUES ('delete', old.id, old.text);
+END;
+
+CREATE TRIGGER IF NOT EXISTS todos_fts_update AFTER UPDATE OF text ON todos BEGIN
+	INSERT INTO todos_fts (todos_fts, rowid, text) VALUES ('delete', old.id, old.text);
+	INSERT INTO todos_fts (rowid, text) VALUES (new.id, new.text);
+END;
+-- migrate:fts5-end
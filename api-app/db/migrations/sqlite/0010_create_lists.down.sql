@@ -0,0 +1,48 @@
+-- migrate:disable-foreign-keys
+-- SQLite won't drop a column that has a foreign key, so todos is rebuilt
+-- without list_id.
+
+CREATE TABLE todos_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	priority_id INTEGER NOT NULL,
+	text TEXT NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	completed BOOLEAN NOT NULL DEFAULT 0,
+	completed_at DATETIME,
+	due_at DATETIME,
+	remind_at DATETIME,
+	reminder_fired_at DATETIME,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY (priority_id) REFERENCES priorities(id)
+);
+
+INSERT INTO todos_new (id, user_id, priority_id, text, created_at, updated_at, completed, completed_at, due_at, remind_at, reminder_fired_at)
+SELECT id, user_id, priority_id, text, created_at, updated_at, completed, completed_at, due_at, remind_at, reminder_fired_at FROM todos;
+
+DROP TABLE todos;
+ALTER TABLE todos_new RENAME TO todos;
+
+-- Dropping todos took its indexes and the search triggers with it
+CREATE INDEX IF NOT EXISTS todos_user_completed_idx ON todos (user_id, completed);
+CREATE INDEX IF NOT EXISTS todos_user_due_at_idx ON todos (user_id, due_at);
+CREATE INDEX IF NOT EXISTS todos_pending_reminders_idx ON todos (remind_at) WHERE reminder_fired_at IS NULL;
+CREATE INDEX IF NOT EXISTS todos_priority_idx ON todos (priority_id);
+
+-- migrate:fts5-begin
+CREATE TRIGGER IF NOT EXISTS todos_fts_insert AFTER INSERT ON todos BEGIN
+	INSERT INTO todos_fts (rowid, text) VALUES (new.id, new.text);
+END;
+
+CREATE TRIGGER IF NOT EXISTS todos_fts_delete AFTER DELETE ON todos BEGIN
+	INSERT INTO todos_fts (todos_fts, rowid, text) VALUES ('delete', old.id, old.text);
+END;
+
+CREATE TRIGGER IF NOT EXISTS todos_fts_update AFTER UPDATE OF text ON todos BEGIN
+	INSERT INTO todos_fts (todos_fts, rowid, text) VALUES ('delete', old.id, old.text);
+	INSERT INTO todos_fts (rowid, text) VALUES (new.id, new.text);
+END;
+-- migrate:fts5-end
+
+DROP TABLE IF EXISTS lists;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS lists (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	position INTEGER NOT NULL DEFAULT 0,
+	is_inbox BOOLEAN NOT NULL DEFAULT 0,
+	archived_at DATETIME,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS lists_user_position_idx ON lists (user_id, position);
+-- Each user has exactly one Inbox, where todos go when no list is given
+CREATE UNIQUE INDEX IF NOT EXISTS lists_user_inbox_idx ON lists (user_id) WHERE is_inbox;
+
+INSERT INTO lists (user_id, name, position, is_inbox, created_at, updated_at)
+SELECT id, 'Inbox', 0, 1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP FROM users;
+
+-- SQLite can't add a NOT NULL column without a default; the application
+-- always sets it
+ALTER TABLE todos ADD COLUMN list_id INTEGER REFERENCES lists(id) ON DELETE CASCADE;
+
+UPDATE todos SET list_id = (SELECT l.id FROM lists l WHERE l.user_id = todos.user_id AND l.is_inbox);
+
+CREATE INDEX IF NOT EXISTS todos_list_idx ON todos (list_id);
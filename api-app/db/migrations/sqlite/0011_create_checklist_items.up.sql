@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS checklist_items (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	todo_id INTEGER NOT NULL,
+	text TEXT NOT NULL,
+	done BOOLEAN NOT NULL DEFAULT FALSE,
+	position INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS checklist_items_todo_position_idx ON checklist_items (todo_id, position);
@@ -0,0 +1,8 @@
+-- Fractional keys ordering each user's todos; see positionBetween
+ALTER TABLE todos ADD COLUMN position TEXT NOT NULL DEFAULT '';
+
+-- Existing todos keep their creation order. The keys are fixed width, and
+-- the trailing "V" keeps them from ending in the lowest digit.
+UPDATE todos SET position = printf('%012dV', id);
+
+CREATE INDEX IF NOT EXISTS todos_user_position_idx ON todos (user_id, position);
@@ -0,0 +1,2 @@
+ALTER TABLE todos DROP COLUMN recurrence_index;
+ALTER TABLE todos DROP COLUMN recurrence;
@@ -0,0 +1,4 @@
+-- The rule of a recurring todo in canonical RRULE form, '' for one-off todos,
+-- and which occurrence of its series the todo is, counting from 0
+ALTER TABLE todos ADD COLUMN recurrence TEXT NOT NULL DEFAULT '';
+ALTER TABLE todos ADD COLUMN recurrence_index INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS todos_deleted_at_idx;
+
+ALTER TABLE todos DROP COLUMN deleted_at;
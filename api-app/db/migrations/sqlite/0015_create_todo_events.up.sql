@@ -0,0 +1,14 @@
+-- Append-only history of todo changes. changes holds a JSON object mapping
+-- each changed field to its before and after values.
+CREATE TABLE IF NOT EXISTS todo_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	todo_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	action TEXT NOT NULL,
+	changes TEXT NOT NULL DEFAULT '{}',
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS todo_events_todo_idx ON todo_events (todo_id, id);
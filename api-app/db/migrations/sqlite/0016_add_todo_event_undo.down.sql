@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS todo_events_user_idx;
+
+ALTER TABLE todo_events DROP COLUMN undone_at;
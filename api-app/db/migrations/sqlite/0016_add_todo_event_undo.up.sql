@@ -0,0 +1,4 @@
+-- Undone events stay in the history, marked, so they can be redone
+ALTER TABLE todo_events ADD COLUMN undone_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS todo_events_user_idx ON todo_events (user_id, id);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS refresh_tokens_session_idx;
+
+DELETE FROM refresh_tokens;
+ALTER TABLE refresh_tokens DROP COLUMN session_id;
+ALTER TABLE refresh_tokens ADD COLUMN family TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_idx ON refresh_tokens (family);
+
+DROP TABLE IF EXISTS sessions;
@@ -0,0 +1,25 @@
+-- One session per login, listed to the user so they can sign out devices.
+-- expires_at follows the session's latest refresh token.
+CREATE TABLE IF NOT EXISTS sessions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	user_agent TEXT NOT NULL DEFAULT '',
+	ip TEXT NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	last_seen_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	expires_at DATETIME NOT NULL,
+	revoked_at DATETIME,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_idx ON sessions (user_id);
+
+-- Refresh tokens now belong to a session, which replaces their family.
+-- Those issued before sessions existed have none, so they are dropped:
+-- their users log in again.
+DELETE FROM refresh_tokens;
+DROP INDEX IF EXISTS refresh_tokens_family_idx;
+ALTER TABLE refresh_tokens DROP COLUMN family;
+ALTER TABLE refresh_tokens ADD COLUMN session_id INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_session_idx ON refresh_tokens (session_id);
@@ -0,0 +1,23 @@
+-- migrate:disable-foreign-keys
+-- The history of purged todos can't reference them, so it goes
+CREATE TABLE todo_events_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	todo_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	action TEXT NOT NULL,
+	changes TEXT NOT NULL DEFAULT '{}',
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	undone_at DATETIME,
+	FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO todo_events_new (id, todo_id, user_id, action, changes, created_at, undone_at)
+SELECT id, todo_id, user_id, action, changes, created_at, undone_at FROM todo_events
+WHERE todo_id IN (SELECT id FROM todos);
+
+DROP TABLE todo_events;
+ALTER TABLE todo_events_new RENAME TO todo_events;
+
+CREATE INDEX IF NOT EXISTS todo_events_todo_idx ON todo_events (todo_id, id);
+CREATE INDEX IF NOT EXISTS todo_events_user_idx ON todo_events (user_id, id);
@@ -0,0 +1,26 @@
+-- migrate:disable-foreign-keys
+-- Refresh tokens go with their session. SQLite can't add a foreign key in
+-- place, so the table is rebuilt, leaving out the tokens of sessions that
+-- are gone.
+CREATE TABLE refresh_tokens_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	session_id INTEGER NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	expires_at DATETIME NOT NULL,
+	used_at DATETIME,
+	revoked_at DATETIME,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
+
+INSERT INTO refresh_tokens_new (id, user_id, session_id, token_hash, expires_at, used_at, revoked_at, created_at)
+SELECT id, user_id, session_id, token_hash, expires_at, used_at, revoked_at, created_at FROM refresh_tokens
+WHERE session_id IN (SELECT id FROM sessions);
+
+DROP TABLE refresh_tokens;
+ALTER TABLE refresh_tokens_new RENAME TO refresh_tokens;
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_session_idx ON refresh_tokens (session_id);
+CREATE INDEX IF NOT EXISTS refresh_tokens_expires_at_idx ON refresh_tokens (expires_at);
@@ -0,0 +1,14 @@
+-- migrate:fts5-begin
+CREATE TRIGGER IF NOT EXISTS todos_fts_insert AFTER INSERT ON todos BEGIN
+	INSERT INTO todos_fts (rowid, text) VALUES (new.id, new.text);
+END;
+
+CREATE TRIGGER IF NOT EXISTS todos_fts_delete AFTER DELETE ON todos BEGIN
+	INSERT INTO todos_fts (todos_fts, rowid, text) VALUES ('delete', old.id, old.text);
+END;
+
+CREATE TRIGGER IF NOT EXISTS todos_fts_update AFTER UPDATE OF text ON todos BEGIN
+	INSERT INTO todos_fts (todos_fts, rowid, text) VALUES ('delete', old.id, old.text);
+	INSERT INTO todos_fts (rowid, text) VALUES (new.id, new.text);
+END;
+-- migrate:fts5-end
@@ -0,0 +1,6 @@
+-- The search index is kept up to date by the application instead: a
+-- trigger on todos naming todos_fts fails every write to todos under a
+-- SQLite library without FTS5, even when the index is never searched.
+DROP TRIGGER IF EXISTS todos_fts_insert;
+DROP TRIGGER IF EXISTS todos_fts_delete;
+DROP TRIGGER IF EXISTS todos_fts_update;
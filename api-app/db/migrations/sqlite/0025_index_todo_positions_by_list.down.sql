@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS todos_list_position_idx;
+
+CREATE INDEX IF NOT EXISTS todos_user_position_idx ON todos (user_id, position);
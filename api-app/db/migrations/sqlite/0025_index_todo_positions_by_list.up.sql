@@ -0,0 +1,4 @@
+-- Positions order the todos of each list rather than all of a user's todos
+DROP INDEX IF EXISTS todos_user_position_idx;
+
+CREATE INDEX IF NOT EXISTS todos_list_position_idx ON todos (list_id, position);
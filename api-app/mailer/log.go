@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// LogMailer writes emails to a writer, such as the console or a file,
+// instead of sending them. Links in them can be followed from there during
+// development.
+type LogMailer struct {
+	from string
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewLogMailer returns a mailer writing the messages from the given sender
+// to w, one after the other.
+func NewLogMailer(w io.Writer, from string) *LogMailer {
+	return &LogMailer{from: from, w: w}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// The body is written as is, rather than encoded as it is for SMTP, so
+	// the links in it stay whole
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := fmt.Fprintf(m.w, "---------- mail ----------\nFrom: %s\nTo: %s\nSubject: %s\nDate: %s\n\n%s\n",
+		m.from, msg.To, msg.Subject, time.Now().Format(time.RFC1123Z), msg.Body)
+
+	return err
+}
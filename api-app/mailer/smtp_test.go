@@ -0,0 +1,292 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpServer is a fake SMTP server on a local port. It offers AUTH PLAIN but
+// not STARTTLS, and records the commands and messages it receives.
+type smtpServer struct {
+	addr string
+	// username and password are the credentials AUTH accepts
+	username string
+	password string
+	// rejectRcpt is a recipient RCPT TO refuses
+	rejectRcpt string
+
+	mu       sync.Mutex
+	commands []string
+	messages [][]byte
+}
+
+func newSMTPServer(t *testing.T, username, password string) *smtpServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &smtpServer{addr: ln.Addr().String(), username: username, password: password}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	c := textproto.NewConn(conn)
+	c.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, line)
+		s.mu.Unlock()
+
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			c.PrintfLine("250-localhost")
+			c.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			_, initial, _ := strings.Cut(arg, " ")
+			credentials, _ := base64.StdEncoding.DecodeString(initial)
+			if string(credentials) == "\x00"+s.username+"\x00"+s.password {
+				c.PrintfLine("235 2.7.0 Authentication successful")
+			} else {
+				c.PrintfLine("535 5.7.8 Authentication credentials invalid")
+			}
+		case "MAIL":
+			c.PrintfLine("250 OK")
+		case "RCPT":
+			if s.rejectRcpt != "" && strings.Contains(arg, s.rejectRcpt) {
+				c.PrintfLine("550 5.1.1 No such user")
+			} else {
+				c.PrintfLine("250 OK")
+			}
+		case "DATA":
+			c.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			message, err := c.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, message)
+			s.mu.Unlock()
+			c.PrintfLine("250 OK")
+		case "QUIT":
+			c.PrintfLine("221 Bye")
+			return
+		default:
+			c.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// verbs returns the verbs of the commands received so far.
+func (s *smtpServer) verbs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	verbs := make([]string, len(s.commands))
+	for i, command := range s.commands {
+		verbs[i], _, _ = strings.Cut(command, " ")
+	}
+
+	return verbs
+}
+
+func (s *smtpServer) received() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.messages
+}
+
+const testFrom = "Task App <no-reply@example.com>"
+
+func TestSMTPMailerSend(t *testing.T) {
+	server := newSMTPServer(t, "", "")
+	m := NewSMTPMailer(server.addr, "", "", testFrom)
+
+	// Long enough a line for quoted-printable to wrap
+	link := "https://tasks.example.com/reset-password?token=" + strings.Repeat("a1b2c3d4", 8)
+	msg := Message{To: "Ada Lovelace <ada@example.com>", Subject: "Réinitialiser", Body: "Hi Ada,\n\n" + link + "\n"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	// Unauthenticated, with bare envelope addresses
+	server.mu.Lock()
+	commands := strings.Join(server.commands, "\n")
+	server.mu.Unlock()
+	want := "EHLO localhost\nMAIL FROM:<no-reply@example.com>\nRCPT TO:<ada@example.com>\nDATA\nQUIT"
+	if commands != want {
+		t.Errorf("commands =\n%s\nwant\n%s", commands, want)
+	}
+
+	messages := server.received()
+	if len(messages) != 1 {
+		t.Fatalf("received %d messages, want 1", len(messages))
+	}
+	parsed, err := mail.ReadMessage(bytes.NewReader(messages[0]))
+	if err != nil {
+		t.Fatalf("reading the message: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != msg.Subject {
+		t.Errorf("Subject = %q, %v; want %q", subject, err, msg.Subject)
+	}
+	for header, want := range map[string]string{"From": testFrom, "To": msg.To, "Content-Transfer-Encoding": "quoted-printable"} {
+		if got := parsed.Header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+	if _, err := parsed.Header.Date(); err != nil {
+		t.Errorf("Date: %v", err)
+	}
+
+	// The link is wrapped on the wire but whole once decoded. The server's
+	// reader turned line endings into LF, including the one closing the body.
+	if !bytes.Contains(messages[0], []byte("=\n")) {
+		t.Errorf("message = %q, want a soft line break", messages[0])
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSuffix(string(body), "\n"); got != msg.Body {
+		t.Errorf("body = %q, want %q", got, msg.Body)
+	}
+}
+
+func TestSMTPMailerAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+		wantAuth bool
+		wantErr  bool
+	}{
+		{"no username", "", "", false, false},
+		{"right credentials", "mailer", "secret", true, false},
+		{"wrong password", "mailer", "guess", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newSMTPServer(t, "mailer", "secret")
+			m := NewSMTPMailer(server.addr, tt.username, tt.password, testFrom)
+
+			err := m.Send(context.Background(), Message{To: "ada@example.com", Subject: "Hi", Body: "Hello"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			verbs := server.verbs()
+			if authed := len(verbs) > 1 && verbs[1] == "AUTH"; authed != tt.wantAuth {
+				t.Errorf("commands = %v, want AUTH %v", verbs, tt.wantAuth)
+			}
+			if sent := len(server.received()) == 1; sent == tt.wantErr {
+				t.Errorf("received %d messages, want it sent %v", len(server.received()), !tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSMTPMailerErrors(t *testing.T) {
+	t.Run("rejected recipient", func(t *testing.T) {
+		server := newSMTPServer(t, "", "")
+		server.rejectRcpt = "nobody@example.com"
+
+		err := NewSMTPMailer(server.addr, "", "", testFrom).Send(context.Background(), Message{To: "nobody@example.com", Subject: "Hi", Body: "Hello"})
+		var smtpErr *textproto.Error
+		if !errors.As(err, &smtpErr) || smtpErr.Code != 550 {
+			t.Errorf("Send() error = %v, want a 550", err)
+		}
+		if len(server.received()) != 0 {
+			t.Error("the message was sent")
+		}
+	})
+
+	t.Run("header injection", func(t *testing.T) {
+		server := newSMTPServer(t, "", "")
+
+		err := NewSMTPMailer(server.addr, "", "", testFrom).Send(context.Background(), Message{To: "ada@example.com", Subject: "Hi\r\nBcc: eve@example.com", Body: "Hello"})
+		if err == nil {
+			t.Fatal("Send() error = nil, want one")
+		}
+		if verbs := server.verbs(); len(verbs) != 0 {
+			t.Errorf("commands = %v, want no connection", verbs)
+		}
+	})
+
+	t.Run("no server", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := ln.Addr().String()
+		ln.Close()
+
+		if err := NewSMTPMailer(addr, "", "", testFrom).Send(context.Background(), Message{To: "ada@example.com"}); err == nil {
+			t.Error("Send() error = nil, want one")
+		}
+	})
+
+	t.Run("unresponsive server", func(t *testing.T) {
+		// Accepts connections but never greets
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		err = NewSMTPMailer(ln.Addr().String(), "", "", testFrom).Send(ctx, Message{To: "ada@example.com"})
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("Send() error = %v, want the deadline exceeded", err)
+		}
+	})
+}
+
+func TestRenderRejectsLineBreaks(t *testing.T) {
+	for name, msg := range map[string]Message{
+		"to":      {To: "ada@example.com\nBcc: eve@example.com"},
+		"subject": {To: "ada@example.com", Subject: "Hi\rBcc: eve@example.com"},
+	} {
+		if _, err := render(testFrom, msg); err == nil {
+			t.Errorf("render() with a line break in %s error = nil, want one", name)
+		}
+	}
+	if _, err := render("Task App\n<no-reply@example.com>", Message{To: "ada@example.com"}); err == nil {
+		t.Error("render() with a line break in from error = nil, want one")
+	}
+}
@@ -0,0 +1,105 @@
+package recurrence
+
+import "time"
+
+// maxMonthSteps bounds the search of monthly rules for a month that has the
+// rule's day, e.g. BYMONTHDAY=31 with INTERVAL=12 starting in April never does.
+const maxMonthSteps = 48
+
+// Next returns the first occurrence after the one at t, at the same wall-clock
+// time in the rule's time zone. It ignores Count, which depends on where t
+// falls in the series, and reports false once the rule is past Until.
+//
+// t doesn't have to match the rule: a weekly rule on Mondays and Fridays
+// continues from a Wednesday with that Friday.
+func (r Rule) Next(t time.Time) (time.Time, bool) {
+	local := t.In(r.location())
+
+	var next time.Time
+	ok := true
+	switch r.Freq {
+	case Daily:
+		// AddDate keeps the wall-clock time across DST changes
+		next = local.AddDate(0, 0, r.interval())
+	case Weekly:
+		next = r.nextWeekly(local)
+	case Monthly:
+		next, ok = r.nextMonthly(local)
+	default:
+		ok = false
+	}
+
+	if !ok || (r.Until != nil && next.After(*r.Until)) {
+		return time.Time{}, false
+	}
+
+	return next, true
+}
+
+// Occurrences returns up to n occurrences following start, which is
+// occurrence number index of the series counting from 0. They stop where the
+// rule ends, by Count or Until.
+func (r Rule) Occurrences(start time.Time, index, n int) []time.Time {
+	var occurrences []time.Time
+
+	t := start
+	for i := index + 1; len(occurrences) < n; i++ {
+		if r.Count > 0 && i >= r.Count {
+			break
+		}
+
+		next, ok := r.Next(t)
+		if !ok {
+			break
+		}
+		occurrences = append(occurrences, next)
+		t = next
+	}
+
+	return occurrences
+}
+
+func (r Rule) nextWeekly(local time.Time) time.Time {
+	weekdays := r.sortedWeekdays()
+	if len(weekdays) == 0 {
+		weekdays = []time.Weekday{local.Weekday()}
+	}
+
+	// A later weekday of the same week
+	today := mondayIndex(local.Weekday())
+	for _, day := range weekdays {
+		if mondayIndex(day) > today {
+			return local.AddDate(0, 0, mondayIndex(day)-today)
+		}
+	}
+
+	// Otherwise the first weekday, interval weeks after this week's Monday
+	return local.AddDate(0, 0, 7*r.interval()-today+mondayIndex(weekdays[0]))
+}
+
+func (r Rule) nextMonthly(local time.Time) (time.Time, bool) {
+	day := r.MonthDay
+	if day == 0 {
+		day = local.Day()
+	}
+
+	// Step 0 looks further into the current month, for a t off the schedule
+	year, month, _ := local.Date()
+	for step := 0; step <= maxMonthSteps; step++ {
+		first := time.Date(year, month+time.Month(step*r.interval()), 1, local.Hour(), local.Minute(), local.Second(), local.Nanosecond(), local.Location())
+		days := first.AddDate(0, 1, -1).Day()
+
+		d := day
+		if day == LastDayOfMonth {
+			d = days
+		} else if day > days {
+			continue // Skipped, as RFC 5545 does
+		}
+
+		if candidate := first.AddDate(0, 0, d-1); candidate.After(local) {
+			return candidate, true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,247 @@
+// Package recurrence parses and expands the RRULE-style schedules of
+// recurring todos. It supports a subset of RFC 5545: daily, weekly and monthly
+// frequencies with an interval, weekdays for weekly rules, a day of the month
+// for monthly ones, and an end given as a date or an occurrence count.
+//
+// Rules are written as semicolon-separated KEY=VALUE parts, for example
+//
+//	FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;COUNT=10;TZID=Europe/Paris
+//
+// TZID, which RFC 5545 keeps on DTSTART, is part of the rule here so that
+// weekdays and days of the month are read in the user's time zone.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is how often a rule repeats, in units of its interval.
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// Limits keep rules sensible and expansion cheap
+const (
+	MaxInterval = 366
+	MaxCount    = 1000
+)
+
+// LastDayOfMonth is the MonthDay of rules repeating on the last day of each month
+const LastDayOfMonth = -1
+
+// ErrInvalidRule wraps every parsing and validation error
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+// untilLayout and untilDateLayout are the UNTIL forms of RFC 5545
+const (
+	untilLayout     = "20060102T150405Z"
+	untilDateLayout = "20060102"
+)
+
+// weekdayCodes are the BYDAY values, indexed by time.Weekday
+var weekdayCodes = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// Rule is a parsed recurrence rule. The zero Interval means 1, a nil
+// Location means UTC, and empty Weekdays or a zero MonthDay repeat on the
+// weekday or day of the month of the first occurrence.
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	// Weekdays is only used by weekly rules
+	Weekdays []time.Weekday
+	// MonthDay is only used by monthly rules: 1 to 31, or LastDayOfMonth.
+	// Months without that day are skipped, as in RFC 5545.
+	MonthDay int
+	// Until and Count end the rule; at most one of them is set
+	Until    *time.Time
+	Count    int
+	Location *time.Location
+}
+
+// Parse reads and validates a rule, with or without the "RRULE:" prefix.
+func Parse(s string) (Rule, error) {
+	var r Rule
+
+	s = strings.TrimSpace(s)
+	if len(s) >= 6 && strings.EqualFold(s[:6], "RRULE:") {
+		s = s[6:]
+	}
+	if s == "" {
+		return r, fmt.Errorf("%w: empty rule", ErrInvalidRule)
+	}
+
+	var until string
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		key = strings.ToUpper(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if !ok || value == "" {
+			return r, fmt.Errorf("%w: %q is not KEY=VALUE", ErrInvalidRule, part)
+		}
+		if seen[key] {
+			return r, fmt.Errorf("%w: %s is given twice", ErrInvalidRule, key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			r.Freq = Frequency(strings.ToUpper(value))
+		case "INTERVAL":
+			r.Interval, err = positive(value)
+		case "BYDAY":
+			r.Weekdays, err = parseWeekdays(value)
+		case "BYMONTHDAY":
+			r.MonthDay, err = strconv.Atoi(value)
+		case "COUNT":
+			r.Count, err = positive(value)
+		case "UNTIL":
+			until = strings.ToUpper(value)
+		case "TZID":
+			r.Location, err = time.LoadLocation(value)
+		default:
+			return r, fmt.Errorf("%w: unsupported part %s", ErrInvalidRule, key)
+		}
+		if err != nil {
+			return r, fmt.Errorf("%w: bad %s %q", ErrInvalidRule, key, value)
+		}
+	}
+
+	// A date-only UNTIL includes the whole day, which needs the time zone
+	if until != "" {
+		t, err := time.Parse(untilLayout, until)
+		if err != nil {
+			day, dayErr := time.ParseInLocation(untilDateLayout, until, r.location())
+			if dayErr != nil {
+				return r, fmt.Errorf("%w: bad UNTIL %q", ErrInvalidRule, until)
+			}
+			t = day.AddDate(0, 0, 1).Add(-time.Second).UTC()
+		}
+		r.Until = &t
+	}
+
+	return r, r.Validate()
+}
+
+// positive parses INTERVAL and COUNT, whose zero values mean "not given".
+func positive(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err == nil && n < 1 {
+		err = errors.New("must be positive")
+	}
+
+	return n, err
+}
+
+func parseWeekdays(value string) ([]time.Weekday, error) {
+	var weekdays []time.Weekday
+	for _, code := range strings.Split(strings.ToUpper(value), ",") {
+		day := slices.Index(weekdayCodes[:], strings.TrimSpace(code))
+		if day < 0 {
+			return nil, fmt.Errorf("unknown weekday %q", code)
+		}
+		if !slices.Contains(weekdays, time.Weekday(day)) {
+			weekdays = append(weekdays, time.Weekday(day))
+		}
+	}
+
+	return weekdays, nil
+}
+
+// Validate checks the rule's parts fit together.
+func (r Rule) Validate() error {
+	switch r.Freq {
+	case Daily, Weekly, Monthly:
+	case "":
+		return fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	default:
+		return fmt.Errorf("%w: FREQ must be DAILY, WEEKLY or MONTHLY", ErrInvalidRule)
+	}
+
+	if r.Interval < 0 || r.Interval > MaxInterval {
+		return fmt.Errorf("%w: INTERVAL must be between 1 and %d", ErrInvalidRule, MaxInterval)
+	}
+	if len(r.Weekdays) > 0 && r.Freq != Weekly {
+		return fmt.Errorf("%w: BYDAY is only allowed with FREQ=WEEKLY", ErrInvalidRule)
+	}
+	if r.MonthDay != 0 && r.Freq != Monthly {
+		return fmt.Errorf("%w: BYMONTHDAY is only allowed with FREQ=MONTHLY", ErrInvalidRule)
+	}
+	if r.MonthDay != LastDayOfMonth && (r.MonthDay < 0 || r.MonthDay > 31) {
+		return fmt.Errorf("%w: BYMONTHDAY must be between 1 and 31, or -1 for the last day", ErrInvalidRule)
+	}
+	if r.Count < 0 || r.Count > MaxCount {
+		return fmt.Errorf("%w: COUNT must be between 1 and %d", ErrInvalidRule, MaxCount)
+	}
+	if r.Count > 0 && r.Until != nil {
+		return fmt.Errorf("%w: COUNT and UNTIL can't be combined", ErrInvalidRule)
+	}
+
+	return nil
+}
+
+// String formats the rule in canonical form, which Parse reads back.
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.Weekdays) > 0 {
+		codes := make([]string, len(r.Weekdays))
+		for i, day := range r.sortedWeekdays() {
+			codes[i] = weekdayCodes[day]
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if r.MonthDay != 0 {
+		parts = append(parts, "BYMONTHDAY="+strconv.Itoa(r.MonthDay))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilLayout))
+	}
+	if r.Location != nil && r.Location != time.UTC {
+		parts = append(parts, "TZID="+r.Location.String())
+	}
+
+	return strings.Join(parts, ";")
+}
+
+func (r Rule) interval() int {
+	return max(r.Interval, 1)
+}
+
+func (r Rule) location() *time.Location {
+	if r.Location == nil {
+		return time.UTC
+	}
+	return r.Location
+}
+
+// sortedWeekdays orders the weekdays from Monday, the RFC 5545 week start.
+func (r Rule) sortedWeekdays() []time.Weekday {
+	weekdays := slices.Clone(r.Weekdays)
+	slices.SortFunc(weekdays, func(a, b time.Weekday) int {
+		return mondayIndex(a) - mondayIndex(b)
+	})
+
+	return weekdays
+}
+
+// mondayIndex numbers weekdays from 0 for Monday to 6 for Sunday.
+func mondayIndex(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}
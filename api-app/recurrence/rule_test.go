@@ -0,0 +1,361 @@
+package recurrence
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseString(t *testing.T) {
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"RRULE:FREQ=DAILY", "FREQ=DAILY"},
+		{" rrule:freq=daily ; interval=1 ", "FREQ=DAILY"},
+		{"FREQ=DAILY;INTERVAL=3", "FREQ=DAILY;INTERVAL=3"},
+		{"FREQ=WEEKLY;BYDAY=su,FR,mo,FR", "FREQ=WEEKLY;BYDAY=MO,FR,SU"},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1", "FREQ=MONTHLY;BYMONTHDAY=-1"},
+		{"FREQ=MONTHLY;BYMONTHDAY=0", "FREQ=MONTHLY"},
+		{"TZID=Europe/Paris;COUNT=10;BYDAY=WE,MO;INTERVAL=2;FREQ=WEEKLY", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;COUNT=10;TZID=Europe/Paris"},
+		{"FREQ=DAILY;UNTIL=20261231T235959z", "FREQ=DAILY;UNTIL=20261231T235959Z"},
+		{"FREQ=DAILY;TZID=UTC", "FREQ=DAILY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			r, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := r.String(); got != tt.want {
+				t.Fatalf("String() = %q, want %q", got, tt.want)
+			}
+
+			// The canonical form reads back as itself
+			again, err := Parse(tt.want)
+			if err != nil || again.String() != tt.want {
+				t.Errorf("Parse(%q) = %q, %v", tt.want, again, err)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{"", "empty rule"},
+		{"RRULE:", "empty rule"},
+		{"FREQ", "not KEY=VALUE"},
+		{"FREQ=", "not KEY=VALUE"},
+		{"FREQ=DAILY;", "not KEY=VALUE"},
+		{"FREQ=DAILY;freq=WEEKLY", "FREQ is given twice"},
+		{"FREQ=DAILY;BYSETPOS=1", "unsupported part BYSETPOS"},
+		{"FREQ=DAILY;INTERVAL=0", "bad INTERVAL"},
+		{"FREQ=DAILY;INTERVAL=two", "bad INTERVAL"},
+		{"FREQ=WEEKLY;BYDAY=MO,XX", "bad BYDAY"},
+		{"FREQ=WEEKLY;BYDAY=1MO", "bad BYDAY"},
+		{"FREQ=MONTHLY;BYMONTHDAY=last", "bad BYMONTHDAY"},
+		{"FREQ=DAILY;COUNT=-1", "bad COUNT"},
+		{"FREQ=DAILY;TZID=Mars/Olympus_Mons", "bad TZID"},
+		{"FREQ=DAILY;UNTIL=2026-12-31", "bad UNTIL"},
+		{"FREQ=DAILY;UNTIL=20261231T235959", "bad UNTIL"},
+		// Validate's checks, reached through Parse
+		{"INTERVAL=2", "FREQ is required"},
+		{"FREQ=YEARLY", "FREQ must be DAILY, WEEKLY or MONTHLY"},
+		{"FREQ=DAILY;INTERVAL=367", "INTERVAL must be between 1 and 366"},
+		{"FREQ=DAILY;BYDAY=MO", "BYDAY is only allowed with FREQ=WEEKLY"},
+		{"FREQ=WEEKLY;BYMONTHDAY=1", "BYMONTHDAY is only allowed with FREQ=MONTHLY"},
+		{"FREQ=MONTHLY;BYMONTHDAY=32", "BYMONTHDAY must be between 1 and 31"},
+		{"FREQ=MONTHLY;BYMONTHDAY=-2", "BYMONTHDAY must be between 1 and 31"},
+		{"FREQ=DAILY;COUNT=1001", "COUNT must be between 1 and 1000"},
+		{"FREQ=DAILY;COUNT=3;UNTIL=20261231", "COUNT and UNTIL can't be combined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			_, err := Parse(tt.rule)
+			if !errors.Is(err, ErrInvalidRule) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse() error = %v, want ErrInvalidRule with %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	until := time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		rule Rule
+		want string
+	}{
+		{"zero interval", Rule{Freq: Daily}, ""},
+		{"last day of month", Rule{Freq: Monthly, MonthDay: LastDayOfMonth}, ""},
+		{"until", Rule{Freq: Weekly, Weekdays: []time.Weekday{time.Monday}, Until: &until}, ""},
+		{"no frequency", Rule{}, "FREQ is required"},
+		{"unknown frequency", Rule{Freq: "HOURLY"}, "FREQ must be DAILY, WEEKLY or MONTHLY"},
+		{"negative interval", Rule{Freq: Daily, Interval: -1}, "INTERVAL must be between 1 and 366"},
+		{"interval too large", Rule{Freq: Daily, Interval: MaxInterval + 1}, "INTERVAL must be between 1 and 366"},
+		{"weekdays of a monthly rule", Rule{Freq: Monthly, Weekdays: []time.Weekday{time.Monday}}, "BYDAY is only allowed with FREQ=WEEKLY"},
+		{"month day of a daily rule", Rule{Freq: Daily, MonthDay: 1}, "BYMONTHDAY is only allowed with FREQ=MONTHLY"},
+		{"month day too large", Rule{Freq: Monthly, MonthDay: 32}, "BYMONTHDAY must be between 1 and 31"},
+		{"month day too small", Rule{Freq: Monthly, MonthDay: -3}, "BYMONTHDAY must be between 1 and 31"},
+		{"negative count", Rule{Freq: Daily, Count: -1}, "COUNT must be between 1 and 1000"},
+		{"count too large", Rule{Freq: Daily, Count: MaxCount + 1}, "COUNT must be between 1 and 1000"},
+		{"count and until", Rule{Freq: Daily, Count: 2, Until: &until}, "COUNT and UNTIL can't be combined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidRule) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() error = %v, want ErrInvalidRule with %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUntil(t *testing.T) {
+	tests := []struct {
+		rule string
+		want time.Time
+	}{
+		// A timestamp is taken as is, whatever the time zone
+		{"FREQ=DAILY;UNTIL=20261231T120000Z", time.Date(2026, 12, 31, 12, 0, 0, 0, time.UTC)},
+		{"FREQ=DAILY;UNTIL=20261231T120000Z;TZID=America/New_York", time.Date(2026, 12, 31, 12, 0, 0, 0, time.UTC)},
+		// A date includes the whole day in the rule's time zone
+		{"FREQ=DAILY;UNTIL=20261231", time.Date(2026, 12, 31, 23, 59, 59, 0, time.UTC)},
+		{"FREQ=DAILY;UNTIL=20261231;TZID=America/New_York", time.Date(2027, 1, 1, 4, 59, 59, 0, time.UTC)},
+		{"TZID=Asia/Tokyo;FREQ=DAILY;UNTIL=20261231", time.Date(2026, 12, 31, 14, 59, 59, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			r, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if r.Until == nil || !r.Until.Equal(tt.want) {
+				t.Errorf("Until = %v, want %v", r.Until, tt.want)
+			}
+		})
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		start string // RFC 3339, read in the rule's time zone
+		index int
+		n     int
+		want  []string
+	}{
+		{
+			name:  "daily",
+			rule:  "FREQ=DAILY;INTERVAL=2",
+			start: "2026-10-30T09:00:00Z",
+			n:     3,
+			want:  []string{"2026-11-01T09:00:00Z", "2026-11-03T09:00:00Z", "2026-11-05T09:00:00Z"},
+		},
+		{
+			name:  "daily into summer time",
+			rule:  "FREQ=DAILY;TZID=Europe/Paris",
+			start: "2026-03-28T09:00:00+01:00",
+			n:     2,
+			want:  []string{"2026-03-29T09:00:00+02:00", "2026-03-30T09:00:00+02:00"},
+		},
+		{
+			name:  "daily out of summer time",
+			rule:  "FREQ=DAILY;TZID=America/New_York",
+			start: "2026-10-31T23:30:00-04:00",
+			n:     2,
+			want:  []string{"2026-11-01T23:30:00-05:00", "2026-11-02T23:30:00-05:00"},
+		},
+		{
+			name:  "weekly on the start's weekday",
+			rule:  "FREQ=WEEKLY",
+			start: "2026-10-18T09:00:00Z", // Sunday
+			n:     2,
+			want:  []string{"2026-10-25T09:00:00Z", "2026-11-01T09:00:00Z"},
+		},
+		{
+			name:  "fortnightly from a weekday between the rule's",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR",
+			start: "2026-10-14T09:00:00Z", // Wednesday
+			n:     4,
+			want:  []string{"2026-10-16T09:00:00Z", "2026-10-26T09:00:00Z", "2026-10-30T09:00:00Z", "2026-11-09T09:00:00Z"},
+		},
+		{
+			name:  "fortnightly from a weekday after the rule's",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR",
+			start: "2026-10-17T09:00:00Z", // Saturday
+			n:     2,
+			want:  []string{"2026-10-26T09:00:00Z", "2026-10-30T09:00:00Z"},
+		},
+		{
+			name:  "every third week from a Sunday, the end of the week",
+			rule:  "FREQ=WEEKLY;INTERVAL=3;BYDAY=TU",
+			start: "2026-10-18T09:00:00Z", // Sunday
+			n:     2,
+			want:  []string{"2026-11-03T09:00:00Z", "2026-11-24T09:00:00Z"},
+		},
+		{
+			name:  "weekly in the rule's time zone",
+			rule:  "FREQ=WEEKLY;BYDAY=MO;TZID=Asia/Tokyo",
+			start: "2026-10-18T20:00:00Z", // Monday in Tokyo
+			n:     1,
+			want:  []string{"2026-10-26T05:00:00+09:00"},
+		},
+		{
+			name:  "monthly on the 31st skips short months",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=31",
+			start: "2026-01-31T09:00:00Z",
+			n:     4,
+			want:  []string{"2026-03-31T09:00:00Z", "2026-05-31T09:00:00Z", "2026-07-31T09:00:00Z", "2026-08-31T09:00:00Z"},
+		},
+		{
+			name:  "monthly on the start's day skips short months",
+			rule:  "FREQ=MONTHLY",
+			start: "2026-08-31T09:00:00Z",
+			n:     2,
+			want:  []string{"2026-10-31T09:00:00Z", "2026-12-31T09:00:00Z"},
+		},
+		{
+			name:  "monthly on the last day",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=-1",
+			start: "2027-12-31T09:00:00Z",
+			n:     4,
+			want:  []string{"2028-01-31T09:00:00Z", "2028-02-29T09:00:00Z", "2028-03-31T09:00:00Z", "2028-04-30T09:00:00Z"},
+		},
+		{
+			name:  "monthly from a day before the rule's",
+			rule:  "FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=15",
+			start: "2026-01-10T09:00:00Z",
+			n:     2,
+			want:  []string{"2026-01-15T09:00:00Z", "2026-04-15T09:00:00Z"},
+		},
+		{
+			// The search gives up after maxMonthSteps intervals
+			name:  "monthly on a day the months stepped to never have",
+			rule:  "FREQ=MONTHLY;INTERVAL=12;BYMONTHDAY=31",
+			start: "2026-04-30T09:00:00Z",
+			n:     1,
+			want:  nil,
+		},
+		{
+			name:  "count from the first occurrence",
+			rule:  "FREQ=DAILY;COUNT=3",
+			start: "2026-10-18T09:00:00Z",
+			n:     10,
+			want:  []string{"2026-10-19T09:00:00Z", "2026-10-20T09:00:00Z"},
+		},
+		{
+			name:  "count from a later occurrence",
+			rule:  "FREQ=DAILY;COUNT=3",
+			start: "2026-10-19T09:00:00Z",
+			index: 1,
+			n:     10,
+			want:  []string{"2026-10-20T09:00:00Z"},
+		},
+		{
+			name:  "count from the last occurrence",
+			rule:  "FREQ=DAILY;COUNT=3",
+			start: "2026-10-20T09:00:00Z",
+			index: 2,
+			n:     10,
+			want:  nil,
+		},
+		{
+			name:  "fewer asked for than the count leaves",
+			rule:  "FREQ=DAILY;COUNT=3",
+			start: "2026-10-18T09:00:00Z",
+			n:     1,
+			want:  []string{"2026-10-19T09:00:00Z"},
+		},
+		{
+			name:  "until a date includes that day",
+			rule:  "FREQ=DAILY;UNTIL=20261231;TZID=America/New_York",
+			start: "2026-12-29T21:00:00-05:00",
+			n:     10,
+			want:  []string{"2026-12-30T21:00:00-05:00", "2026-12-31T21:00:00-05:00"},
+		},
+		{
+			name:  "until a timestamp",
+			rule:  "FREQ=DAILY;UNTIL=20261231T120000Z;TZID=America/New_York",
+			start: "2026-12-29T09:00:00-05:00",
+			n:     10,
+			want:  []string{"2026-12-30T09:00:00-05:00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			start, err := time.Parse(time.RFC3339, tt.start)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, occurrence := range r.Occurrences(start, tt.index, tt.n) {
+				got = append(got, occurrence.Format(time.RFC3339))
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("Occurrences() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextMonthlySearch(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  Rule
+		start time.Time
+		want  time.Time // zero for none
+	}{
+		{
+			// Every 12 months from April stays in April, which has no 31st, so
+			// the search gives up after maxMonthSteps intervals
+			name:  "never",
+			rule:  Rule{Freq: Monthly, Interval: 12, MonthDay: 31},
+			start: time.Date(2026, time.April, 30, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "next interval",
+			rule:  Rule{Freq: Monthly, Interval: 11, MonthDay: 31},
+			start: time.Date(2026, time.April, 30, 9, 0, 0, 0, time.UTC),
+			want:  time.Date(2027, time.March, 31, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "leap day",
+			rule:  Rule{Freq: Monthly, Interval: 12, MonthDay: 29},
+			start: time.Date(2024, time.February, 29, 9, 0, 0, 0, time.UTC),
+			want:  time.Date(2028, time.February, 29, 9, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, ok := tt.rule.Next(tt.start)
+			if ok != !tt.want.IsZero() || !next.Equal(tt.want) {
+				t.Errorf("Next() = %v, %v; want %v", next, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextUnknownFrequency(t *testing.T) {
+	if next, ok := (Rule{Freq: "YEARLY"}).Next(time.Now()); ok {
+		t.Errorf("Next() = %v, want none", next)
+	}
+}
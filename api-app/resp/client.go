@@ -0,0 +1,170 @@
+// Package resp is a small client for RESP, the protocol of Redis and of the
+// servers compatible with it. It covers what the API needs: sending a
+// command and reading its reply over a single connection.
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Error is an error reply from the server, which leaves the connection
+// usable.
+type Error string
+
+func (e Error) Error() string {
+	return "redis: " + string(e)
+}
+
+// Client sends commands one at a time over a single connection, dialed on
+// first use and again after a failure. It is safe for concurrent use.
+type Client struct {
+	addr     string
+	password string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewClient returns a client of the server at addr (host:port),
+// authenticating with password unless it is empty. Nothing is dialed until
+// the first command.
+func NewClient(addr, password string) *Client {
+	return &Client{addr: addr, password: password}
+}
+
+// Do sends a command and returns its reply: a string, an int64, nil, a []any,
+// or an Error. The connection is dropped after any failure but an error
+// reply. The deadline of ctx, if any, bounds the whole exchange.
+func (c *Client) Do(ctx context.Context, args ...string) (reply any, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	defer func() {
+		var replyErr Error
+		if err != nil && !errors.As(err, &replyErr) {
+			c.drop()
+		}
+	}()
+
+	if c.conn == nil {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+		if err != nil {
+			return nil, err
+		}
+		c.conn, c.reader = conn, bufio.NewReader(conn)
+
+		// A connection that failed to authenticate isn't kept, error reply
+		// or not
+		if c.password != "" {
+			if _, err = c.roundTrip(ctx, "AUTH", c.password); err != nil {
+				c.drop()
+				return nil, err
+			}
+		}
+	}
+
+	return c.roundTrip(ctx, args...)
+}
+
+// Close closes the connection, if any. The client dials again on the next
+// command.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn, c.reader = nil, nil
+
+	return err
+}
+
+// drop closes the connection after a failure. Callers hold mu.
+func (c *Client) drop() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn, c.reader = nil, nil
+	}
+}
+
+// roundTrip writes a command and reads the reply. Callers hold mu.
+func (c *Client) roundTrip(ctx context.Context, args ...string) (any, error) {
+	deadline, _ := ctx.Deadline() // The zero time clears an earlier deadline
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	if _, err := c.conn.Write(AppendCommand(nil, args...)); err != nil {
+		return nil, err
+	}
+
+	return ReadReply(c.reader)
+}
+
+// AppendCommand appends a command, encoded as an array of bulk strings, to b.
+func AppendCommand(b []byte, args ...string) []byte {
+	b = fmt.Appendf(b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		b = fmt.Appendf(b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	return b
+}
+
+// ReadReply reads one value: a string, an int64, nil, a []any, or an Error
+// for an error reply, which is returned as the error.
+func ReadReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch prefix, rest := line[0], line[1:]; prefix {
+	case '+':
+		return rest, nil
+	case '-':
+		return nil, Error(rest)
+	case ':':
+		return strconv.ParseInt(rest, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = ReadReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
@@ -0,0 +1,206 @@
+package resp_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"task-app/resp"
+	"task-app/resp/resptest"
+	"testing"
+	"time"
+)
+
+func TestReadReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    any
+		wantErr error
+	}{
+		{"simple string", "+OK\r\n", "OK", nil},
+		{"integer", ":42\r\n", int64(42), nil},
+		{"bulk string", "$5\r\nhello\r\n", "hello", nil},
+		{"bulk string with CRLF", "$4\r\na\r\nb\r\n", "a\r\nb", nil},
+		{"null bulk string", "$-1\r\n", nil, nil},
+		{"array", "*2\r\n:1\r\n$1\r\nx\r\n", []any{int64(1), "x"}, nil},
+		{"null array", "*-1\r\n", nil, nil},
+		{"error", "-ERR wrong\r\n", nil, resp.Error("ERR wrong")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resp.ReadReply(bufio.NewReader(strings.NewReader(tt.input)))
+			if err != tt.wantErr {
+				t.Fatalf("ReadReply() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadReply() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadReplyMalformed(t *testing.T) {
+	for _, input := range []string{"", "\r\n", "?what\r\n", ":x\r\n", "$5\r\nab\r\n"} {
+		if _, err := resp.ReadReply(bufio.NewReader(strings.NewReader(input))); err == nil {
+			t.Errorf("ReadReply(%q) succeeded, want an error", input)
+		}
+	}
+}
+
+func TestAppendCommand(t *testing.T) {
+	got := string(resp.AppendCommand(nil, "SET", "key", "a b"))
+	want := "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$3\r\na b\r\n"
+	if got != want {
+		t.Errorf("AppendCommand() = %q, want %q", got, want)
+	}
+}
+
+func TestClientDo(t *testing.T) {
+	server := resptest.NewServer("")
+	defer server.Close()
+
+	client := resp.NewClient(server.Addr, "")
+	defer client.Close()
+	ctx := context.Background()
+
+	if reply, err := client.Do(ctx, "SET", "key", "value"); err != nil || reply != "OK" {
+		t.Fatalf("SET = %v, %v; want OK", reply, err)
+	}
+	if reply, err := client.Do(ctx, "GET", "key"); err != nil || reply != "value" {
+		t.Fatalf("GET = %v, %v; want value", reply, err)
+	}
+
+	// An error reply is returned as such and keeps the connection
+	_, err := client.Do(ctx, "NOPE")
+	var replyErr resp.Error
+	if !errors.As(err, &replyErr) {
+		t.Fatalf("unknown command error = %v, want a resp.Error", err)
+	}
+	if reply, err := client.Do(ctx, "EXISTS", "key"); err != nil || reply != int64(1) {
+		t.Fatalf("EXISTS after an error reply = %v, %v; want 1", reply, err)
+	}
+}
+
+func TestClientAuth(t *testing.T) {
+	server := resptest.NewServer("secret")
+	defer server.Close()
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  string
+	}{
+		{"right password", "secret", ""},
+		{"wrong password", "guess", "WRONGPASS"},
+		{"no password", "", "NOAUTH"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := resp.NewClient(server.Addr, tt.password)
+			defer client.Close()
+
+			_, err := client.Do(ctx, "PING")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("PING error = %v", err)
+				}
+				return
+			}
+
+			var replyErr resp.Error
+			if !errors.As(err, &replyErr) || !strings.HasPrefix(string(replyErr), tt.wantErr) {
+				t.Fatalf("PING error = %v, want a %s error reply", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientFailedAuthRetries(t *testing.T) {
+	server := resptest.NewServer("secret")
+	defer server.Close()
+
+	// Each command dials and authenticates again after a rejected AUTH
+	client := resp.NewClient(server.Addr, "guess")
+	defer client.Close()
+	for range 2 {
+		if _, err := client.Do(context.Background(), "PING"); err == nil {
+			t.Fatal("PING with a wrong password succeeded")
+		}
+	}
+	if got := server.Commands(); got != 2 {
+		t.Errorf("server got %d commands, want 2 AUTH", got)
+	}
+}
+
+func TestClientReconnects(t *testing.T) {
+	server := resptest.NewServer("secret")
+	defer server.Close()
+
+	client := resp.NewClient(server.Addr, "secret")
+	defer client.Close()
+	ctx := context.Background()
+
+	if _, err := client.Do(ctx, "SET", "key", "value"); err != nil {
+		t.Fatalf("SET error = %v", err)
+	}
+
+	// The command on the dropped connection fails, and the next one dials
+	// and authenticates again
+	server.CloseClientConnections()
+	if _, err := client.Do(ctx, "GET", "key"); err == nil {
+		t.Fatal("GET on a dropped connection succeeded")
+	}
+	if reply, err := client.Do(ctx, "GET", "key"); err != nil || reply != "value" {
+		t.Fatalf("GET after reconnecting = %v, %v; want value", reply, err)
+	}
+}
+
+func TestClientDialError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	client := resp.NewClient(addr, "")
+	if _, err := client.Do(context.Background(), "PING"); err == nil {
+		t.Fatal("PING to a closed port succeeded")
+	}
+}
+
+func TestClientDeadline(t *testing.T) {
+	// A server that accepts but never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	client := resp.NewClient(listener.Addr().String(), "")
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = client.Do(ctx, "PING")
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("PING error = %v, want a timeout", err)
+	}
+}
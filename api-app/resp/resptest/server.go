@@ -0,0 +1,259 @@
+// Package resptest runs an in-process RESP server for tests, in the manner
+// of net/http/httptest. It keeps strings in memory and knows the few
+// commands the API sends: AUTH, PING, SET (with EX or PX), GET, EXISTS and
+// DEL.
+package resptest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"task-app/resp"
+	"time"
+)
+
+// Server is a RESP server listening on a local port.
+type Server struct {
+	// Addr is the host:port the server listens on
+	Addr string
+
+	password string
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	closed   bool
+	conns    map[net.Conn]bool
+	values   map[string]value
+	offset   time.Duration
+	commands int
+}
+
+// value is a stored string, expiring at expiresAt unless that is zero.
+type value struct {
+	data      string
+	expiresAt time.Time
+}
+
+// NewServer starts a server requiring clients to AUTH with password, unless
+// it is empty. Close stops it.
+func NewServer(password string) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("resptest: failed to listen on a port: %v", err))
+	}
+
+	s := &Server{
+		Addr:     listener.Addr().String(),
+		password: password,
+		listener: listener,
+		conns:    map[net.Conn]bool{},
+		values:   map[string]value{},
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s
+}
+
+// Close stops the server, dropping its connections, and waits for them to
+// end.
+func (s *Server) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	s.listener.Close()
+	s.CloseClientConnections()
+	s.wg.Wait()
+}
+
+// CloseClientConnections drops the open connections, as a restarting server
+// would, while still accepting new ones.
+func (s *Server) CloseClientConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// FastForward moves the clock of the server forward by d, expiring the keys
+// whose time has come.
+func (s *Server) FastForward(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.offset += d
+}
+
+// Commands returns how many commands the server has received, AUTH included.
+func (s *Server) Commands() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.commands
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		// A connection accepted while closing would otherwise be missed
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = true
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.handle(conn)
+	}
+}
+
+// handle answers the commands of one connection until it ends.
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	authenticated := s.password == ""
+	for {
+		request, err := resp.ReadReply(reader)
+		if err != nil {
+			return
+		}
+		items, ok := request.([]any)
+		if !ok || len(items) == 0 {
+			conn.Write([]byte("-ERR protocol error\r\n"))
+			return
+		}
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i], _ = item.(string)
+		}
+
+		var reply string
+		if strings.EqualFold(args[0], "AUTH") {
+			reply, authenticated = s.auth(args[1:], authenticated)
+		} else if !authenticated {
+			s.count()
+			reply = "-NOAUTH Authentication required.\r\n"
+		} else {
+			reply = s.exec(args)
+		}
+
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+// count records a command received.
+func (s *Server) count() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.commands++
+}
+
+// auth answers AUTH, returning whether the connection is authenticated after.
+func (s *Server) auth(args []string, authenticated bool) (string, bool) {
+	s.count()
+
+	switch {
+	case len(args) != 1:
+		return "-ERR wrong number of arguments for 'auth' command\r\n", authenticated
+	case s.password == "":
+		return "-ERR AUTH called without any password configured\r\n", authenticated
+	case args[0] != s.password:
+		return "-WRONGPASS invalid username-password pair or user is disabled.\r\n", false
+	}
+
+	return "+OK\r\n", true
+}
+
+// exec runs a command other than AUTH and returns the encoded reply.
+func (s *Server) exec(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.commands++
+	now := time.Now().Add(s.offset)
+	for key, v := range s.values {
+		if !v.expiresAt.IsZero() && !now.Before(v.expiresAt) {
+			delete(s.values, key)
+		}
+	}
+
+	switch name := strings.ToUpper(args[0]); {
+	case name == "PING":
+		return "+PONG\r\n"
+	case name == "SET" && (len(args) == 3 || len(args) == 5):
+		v := value{data: args[2]}
+		if len(args) == 5 {
+			ttl, err := expiry(args[3], args[4])
+			if err != nil {
+				return "-ERR " + err.Error() + "\r\n"
+			}
+			v.expiresAt = now.Add(ttl)
+		}
+		s.values[args[1]] = v
+		return "+OK\r\n"
+	case name == "GET" && len(args) == 2:
+		v, ok := s.values[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v.data), v.data)
+	case (name == "EXISTS" || name == "DEL") && len(args) > 1:
+		n := 0
+		for _, key := range args[1:] {
+			if _, ok := s.values[key]; ok {
+				n++
+				if name == "DEL" {
+					delete(s.values, key)
+				}
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	}
+
+	return fmt.Sprintf("-ERR unknown command or wrong number of arguments for '%s'\r\n", args[0])
+}
+
+// expiry parses the EX (seconds) or PX (milliseconds) option of SET.
+func expiry(option, amount string) (time.Duration, error) {
+	n, err := strconv.ParseInt(amount, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, errors.New("invalid expire time in 'set' command")
+	}
+
+	switch strings.ToUpper(option) {
+	case "EX":
+		return time.Duration(n) * time.Second, nil
+	case "PX":
+		return time.Duration(n) * time.Millisecond, nil
+	}
+
+	return 0, errors.New("syntax error")
+}
@@ -1,31 +1,44 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-const secretKey = "kjfhdkjhGHDFGgghfjgHJGgdhghjFGUYtgGDFY87687GUD74574RFUGFJHghiuY87557UGIUGDFYT757576GHGJHGJHddfdfher"
+// Claims are the parts of a valid token the API relies on
+type Claims struct {
+	// ID is the token's unique jti, by which it gets revoked
+	ID     string
+	UserID int64
+	// SessionID is the login the token belongs to
+	SessionID int64
+	Email     string
+	ExpiresAt time.Time
+}
 
-// A simple in-memory token blacklist (use Redis for production)
-var (
-	tokenBlacklist = make(map[string]bool)
-	blacklistMutex sync.Mutex
-)
+// GenerateToken creates a JWT token for authentication within a session,
+// valid for ttl and signed with the current key
+func (k *Keyring) GenerateToken(email string, userID, sessionID int64, ttl time.Duration) (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", fmt.Errorf("failed to generate token ID: %w", err)
+	}
 
-// GenerateToken creates a JWT token for authentication
-func GenerateToken(email string, userID int64) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	token := jwt.NewWithClaims(k.current.Method, jwt.MapClaims{
+		"jti":    hex.EncodeToString(jti),
 		"email":  email,
 		"userID": userID,
-		"exp":    time.Now().Add(time.Hour * 2).Unix(),
+		"sid":    sessionID,
+		"exp":    time.Now().Add(ttl).Unix(),
 	})
+	token.Header["kid"] = k.current.ID
 
-	signedToken, err := token.SignedString([]byte(secretKey))
+	signedToken, err := token.SignedString(k.current.signKey)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
@@ -33,60 +46,70 @@ func GenerateToken(email string, userID int64) (string, error) {
 	return signedToken, nil
 }
 
-// VerifyToken parses and validates a JWT token
-func VerifyToken(token string) (int64, error) {
+// VerifyToken parses and validates a JWT token signed by any key of the
+// keyring. Whether it was revoked is up to the caller to check, by its ID.
+func (k *Keyring) VerifyToken(token string) (*Claims, error) {
 	// Parse and validate the token
 	parsedToken, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
-		// Check if the signing method is HMAC
-		_, ok := t.Method.(*jwt.SigningMethodHMAC)
+		// Find the key by kid, and make sure the token uses its algorithm
+		kid, _ := t.Header["kid"].(string)
+		key, ok := k.keys[kid]
 		if !ok {
+			return nil, errors.New("Unknown signing key.")
+		}
+		if t.Method.Alg() != key.Method.Alg() {
 			return nil, errors.New("Unexpected signing method.")
 		}
-		return []byte(secretKey), nil
+		return key.verifyKey, nil
 	})
 
 	if err != nil {
-		return 0, errors.New("Could not parse token.")
+		return nil, errors.New("Could not parse token.")
 	}
 
 	// Ensure that the token is valid
 	if !parsedToken.Valid {
-		return 0, errors.New("Invalid token.")
+		return nil, errors.New("Invalid token.")
 	}
 
 	// Extract the claims
 	claims, ok := parsedToken.Claims.(jwt.MapClaims)
 	if !ok {
-		return 0, errors.New("Invalid token claims.")
+		return nil, errors.New("Invalid token claims.")
 	}
 
 	// Check the expiration date
 	expirationTime, ok := claims["exp"].(float64)
 	if !ok {
-		return 0, errors.New("Invalid expiration time.")
+		return nil, errors.New("Invalid expiration time.")
 	}
 
 	// If token is expired, reject it immediately
 	if time.Unix(int64(expirationTime), 0).Before(time.Now()) {
-		return 0, errors.New("Token has expired.")
+		return nil, errors.New("Token has expired.")
 	}
 
-	// Check if the token is blacklisted
-	blacklistMutex.Lock()
-	if tokenBlacklist[token] {
-		blacklistMutex.Unlock()
-		return 0, errors.New("Token is revoked.")
+	// Tokens without an ID couldn't be revoked
+	jti, ok := claims["jti"].(string)
+	if !ok || jti == "" {
+		return nil, errors.New("Missing token ID.")
 	}
-	blacklistMutex.Unlock()
 
-	// If token is valid and not blacklisted, return the user ID
-	userID := int64(claims["userID"].(float64))
-	return userID, nil
+	userID, ok := claims["userID"].(float64)
+	if !ok {
+		return nil, errors.New("Invalid token claims.")
+	}
+	sessionID, ok := claims["sid"].(float64)
+	if !ok {
+		return nil, errors.New("Missing session ID.")
+	}
+	email, _ := claims["email"].(string)
+
+	return &Claims{
+		ID:        jti,
+		UserID:    int64(userID),
+		SessionID: int64(sessionID),
+		Email:     email,
+		ExpiresAt: time.Unix(int64(expirationTime), 0),
+	}, nil
 }
-
-// InvalidateToken adds a token to the blacklist
-func InvalidateToken(token string) {
-	blacklistMutex.Lock()
-	tokenBlacklist[token] = true
-	blacklistMutex.Unlock()
-}
\ No newline at end of file
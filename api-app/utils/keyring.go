@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minSecretLength is the shortest HS256 secret accepted, the size of the hash
+const minSecretLength = 32
+
+// SigningKey is a key tokens are signed with, named by the kid header of
+// the tokens it signs.
+type SigningKey struct {
+	ID     string
+	Method jwt.SigningMethod
+	// signKey signs tokens and verifyKey checks them; both are the secret
+	// for HMAC keys
+	signKey   any
+	verifyKey any
+}
+
+// HMACKey returns an HS256 key with the given secret.
+func HMACKey(id string, secret []byte) (*SigningKey, error) {
+	if len(secret) < minSecretLength {
+		return nil, fmt.Errorf("key %q: an HS256 secret needs at least %d bytes", id, minSecretLength)
+	}
+
+	return &SigningKey{ID: id, Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}, nil
+}
+
+// PrivateKey returns a key from a PEM encoded private key, whose type picks
+// the algorithm: RS256 for RSA, ES256 for ECDSA on P-256 and EdDSA for
+// Ed25519.
+func PrivateKey(id string, pemData []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, fmt.Errorf("key %q: no PEM data found", id)
+	}
+
+	var private any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("key %q: unsupported PEM block %q", id, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", id, err)
+	}
+
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, signKey: private, verifyKey: &private.PublicKey}, nil
+	case *ecdsa.PrivateKey:
+		if private.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("key %q: ES256 needs a P-256 key", id)
+		}
+		return &SigningKey{ID: id, Method: jwt.SigningMethodES256, signKey: private, verifyKey: &private.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, signKey: private, verifyKey: private.Public()}, nil
+	}
+
+	return nil, fmt.Errorf("key %q: unsupported key type %T", id, private)
+}
+
+// LoadKeys reads the keys of a directory, each file named after its kid:
+// <kid>.pem for a private key, <kid>.key for an HS256 secret. Other files
+// are ignored.
+func LoadKeys(dir string) ([]*SigningKey, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*SigningKey
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".pem" && ext != ".key") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		id := strings.TrimSuffix(entry.Name(), ext)
+		var key *SigningKey
+		if ext == ".pem" {
+			key, err = PrivateKey(id, data)
+		} else {
+			key, err = HMACKey(id, []byte(strings.TrimSpace(string(data))))
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// Keyring holds the active keys: tokens signed by any of them are valid,
+// and new ones are signed with the current key. Rotating means adding a key,
+// making it current, then dropping the old one once its tokens expired.
+type Keyring struct {
+	keys    map[string]*SigningKey
+	current *SigningKey
+}
+
+// NewKeyring returns a keyring signing with the key named currentID, or
+// when it is empty, with the last key in kid order.
+func NewKeyring(keys []*SigningKey, currentID string) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys")
+	}
+
+	ring := &Keyring{keys: make(map[string]*SigningKey, len(keys))}
+	for _, key := range keys {
+		if _, ok := ring.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key %q", key.ID)
+		}
+		ring.keys[key.ID] = key
+		if currentID == "" && (ring.current == nil || key.ID > ring.current.ID) {
+			ring.current = key
+		}
+	}
+
+	if currentID != "" {
+		ring.current = ring.keys[currentID]
+		if ring.current == nil {
+			return nil, fmt.Errorf("unknown signing key %q", currentID)
+		}
+	}
+
+	return ring, nil
+}
+
+// Current returns the key new tokens are signed with.
+func (k *Keyring) Current() *SigningKey {
+	return k.current
+}
+
+// JWK is a public key in JSON Web Key form (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	ID        string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP keys
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+// JWKS returns the public keys of the keyring, in kid order. HMAC secrets
+// can't be published, so they are left out.
+func (k *Keyring) JWKS() []JWK {
+	b64 := base64.RawURLEncoding.EncodeToString
+	// fixed pads EC coordinates to the curve size, as RFC 7518 requires
+	fixed := func(n *big.Int) string {
+		return b64(n.FillBytes(make([]byte, 32)))
+	}
+
+	jwks := []JWK{}
+	for _, key := range k.keys {
+		jwk := JWK{ID: key.ID, Use: "sig", Algorithm: key.Method.Alg()}
+		switch public := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType, jwk.N, jwk.E = "RSA", b64(public.N.Bytes()), b64(big.NewInt(int64(public.E)).Bytes())
+		case *ecdsa.PublicKey:
+			jwk.KeyType, jwk.Curve, jwk.X, jwk.Y = "EC", "P-256", fixed(public.X), fixed(public.Y)
+		case ed25519.PublicKey:
+			jwk.KeyType, jwk.Curve, jwk.X = "OKP", "Ed25519", b64(public)
+		default:
+			continue
+		}
+		jwks = append(jwks, jwk)
+	}
+	slices.SortFunc(jwks, func(a, b JWK) int { return strings.Compare(a.ID, b.ID) })
+
+	return jwks
+}
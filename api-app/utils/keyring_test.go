@@ -0,0 +1,293 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	testSecret  = []byte(strings.Repeat("s", minSecretLength))
+	otherSecret = []byte(strings.Repeat("o", minSecretLength))
+)
+
+// Keys of every supported type, generated once since RSA keys take a while
+var (
+	rsaKey  *rsa.PrivateKey
+	p256Key *ecdsa.PrivateKey
+	edKey   ed25519.PrivateKey
+	// rsaPEM, p256PEM and edPEM are PKCS#1, SEC 1 and PKCS#8 encodings
+	rsaPEM, p256PEM, edPEM []byte
+)
+
+func init() {
+	var err error
+	if rsaKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		panic(err)
+	}
+	if p256Key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		panic(err)
+	}
+	if _, edKey, err = ed25519.GenerateKey(rand.Reader); err != nil {
+		panic(err)
+	}
+
+	rsaPEM = pemBlock("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	p256PEM = pemBlock("EC PRIVATE KEY", must(x509.MarshalECPrivateKey(p256Key)))
+	edPEM = pemBlock("PRIVATE KEY", must(x509.MarshalPKCS8PrivateKey(edKey)))
+}
+
+// must panics on err, for the key encodings that can't fail.
+func must[T any](value T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+func pemBlock(typ string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+}
+
+// mustKey returns a function failing the test if a key couldn't be built.
+func mustKey(t *testing.T) func(*SigningKey, error) *SigningKey {
+	return func(key *SigningKey, err error) *SigningKey {
+		t.Helper()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+}
+
+func TestPrivateKey(t *testing.T) {
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		pem  []byte
+		alg  string
+	}{
+		{"RSA PKCS#1", rsaPEM, "RS256"},
+		{"RSA PKCS#8", pemBlock("PRIVATE KEY", must(x509.MarshalPKCS8PrivateKey(rsaKey))), "RS256"},
+		{"P-256", p256PEM, "ES256"},
+		{"Ed25519", edPEM, "EdDSA"},
+
+		{"not PEM", []byte("secret"), ""},
+		{"public key", pemBlock("PUBLIC KEY", must(x509.MarshalPKIXPublicKey(&rsaKey.PublicKey))), ""},
+		{"P-384", pemBlock("EC PRIVATE KEY", must(x509.MarshalECPrivateKey(p384Key))), ""},
+		{"corrupt", pemBlock("RSA PRIVATE KEY", []byte("garbage")), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := PrivateKey("k1", tt.pem)
+			if tt.alg == "" {
+				if err == nil {
+					t.Fatalf("PrivateKey() = %s key, want an error", key.Method.Alg())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PrivateKey() error = %v", err)
+			}
+			if key.ID != "k1" || key.Method.Alg() != tt.alg {
+				t.Errorf("PrivateKey() = %s key %q, want %s key \"k1\"", key.Method.Alg(), key.ID, tt.alg)
+			}
+		})
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"2024-rsa.pem": rsaPEM,
+		"2025-hs.key":  append(testSecret, '\n'),
+		"README.txt":   []byte("not a key"),
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), contents, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "old.pem"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := LoadKeys(dir)
+	if err != nil {
+		t.Fatalf("LoadKeys() error = %v", err)
+	}
+	if len(keys) != 2 || keys[0].ID != "2024-rsa" || keys[0].Method.Alg() != "RS256" || keys[1].ID != "2025-hs" || keys[1].Method.Alg() != "HS256" {
+		t.Fatalf("LoadKeys() = %+v, want the RSA key then the HS256 one", keys)
+	}
+	// The trailing newline isn't part of the secret
+	if string(keys[1].signKey.([]byte)) != string(testSecret) {
+		t.Errorf("secret = %q, want %q", keys[1].signKey, testSecret)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "short.key"), []byte("short"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeys(dir); err == nil {
+		t.Error("LoadKeys() with a short secret succeeded")
+	}
+	if _, err := LoadKeys(filepath.Join(dir, "missing")); err == nil {
+		t.Error("LoadKeys() of a missing directory succeeded")
+	}
+}
+
+func TestNewKeyring(t *testing.T) {
+	key := mustKey(t)
+	a, b, c := key(HMACKey("a", testSecret)), key(PrivateKey("b", rsaPEM)), key(HMACKey("c", otherSecret))
+
+	tests := []struct {
+		name    string
+		keys    []*SigningKey
+		current string
+		want    string
+	}{
+		{"last kid by default", []*SigningKey{c, a, b}, "", "c"},
+		{"named key", []*SigningKey{a, b, c}, "b", "b"},
+		{"single key", []*SigningKey{a}, "", "a"},
+
+		{"no keys", nil, "", ""},
+		{"unknown key", []*SigningKey{a, b}, "c", ""},
+		{"duplicate kid", []*SigningKey{a, key(HMACKey("a", otherSecret))}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring, err := NewKeyring(tt.keys, tt.current)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("NewKeyring() signs with %q, want an error", ring.Current().ID)
+				}
+				return
+			}
+			if err != nil || ring.Current().ID != tt.want {
+				t.Fatalf("NewKeyring() = %+v, %v; want it signing with %q", ring, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyringJWKS(t *testing.T) {
+	key := mustKey(t)
+	ring, err := NewKeyring([]*SigningKey{
+		key(PrivateKey("rsa", rsaPEM)),
+		key(PrivateKey("ec", p256PEM)),
+		key(PrivateKey("ed", edPEM)),
+		key(HMACKey("hs", testSecret)),
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The HMAC secret stays private
+	jwks := ring.JWKS()
+	if len(jwks) != 3 {
+		t.Fatalf("JWKS() = %+v, want 3 keys", jwks)
+	}
+	want := []JWK{
+		{KeyType: "EC", ID: "ec", Use: "sig", Algorithm: "ES256", Curve: "P-256"},
+		{KeyType: "OKP", ID: "ed", Use: "sig", Algorithm: "EdDSA", Curve: "Ed25519"},
+		{KeyType: "RSA", ID: "rsa", Use: "sig", Algorithm: "RS256"},
+	}
+	for i, jwk := range jwks {
+		if jwk.KeyType != want[i].KeyType || jwk.ID != want[i].ID || jwk.Use != "sig" || jwk.Algorithm != want[i].Algorithm || jwk.Curve != want[i].Curve {
+			t.Errorf("JWKS()[%d] = %+v, want %+v", i, jwk, want[i])
+		}
+	}
+
+	// 32 bytes each, unpadded base64
+	if len(jwks[0].X) != 43 || len(jwks[0].Y) != 43 || len(jwks[1].X) != 43 {
+		t.Errorf("coordinates %q, %q and %q, want 43 characters each", jwks[0].X, jwks[0].Y, jwks[1].X)
+	}
+	if jwks[2].E != "AQAB" || jwks[2].N == "" {
+		t.Errorf("RSA key n = %q, e = %q; want the modulus and AQAB", jwks[2].N, jwks[2].E)
+	}
+}
+
+func TestKeyringVerifyToken(t *testing.T) {
+	key := mustKey(t)
+	keys := []*SigningKey{
+		key(PrivateKey("rsa", rsaPEM)),
+		key(PrivateKey("ec", p256PEM)),
+		key(PrivateKey("ed", edPEM)),
+		key(HMACKey("hs", testSecret)),
+	}
+
+	// Tokens signed with each key verify with a keyring holding them all
+	for _, signingKey := range keys {
+		signer, err := NewKeyring(keys, signingKey.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := signer.GenerateToken("ada@example.com", 1, 2, time.Minute)
+		if err != nil {
+			t.Fatalf("GenerateToken(%s) error = %v", signingKey.ID, err)
+		}
+		verifier, err := NewKeyring(keys, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := verifier.VerifyToken(token)
+		if err != nil || claims.UserID != 1 || claims.SessionID != 2 || claims.Email != "ada@example.com" {
+			t.Errorf("VerifyToken(%s token) = %+v, %v", signingKey.ID, claims, err)
+		}
+	}
+
+	ring, err := NewKeyring(keys, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := jwt.MapClaims{"jti": "1", "userID": 1, "sid": 2, "exp": time.Now().Add(time.Minute).Unix()}
+	sign := func(method jwt.SigningMethod, kid string, key any) string {
+		t.Helper()
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		// The RSA public key, which the JWKS publishes, used as an HS256
+		// secret under the RSA key's kid
+		{"HS256 under an RSA kid", sign(jwt.SigningMethodHS256, "rsa", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey))},
+		{"RS256 under an HMAC kid", sign(jwt.SigningMethodRS256, "hs", rsaKey)},
+		{"HS512 under an HMAC kid", sign(jwt.SigningMethodHS512, "hs", testSecret)},
+		{"ES256 under an EdDSA kid", sign(jwt.SigningMethodES256, "ed", p256Key)},
+		{"unknown kid", sign(jwt.SigningMethodHS256, "other", testSecret)},
+		{"no kid", sign(jwt.SigningMethodHS256, "", testSecret)},
+		{"another secret", sign(jwt.SigningMethodHS256, "hs", otherSecret)},
+		{"none", sign(jwt.SigningMethodNone, "hs", jwt.UnsafeAllowNoneSignatureType)},
+	}
+	for _, tt := range tests {
+		if claims, err := ring.VerifyToken(tt.token); err == nil {
+			t.Errorf("VerifyToken(%s) = %+v, want an error", tt.name, claims)
+		}
+	}
+	if _, err := ring.VerifyToken(sign(jwt.SigningMethodHS256, "hs", testSecret)); err != nil {
+		t.Errorf("VerifyToken() of a well-signed token error = %v", err)
+	}
+}